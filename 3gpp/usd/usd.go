@@ -0,0 +1,214 @@
+// Package usd builds the 3GPP MBMS User Service Description of TS 26.346,
+// USD-schema-main.xsd, of the services of this API.
+package usd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+)
+
+const (
+	Namespace            = "urn:3GPP:metadata:2005:MBMS:userServiceDescription"
+	DefaultSchemaVersion = 4
+	DefaultAccessBearer  = api.TGPP_R7_MBSFN_FDD
+)
+
+// Options set the description the API types do not carry. The zero value
+// uses the defaults.
+type Options struct {
+	// BaseURL the fragments are published under, the URIs are relative if
+	// empty. See SessionDescriptionPath, ProcedurePath and SchedulePath.
+	BaseURL string
+	// accessBearer of each access group, DefaultAccessBearer if missing
+	AccessBearers map[uint8][]api.DeliveryMode
+	SchemaVersion uint // schemaVersion, DefaultSchemaVersion if 0
+}
+
+func (o *Options) orDefault() *Options {
+	if o == nil {
+		o = &Options{}
+	}
+	c := *o
+	if c.SchemaVersion == 0 {
+		c.SchemaVersion = DefaultSchemaVersion
+	}
+	return &c
+}
+
+// uri returns the URI of the fragment at path p.
+func (o *Options) uri(p string) (string, error) {
+	if o.BaseURL == "" {
+		return p, nil
+	}
+	u, err := url.Parse(o.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", o.BaseURL, err)
+	}
+	return u.JoinPath(p).String(), nil
+}
+
+// SessionDescriptionPath returns the path of the SDP of delivery method i of
+// session s, as returned by sdp.NewSession.
+func SessionDescriptionPath(s api.Session, i int) string {
+	return fmt.Sprintf("session-%d-%d.sdp", s.ID, i)
+}
+
+// ProcedurePath returns the path of the associated delivery procedure
+// description of session s.
+func ProcedurePath(s api.Session) string {
+	return fmt.Sprintf("procedure-%d.xml", s.ID)
+}
+
+// SchedulePath returns the path of the schedule description of service svc.
+func SchedulePath(svc api.Service) string {
+	return fmt.Sprintf("schedule-%d.xml", svc.ID)
+}
+
+// BundleDescription is the bundleDescription of the user service
+// descriptions built by this package.
+type BundleDescription struct {
+	UserServiceDescription []UserServiceDescription `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription userServiceDescription" json:"userServiceDescription"`
+	SchemaVersion          uint                     `xml:"urn:3gpp:metadata:2009:MBMS:schemaVersion schemaVersion" json:"schemaVersion"`
+}
+
+// UserServiceDescription is the part of the userServiceDescription of the
+// models this package sets. Unlike the generated types, its access groups
+// carry their id and its schedule only its URI.
+type UserServiceDescription struct {
+	Name            []gsma.Name           `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription name,omitempty" json:"name,omitempty"`
+	ServiceLanguage []string              `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription serviceLanguage,omitempty" json:"serviceLanguage,omitempty"`
+	DeliveryMethod  []gsma.DeliveryMethod `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription deliveryMethod" json:"deliveryMethod"`
+	AccessGroup     []AccessGroup         `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription accessGroup,omitempty" json:"accessGroup,omitempty"`
+	ServiceGroup    *gsma.ServiceGroup    `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription serviceGroup,omitempty" json:"serviceGroup,omitempty"`
+	Schedule        *Schedule             `xml:"urn:3GPP:metadata:2009:MBMS:userServiceDescription schedule,omitempty" json:"schedule,omitempty"`
+	ServiceId       string                `xml:"serviceId,attr" json:"serviceId"`
+}
+
+// AccessGroup is an accessGroup and the id delivery methods refer to it by.
+type AccessGroup struct {
+	AccessBearer []string `xml:"urn:3GPP:metadata:2005:MBMS:userServiceDescription accessBearer" json:"accessBearer"`
+	Id           uint     `xml:"id,attr" json:"id"`
+}
+
+// Schedule refers to the schedule description of the service.
+type Schedule struct {
+	ScheduleDescriptionURI string `xml:"urn:3GPP:metadata:2009:MBMS:userServiceDescription scheduleDescriptionURI" json:"scheduleDescriptionURI"`
+}
+
+// NewBundleDescription returns the bundleDescription of service svc delivered
+// by sessions.
+func NewBundleDescription(o *Options, svc api.Service, sessions ...api.Session) (*BundleDescription, error) {
+	o = o.orDefault()
+	usd, err := NewUserServiceDescription(svc, sessions, o)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", svc.ServiceId, err)
+	}
+	return &BundleDescription{
+		UserServiceDescription: []UserServiceDescription{usd},
+		SchemaVersion:          o.SchemaVersion,
+	}, nil
+}
+
+// NewUserServiceDescription returns the userServiceDescription of service svc,
+// a deliveryMethod per delivery method of its sessions. The delivery methods
+// of access group 0 are in no access group, those of the others refer to an
+// accessGroup of the bearers of o.AccessBearers. Sessions with a unicast repair
+// host refer to their associated delivery procedure, and the service refers
+// to its schedule if a session has a start time.
+func NewUserServiceDescription(svc api.Service, sessions []api.Session, o *Options) (UserServiceDescription, error) {
+	o = o.orDefault()
+	usd := UserServiceDescription{
+		Name:            svc.Name,
+		ServiceLanguage: svc.Lang,
+		ServiceId:       svc.ServiceId,
+	}
+	if usd.ServiceId == "" {
+		return usd, fmt.Errorf("no service identifier")
+	}
+	if len(sessions) == 0 {
+		return usd, fmt.Errorf("no session")
+	}
+	if svc.GroupId != 0 {
+		usd.ServiceGroup = &gsma.ServiceGroup{GroupID: strconv.Itoa(svc.GroupId)}
+	}
+	var groups []uint8
+	scheduled := false
+	for _, s := range sessions {
+		if len(s.Delivery) == 0 {
+			return usd, fmt.Errorf("session %d has no delivery method", s.ID)
+		}
+		scheduled = scheduled || !time.Time(s.Reoccurrences.Dtstart).IsZero()
+		var procedure string
+		if s.RprHost != "" {
+			var err error
+			if procedure, err = o.uri(ProcedurePath(s)); err != nil {
+				return usd, err
+			}
+		}
+		for i, d := range s.Delivery {
+			uri, err := o.uri(SessionDescriptionPath(s, i))
+			if err != nil {
+				return usd, err
+			}
+			usd.DeliveryMethod = append(usd.DeliveryMethod, gsma.DeliveryMethod{
+				AccessGroupId:                     int(d.AccessGroup),
+				AssociatedProcedureDescriptionURI: procedure,
+				SessionDescriptionURI:             uri,
+			})
+			if d.AccessGroup != 0 && !slices.Contains(groups, d.AccessGroup) {
+				groups = append(groups, d.AccessGroup)
+			}
+		}
+	}
+	slices.Sort(groups)
+	for _, g := range groups {
+		usd.AccessGroup = append(usd.AccessGroup, accessGroup(g, o.AccessBearers[g]))
+	}
+	if scheduled {
+		uri, err := o.uri(SchedulePath(svc))
+		if err != nil {
+			return usd, err
+		}
+		usd.Schedule = &Schedule{ScheduleDescriptionURI: uri}
+	}
+	return usd, nil
+}
+
+// accessGroup returns the accessGroup id of bearers, DefaultAccessBearer if
+// none.
+func accessGroup(id uint8, bearers []api.DeliveryMode) AccessGroup {
+	if len(bearers) == 0 {
+		bearers = []api.DeliveryMode{DefaultAccessBearer}
+	}
+	g := AccessGroup{Id: uint(id)}
+	for _, b := range bearers {
+		g.AccessBearer = append(g.AccessBearer, string(b))
+	}
+	return g
+}
+
+// MarshalBundleDescription returns the XML document of b.
+func MarshalBundleDescription(b *BundleDescription) ([]byte, error) {
+	out, err := xml.MarshalIndent(wrapper{b}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// wrapper encodes the bundleDescription root element, which has no type of
+// its own in the models.
+type wrapper struct {
+	b *BundleDescription
+}
+
+func (w wrapper) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return e.EncodeElement(w.b, xml.StartElement{Name: xml.Name{Space: Namespace, Local: "bundleDescription"}})
+}
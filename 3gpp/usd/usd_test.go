@@ -0,0 +1,134 @@
+package usd_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+	"github.com/Blockcast/multicast-api/3gpp/usd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleDescription(t *testing.T) {
+	tsi := uint64(1)
+	ep := api.MulticastEndpointAddressesType{
+		{Source: netip.MustParseAddr("10.0.0.1"), Group: netip.MustParseAddr("232.1.1.1"), DestPort: 3000, TSI: &tsi},
+	}
+	delivery := func(group uint8) api.DeliveryMethod {
+		return api.DeliveryMethod{
+			AccessGroup: group,
+			BitrateKbps: api.BitRateType{Average: 200, Maximum: 200},
+			FEC:         api.FECParamsType{{Encoding: api.COM_NO_C_FEC_ENC_ID, Endpoint: ep}},
+		}
+	}
+	svc := api.Service{
+		ID:                3,
+		ServiceId:         "urn:3gpp:1234567890coolcat",
+		Name:              []gsma.Name{{Name: "Welcome", Lang: "EN"}, {Name: "Willkommen", Lang: "DE"}},
+		Lang:              []string{"EN", "DE"},
+		GroupId:           12,
+		TransportProtocol: api.FLUTE,
+	}
+	sessions := []api.Session{{
+		ID:            1,
+		Reoccurrences: api.RRuleSet{Dtstart: api.TimeZ(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		Delivery:      []api.DeliveryMethod{delivery(1), delivery(2)},
+	}, {
+		ID:             2,
+		RprHost:        "repair.example.com",
+		RprUnicastPath: "/repair",
+		Delivery:       []api.DeliveryMethod{delivery(0), delivery(1)},
+	}}
+	b, err := usd.NewBundleDescription(&usd.Options{
+		BaseURL:       "http://www.example.com/3gpp/mbms",
+		AccessBearers: map[uint8][]api.DeliveryMode{1: {api.TGPP_R7_MBSFN_FDD, api.TGPP_R7_MBSFN_TDD}},
+	}, svc, sessions...)
+	require.NoError(t, err)
+	assert.Equal(t, uint(usd.DefaultSchemaVersion), b.SchemaVersion)
+	require.Len(t, b.UserServiceDescription, 1)
+	u := b.UserServiceDescription[0]
+	assert.Equal(t, svc.ServiceId, u.ServiceId)
+	assert.Equal(t, svc.Name, u.Name)
+	assert.Equal(t, []string{"EN", "DE"}, u.ServiceLanguage)
+	assert.Equal(t, &gsma.ServiceGroup{GroupID: "12"}, u.ServiceGroup)
+	assert.Equal(t, &usd.Schedule{ScheduleDescriptionURI: "http://www.example.com/3gpp/mbms/schedule-3.xml"}, u.Schedule)
+	assert.Equal(t, []gsma.DeliveryMethod{
+		{AccessGroupId: 1, SessionDescriptionURI: "http://www.example.com/3gpp/mbms/session-1-0.sdp"},
+		{AccessGroupId: 2, SessionDescriptionURI: "http://www.example.com/3gpp/mbms/session-1-1.sdp"},
+		{
+			SessionDescriptionURI:             "http://www.example.com/3gpp/mbms/session-2-0.sdp",
+			AssociatedProcedureDescriptionURI: "http://www.example.com/3gpp/mbms/procedure-2.xml",
+		},
+		{
+			AccessGroupId:                     1,
+			SessionDescriptionURI:             "http://www.example.com/3gpp/mbms/session-2-1.sdp",
+			AssociatedProcedureDescriptionURI: "http://www.example.com/3gpp/mbms/procedure-2.xml",
+		},
+	}, u.DeliveryMethod)
+	assert.Equal(t, []usd.AccessGroup{
+		{Id: 1, AccessBearer: []string{"3GPP.R7.MBSFN-FDD", "3GPP.R7.MBSFN-TDD"}},
+		{Id: 2, AccessBearer: []string{string(usd.DefaultAccessBearer)}},
+	}, u.AccessGroup)
+
+	out, err := usd.MarshalBundleDescription(b)
+	require.NoError(t, err)
+	s := string(out)
+	assert.Contains(t, s, `<bundleDescription xmlns="urn:3GPP:metadata:2005:MBMS:userServiceDescription">`)
+	assert.Contains(t, s, ` id="2">`)
+	assert.Contains(t, s, `>http://www.example.com/3gpp/mbms/schedule-3.xml</scheduleDescriptionURI>`)
+	assert.Contains(t, s, ` lang="DE">Willkommen</name>`)
+
+	var again usd.BundleDescription
+	require.NoError(t, xml.Unmarshal(out, &again))
+	assert.Equal(t, b, &again)
+}
+
+func TestExample(t *testing.T) {
+	// the accessGroup ids of the example parse, the example
+	// is cut short after its userServiceDescription
+	data, err := os.ReadFile("../models/example.xml")
+	require.NoError(t, err)
+	data, _, _ = bytes.Cut(data, []byte("<sv:schemaVersion>"))
+	data = append(data, "<sv:schemaVersion>1</sv:schemaVersion></bundleDescription>"...)
+	var b usd.BundleDescription
+	require.NoError(t, xml.Unmarshal(data, &b))
+	require.Len(t, b.UserServiceDescription, 1)
+	u := b.UserServiceDescription[0]
+	assert.Equal(t, []usd.AccessGroup{
+		{Id: 1, AccessBearer: []string{"3GPP.R6.GERAN", "3GPP.R6.UTRAN"}},
+		{Id: 2, AccessBearer: []string{"3GPP.R6.UTRAN"}},
+	}, u.AccessGroup)
+	require.Len(t, u.DeliveryMethod, 4)
+	assert.Equal(t, 2, u.DeliveryMethod[3].AccessGroupId)
+}
+
+func TestRelative(t *testing.T) {
+	svc := api.Service{ID: 3, ServiceId: "urn:3gpp:1234567890coolcat", TransportProtocol: api.FLUTE}
+	sessions := []api.Session{{ID: 2, RprHost: "repair.example.com", Delivery: []api.DeliveryMethod{{}}}}
+	u, err := usd.NewUserServiceDescription(svc, sessions, nil)
+	require.NoError(t, err)
+	assert.Nil(t, u.Schedule, "no start time")
+	assert.Equal(t, "session-2-0.sdp", u.DeliveryMethod[0].SessionDescriptionURI)
+	assert.Equal(t, "procedure-2.xml", u.DeliveryMethod[0].AssociatedProcedureDescriptionURI)
+}
+
+func TestErrors(t *testing.T) {
+	svc := api.Service{ServiceId: "urn:3gpp:1234567890coolcat", TransportProtocol: api.FLUTE}
+	sessions := []api.Session{{ID: 1, Delivery: []api.DeliveryMethod{{}}}}
+	_, err := usd.NewBundleDescription(nil, svc)
+	assert.ErrorContains(t, err, "no session")
+	_, err = usd.NewBundleDescription(&usd.Options{BaseURL: "://x"}, svc, sessions...)
+	assert.ErrorContains(t, err, "invalid base URL")
+	sessions[0].Delivery = nil
+	_, err = usd.NewBundleDescription(nil, svc, sessions...)
+	assert.ErrorContains(t, err, "session 1 has no delivery method")
+	svc.ServiceId = ""
+	_, err = usd.NewBundleDescription(nil, svc, sessions...)
+	assert.ErrorContains(t, err, "no service identifier")
+}
@@ -0,0 +1,80 @@
+// Package atsc generates and parses the ATSC 3.0 signaling of A/331 of the
+// ROUTE services of this API: the Service List Table (SLT) and the Service
+// Layer Signaling (SLS) of each service, its User Service Bundle Description
+// (USBD) and Service-based Transport Session Instance Description (S-TSID).
+package atsc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// Namespaces of the A/331 signaling tables
+const (
+	NamespaceSLT   = "tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/SLT/1.0/"
+	NamespaceUSBD  = "tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/ROUTEUSD/1.0/"
+	NamespaceSTSID = "tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/S-TSID/1.0/"
+)
+
+// SLSTSI is the TSI of the LCT channel of the ROUTE session carrying the SLS.
+const SLSTSI = 0
+
+// Options set the signaling the API types do not carry. The zero value uses
+// the defaults.
+type Options struct {
+	BSID     uint16          // bsid of the SLT, the broadcast stream
+	Category ServiceCategory // serviceCategory, see category if 0
+}
+
+// ServiceSession is a session and the service it delivers.
+type ServiceSession struct {
+	Service api.Service
+	Session api.Session
+}
+
+// category returns the serviceCategory of session s, o.Category if set,
+// linear audio/video for live sessions and app-based for the others.
+func (o *Options) category(s api.Session) ServiceCategory {
+	switch {
+	case o != nil && o.Category != 0:
+		return o.Category
+	case s.Type == api.Live:
+		return LinearAV
+	}
+	return AppBased
+}
+
+// schedule returns the start and end times of delivery method i of session s,
+// nil if unknown.
+func schedule(s api.Session, i int) (start, end *time.Time) {
+	dtstart := time.Time(s.Reoccurrences.Dtstart)
+	if dtstart.IsZero() {
+		return nil, nil
+	}
+	d := s.Delivery[i]
+	t := dtstart.Add(time.Duration(d.StartOffset)).UTC()
+	start = &t
+	if d.Duration != nil {
+		t := t.Add(time.Duration(*d.Duration))
+		end = &t
+	}
+	return start, end
+}
+
+func marshal(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func unmarshal(data []byte, v any, root string) error {
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", root, err)
+	}
+	return nil
+}
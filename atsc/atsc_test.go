@@ -0,0 +1,145 @@
+package atsc_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+	"github.com/Blockcast/multicast-api/atsc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLT(t *testing.T) {
+	minor := uint(1)
+	svc := api.Service{
+		ID:                1001,
+		ServiceId:         "https://example.com/svc/1001",
+		Name:              []gsma.Name{{Name: "Example News", Lang: "en"}},
+		Lang:              []string{"en"},
+		MajorChannelNo:    5,
+		MinorChannelNo:    &minor,
+		TransportProtocol: api.ROUTE,
+	}
+	s := api.Session{Type: api.Live, Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{{Endpoint: api.MulticastEndpointAddressesType{
+		{Source: netip.MustParseAddr("172.16.200.1"), Group: netip.MustParseAddr("239.255.10.1"), DestPort: 5000},
+	}}}}}}
+	slt, err := atsc.NewSLT(&atsc.Options{BSID: 50}, atsc.ServiceSession{Service: svc, Session: s})
+	require.NoError(t, err)
+	out, err := atsc.MarshalSLT(slt)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<SLT xmlns="tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/SLT/1.0/" bsid="50">`,
+		`  <Service serviceId="1001" globalServiceID="https://example.com/svc/1001" sltSvcSeqNum="0" majorChannelNo="5" minorChannelNo="1" serviceCategory="1" shortServiceName="Example">`,
+		`    <BroadcastSvcSignaling slsProtocol="1" slsMajorProtocolVersion="1" slsMinorProtocolVersion="0" slsDestinationIpAddress="239.255.10.1" slsDestinationUdpPort="5000" slsSourceIpAddress="172.16.200.1"></BroadcastSvcSignaling>`,
+		`  </Service>`,
+		`</SLT>`,
+	}, "\n"), string(out))
+
+	parsed, err := atsc.UnmarshalSLT(out)
+	require.NoError(t, err)
+	assert.Equal(t, slt, parsed)
+
+	usbd, err := atsc.NewUSBD(svc, s)
+	require.NoError(t, err)
+	imported, err := parsed.Service[0].APIService(&usbd.UserServiceDescription[0])
+	require.NoError(t, err)
+	assert.Equal(t, svc, imported)
+
+	imported, err = parsed.Service[0].APIService(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []gsma.Name{{Name: "Example"}}, imported.Name, "shortServiceName")
+}
+
+func TestUSBD(t *testing.T) {
+	svc := api.Service{
+		ID:        1001,
+		ServiceId: "https://example.com/svc/1001",
+		Name:      []gsma.Name{{Name: "Example News", Lang: "en"}},
+		Lang:      []string{"en"},
+	}
+	s := api.Session{Delivery: []api.DeliveryMethod{
+		{
+			BroadcastBasePattern: []string{"https://example.com/svc/1001/"},
+			UnicastBasePattern:   []string{"https://cdn.example.com/svc/1001/"},
+		},
+		{UnicastBasePattern: []string{"https://cdn.example.com/unicast-only/"}},
+	}}
+	b, err := atsc.NewUSBD(svc, s)
+	require.NoError(t, err)
+	out, err := atsc.MarshalUSBD(b)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<BundleDescriptionROUTE xmlns="tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/ROUTEUSD/1.0/">`,
+		`  <UserServiceDescription globalServiceID="https://example.com/svc/1001" serviceId="1001">`,
+		`    <Name lang="en">Example News</Name>`,
+		`    <ServiceLanguage>en</ServiceLanguage>`,
+		`    <DeliveryMethod>`,
+		`      <BroadcastAppService>`,
+		`        <BasePattern>https://example.com/svc/1001/</BasePattern>`,
+		`      </BroadcastAppService>`,
+		`      <UnicastAppService>`,
+		`        <BasePattern>https://cdn.example.com/svc/1001/</BasePattern>`,
+		`      </UnicastAppService>`,
+		`    </DeliveryMethod>`,
+		`  </UserServiceDescription>`,
+		`</BundleDescriptionROUTE>`,
+	}, "\n"), string(out))
+
+	parsed, err := atsc.UnmarshalUSBD(out)
+	require.NoError(t, err)
+	assert.Equal(t, b, parsed)
+}
+
+func TestErrors(t *testing.T) {
+	tsi := uint64(1)
+	ep := api.MulticastEndpointAddressType{Source: netip.MustParseAddr("172.16.200.1"), Group: netip.MustParseAddr("239.255.10.1"), DestPort: 5000, TSI: &tsi}
+	s := api.Session{Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{{Endpoint: api.MulticastEndpointAddressesType{ep}}}}}}
+	svc := api.Service{ServiceId: "svc", TransportProtocol: api.FLUTE}
+	_, err := atsc.NewSLT(nil, atsc.ServiceSession{Service: svc, Session: s})
+	assert.ErrorContains(t, err, "unsupported transport protocol")
+	_, err = atsc.NewSTSID(svc, s)
+	assert.ErrorContains(t, err, "unsupported transport protocol")
+
+	svc = api.Service{ID: 1 << 16, ServiceId: "svc", TransportProtocol: api.ROUTE}
+	_, err = atsc.NewSLT(nil, atsc.ServiceSession{Service: svc, Session: s})
+	assert.ErrorContains(t, err, "exceeds 16 bits")
+	_, err = atsc.NewUSBD(svc, s)
+	assert.ErrorContains(t, err, "exceeds 16 bits")
+
+	svc.ID = 1
+	ep.Source = netip.Addr{}
+	s.Delivery[0].FEC[0].Endpoint[0] = ep
+	_, err = atsc.NewService(svc, s, nil)
+	assert.ErrorContains(t, err, "no source address")
+	_, err = atsc.NewService(svc, api.Session{ID: 7}, nil)
+	assert.ErrorContains(t, err, "session 7 has no endpoint")
+
+	_, err = atsc.UnmarshalSLT([]byte(`<SLT bsid="x"/>`))
+	assert.ErrorContains(t, err, "invalid SLT")
+
+	slt := atsc.Service{BroadcastSvcSignaling: &atsc.BroadcastSvcSignaling{SLSProtocol: atsc.ProtocolMMTP}}
+	_, err = slt.APIService(nil)
+	assert.ErrorContains(t, err, "unsupported slsProtocol")
+}
+
+func TestCategory(t *testing.T) {
+	svc := api.Service{TransportProtocol: api.ROUTE}
+	s := api.Session{Type: api.Live, Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{{Endpoint: api.MulticastEndpointAddressesType{
+		{Source: netip.MustParseAddr("172.16.200.1"), Group: netip.MustParseAddr("239.255.10.1"), DestPort: 5000},
+	}}}}}}
+	st, err := atsc.NewService(svc, s, nil)
+	require.NoError(t, err)
+	assert.Equal(t, atsc.LinearAV, st.ServiceCategory)
+	s.Type = api.Files
+	st, err = atsc.NewService(svc, s, nil)
+	require.NoError(t, err)
+	assert.Equal(t, atsc.AppBased, st.ServiceCategory)
+	st, err = atsc.NewService(svc, s, &atsc.Options{Category: atsc.ESG})
+	require.NoError(t, err)
+	assert.Equal(t, atsc.ESG, st.ServiceCategory)
+}
@@ -0,0 +1,206 @@
+package atsc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+)
+
+// ServiceCategory is the serviceCategory of a service of the SLT.
+type ServiceCategory uint8
+
+const (
+	LinearAV    ServiceCategory = 1 // linear audio/video
+	LinearAudio ServiceCategory = 2 // linear audio only
+	AppBased    ServiceCategory = 3
+	ESG         ServiceCategory = 4 // electronic service guide
+	EAS         ServiceCategory = 5 // emergency alert system
+)
+
+// SLSProtocol is the slsProtocol of the SLS of a service.
+type SLSProtocol uint8
+
+const (
+	ProtocolROUTE SLSProtocol = 1
+	ProtocolMMTP  SLSProtocol = 2
+)
+
+// maxShortServiceName is the maximum length of a shortServiceName in characters.
+const maxShortServiceName = 7
+
+// UShortList is a list of unsigned shorts, encoded separated by spaces.
+type UShortList []uint16
+
+func (l UShortList) MarshalText() ([]byte, error) {
+	f := make([]string, len(l))
+	for i, v := range l {
+		f[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return []byte(strings.Join(f, " ")), nil
+}
+
+func (l *UShortList) UnmarshalText(in []byte) error {
+	*l = nil
+	for _, f := range strings.Fields(string(in)) {
+		v, err := strconv.ParseUint(f, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid unsigned short %q", f)
+		}
+		*l = append(*l, uint16(v))
+	}
+	return nil
+}
+
+// SLT is the Service List Table of a broadcast stream.
+type SLT struct {
+	BSID            UShortList `xml:"bsid,attr"`
+	SLTCapabilities string     `xml:"sltCapabilities,attr,omitempty"`
+	SLTInetURL      []InetURL  `xml:"SLTInetUrl,omitempty"`
+	Service         []Service  `xml:"Service"`
+}
+
+func (t *SLT) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type T SLT
+	start.Name = xml.Name{Space: NamespaceSLT, Local: "SLT"}
+	return e.EncodeElement((*T)(t), start)
+}
+
+// InetURL is the URL of the signaling available over broadband, of type
+// URLType.
+type InetURL struct {
+	URL     string `xml:",chardata"`
+	URLType uint8  `xml:"urlType,attr"`
+}
+
+// Service is a service of the SLT.
+type Service struct {
+	ServiceID               uint16                 `xml:"serviceId,attr"`
+	GlobalServiceID         string                 `xml:"globalServiceID,attr,omitempty"`
+	SLTSvcSeqNum            uint8                  `xml:"sltSvcSeqNum,attr"`
+	Protected               bool                   `xml:"protected,attr,omitempty"`
+	MajorChannelNo          uint                   `xml:"majorChannelNo,attr,omitempty"`
+	MinorChannelNo          uint                   `xml:"minorChannelNo,attr,omitempty"`
+	ServiceCategory         ServiceCategory        `xml:"serviceCategory,attr"`
+	ShortServiceName        string                 `xml:"shortServiceName,attr,omitempty"`
+	Hidden                  bool                   `xml:"hidden,attr,omitempty"`
+	BroadbandAccessRequired bool                   `xml:"broadbandAccessRequired,attr,omitempty"`
+	SvcCapabilities         string                 `xml:"svcCapabilities,attr,omitempty"`
+	BroadcastSvcSignaling   *BroadcastSvcSignaling `xml:"BroadcastSvcSignaling,omitempty"`
+	SvcInetURL              []InetURL              `xml:"SvcInetUrl,omitempty"`
+}
+
+// BroadcastSvcSignaling locates the SLS of a service in the broadcast stream.
+type BroadcastSvcSignaling struct {
+	SLSProtocol             SLSProtocol `xml:"slsProtocol,attr"`
+	SLSMajorProtocolVersion uint8       `xml:"slsMajorProtocolVersion,attr"`
+	SLSMinorProtocolVersion uint8       `xml:"slsMinorProtocolVersion,attr"`
+	SLSDestinationIPAddress netip.Addr  `xml:"slsDestinationIpAddress,attr"`
+	SLSDestinationUDPPort   uint16      `xml:"slsDestinationUdpPort,attr"`
+	SLSSourceIPAddress      netip.Addr  `xml:"slsSourceIpAddress,attr"`
+}
+
+// NewSLT returns the SLT of the sessions, a Service each.
+func NewSLT(o *Options, sessions ...ServiceSession) (*SLT, error) {
+	var bsid uint16
+	if o != nil {
+		bsid = o.BSID
+	}
+	slt := &SLT{BSID: UShortList{bsid}}
+	for _, s := range sessions {
+		svc, err := NewService(s.Service, s.Session, o)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", s.Service.ServiceId, err)
+		}
+		slt.Service = append(slt.Service, svc)
+	}
+	return slt, nil
+}
+
+// NewService returns the SLT Service of session s of service svc. Its SLS is
+// carried on the LCT channel SLSTSI of the ROUTE session of the first
+// endpoint of the session.
+func NewService(svc api.Service, s api.Session, o *Options) (Service, error) {
+	st := Service{
+		GlobalServiceID:         svc.ServiceId,
+		MajorChannelNo:          svc.MajorChannelNo,
+		ServiceCategory:         o.category(s),
+		BroadbandAccessRequired: svc.BroadbandAccessRequired,
+	}
+	if svc.TransportProtocol != api.ROUTE {
+		return st, fmt.Errorf("unsupported transport protocol %q", svc.TransportProtocol)
+	}
+	if svc.ID > 0xffff {
+		return st, fmt.Errorf("service id %d exceeds 16 bits", svc.ID)
+	}
+	st.ServiceID = uint16(svc.ID)
+	if svc.MinorChannelNo != nil {
+		st.MinorChannelNo = *svc.MinorChannelNo
+	}
+	if len(svc.Name) > 0 {
+		name := []rune(svc.Name[0].Name)
+		st.ShortServiceName = string(name[:min(len(name), maxShortServiceName)])
+	}
+	if len(s.Delivery) == 0 || len(s.Delivery[0].FEC) == 0 || len(s.Delivery[0].FEC[0].Endpoint) == 0 {
+		return st, fmt.Errorf("session %d has no endpoint", s.ID)
+	}
+	ep := s.Delivery[0].FEC[0].Endpoint[0]
+	if !ep.Source.IsValid() {
+		return st, fmt.Errorf("endpoint %s has no source address", ep.Group)
+	}
+	st.BroadcastSvcSignaling = &BroadcastSvcSignaling{
+		SLSProtocol:             ProtocolROUTE,
+		SLSMajorProtocolVersion: 1,
+		SLSDestinationIPAddress: ep.Group,
+		SLSDestinationUDPPort:   ep.DestPort,
+		SLSSourceIPAddress:      ep.Source,
+	}
+	return st, nil
+}
+
+// APIService returns the service of st, named and with the languages of the
+// user service description usd if not nil.
+func (st Service) APIService(usd *UserServiceDescription) (api.Service, error) {
+	svc := api.Service{
+		ID:                      uint(st.ServiceID),
+		ServiceId:               st.GlobalServiceID,
+		MajorChannelNo:          st.MajorChannelNo,
+		BroadbandAccessRequired: st.BroadbandAccessRequired,
+		TransportProtocol:       api.ROUTE,
+	}
+	if st.BroadcastSvcSignaling != nil && st.BroadcastSvcSignaling.SLSProtocol != ProtocolROUTE {
+		return svc, fmt.Errorf("unsupported slsProtocol %d", st.BroadcastSvcSignaling.SLSProtocol)
+	}
+	if st.MinorChannelNo != 0 {
+		minor := st.MinorChannelNo
+		svc.MinorChannelNo = &minor
+	}
+	if usd != nil {
+		if svc.ServiceId == "" {
+			svc.ServiceId = usd.GlobalServiceID
+		}
+		svc.Name, svc.Lang = usd.Name, usd.ServiceLanguage
+	}
+	if len(svc.Name) == 0 && st.ShortServiceName != "" {
+		svc.Name = []gsma.Name{{Name: st.ShortServiceName}}
+	}
+	return svc, nil
+}
+
+// MarshalSLT returns the XML document of slt.
+func MarshalSLT(slt *SLT) ([]byte, error) {
+	return marshal(slt)
+}
+
+// UnmarshalSLT parses the SLT XML document data.
+func UnmarshalSLT(data []byte) (*SLT, error) {
+	slt := &SLT{}
+	if err := unmarshal(data, slt, "SLT"); err != nil {
+		return nil, err
+	}
+	return slt, nil
+}
@@ -0,0 +1,347 @@
+package atsc
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+	"github.com/Blockcast/multicast-api/fec"
+)
+
+// PayloadFormat is the formatId of the payload of a source flow.
+type PayloadFormat uint8
+
+const (
+	FormatFile            PayloadFormat = 1 // file mode, objects described by the EFDT
+	FormatEntity          PayloadFormat = 2 // entity mode, objects with HTTP entity headers
+	FormatUnsignedPackage PayloadFormat = 3
+	FormatSignedPackage   PayloadFormat = 4
+)
+
+// SrcFECPayloadIDStartOffset is the srcFecPayloadId of source flows whose FEC
+// Payload ID is the 32-bit start offset of the packet in the object.
+const SrcFECPayloadIDStartOffset = 1
+
+// HexBinary is an xs:hexBinary.
+type HexBinary []byte
+
+func (b HexBinary) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+func (b *HexBinary) UnmarshalText(in []byte) error {
+	out, err := hex.DecodeString(string(in))
+	if err != nil {
+		return fmt.Errorf("invalid hexBinary %q", in)
+	}
+	*b = out
+	return nil
+}
+
+// STSID is the Service-based Transport Session Instance Description of a
+// service, its ROUTE sessions.
+type STSID struct {
+	RS []RS `xml:"RS"`
+}
+
+func (t *STSID) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type T STSID
+	start.Name = xml.Name{Space: NamespaceSTSID, Local: "S-TSID"}
+	return e.EncodeElement((*T)(t), start)
+}
+
+// RS is a ROUTE session, its LCT channels.
+type RS struct {
+	SIPAddr netip.Addr `xml:"sIpAddr,attr"`
+	DIPAddr netip.Addr `xml:"dIpAddr,attr"`
+	DPort   uint16     `xml:"dPort,attr"`
+	LS      []LS       `xml:"LS"`
+}
+
+// LS is an LCT channel of a ROUTE session, a source flow, a repair flow or
+// both. BW is the maximum bit rate in bit/s.
+type LS struct {
+	TSI       uint32     `xml:"tsi,attr"`
+	BW        uint       `xml:"bw,attr,omitempty"`
+	StartTime *time.Time `xml:"startTime,attr,omitempty"`
+	EndTime   *time.Time `xml:"endTime,attr,omitempty"`
+	SrcFlow   *SrcFlow   `xml:"SrcFlow,omitempty"`
+	RprFlow   *RprFlow   `xml:"RprFlow,omitempty"`
+}
+
+// SrcFlow is the source flow of an LCT channel. RT is set for the media of
+// real-time services.
+type SrcFlow struct {
+	RT          bool          `xml:"rt,attr,omitempty"`
+	MinBuffSize uint          `xml:"minBuffSize,attr,omitempty"` // in kilobytes
+	ContentInfo []ContentInfo `xml:"ContentInfo,omitempty"`
+	Payload     []Payload     `xml:"Payload"`
+}
+
+// ContentInfo describes the content carried by a source flow.
+type ContentInfo struct {
+	MediaInfo *MediaInfo `xml:"MediaInfo,omitempty"`
+}
+
+// MediaInfo identifies the DASH representation carried by a source flow.
+type MediaInfo struct {
+	RepID        string `xml:"repId,attr"`
+	ContentType  string `xml:"contentType,attr,omitempty"` // audio, video or subtitles
+	StartUpDelay uint   `xml:"startUpDelay,attr,omitempty"`
+}
+
+// Payload is the payload format of the packets of code point CodePoint of a
+// source flow.
+type Payload struct {
+	CodePoint       uint8         `xml:"codePoint,attr"`
+	FormatID        PayloadFormat `xml:"formatId,attr"`
+	Frag            uint8         `xml:"frag,attr"`
+	Order           bool          `xml:"order,attr"`
+	SrcFECPayloadID uint8         `xml:"srcFecPayloadId,attr"`
+}
+
+// RprFlow is the repair flow of an LCT channel.
+type RprFlow struct {
+	FECParameters *FECParameters `xml:"FECParameters,omitempty"`
+}
+
+// FECParameters are the RaptorQ parameters of a repair flow and the source
+// objects it protects. Overhead is the percentage of repair symbols.
+type FECParameters struct {
+	MaximumDelay    uint              `xml:"maximumDelay,attr,omitempty"` // in milliseconds
+	Overhead        uint16            `xml:"overhead,attr,omitempty"`
+	MinBuffSize     uint              `xml:"minBuffSize,attr,omitempty"` // in kilobytes
+	FECOTI          HexBinary         `xml:"fecOTI,attr"`
+	ProtectedObject []ProtectedObject `xml:"ProtectedObject,omitempty"`
+}
+
+// ProtectedObject is a source flow protected by a repair flow.
+type ProtectedObject struct {
+	SessionDescription     string `xml:"sessionDescription,attr,omitempty"`
+	TSI                    uint32 `xml:"tsi,attr"`
+	SourceTOI              string `xml:"sourceTOI,attr,omitempty"`
+	FECTransportObjectSize uint   `xml:"fecTransportObjectSize,attr,omitempty"`
+}
+
+// NewSTSID returns the S-TSID of session s of service svc. The endpoints of
+// the first FEC parameters of each delivery method are source flows, repaired
+// in the same LCT channel unless Compact No-Code, and those of the others are
+// repair flows protecting them. ROUTE repair flows are RaptorQ, their FEC OTI
+// that of an object of one maximum source block. Bit rates are converted
+// from kbit/s to bit/s.
+func NewSTSID(svc api.Service, s api.Session) (*STSID, error) {
+	if svc.TransportProtocol != api.ROUTE {
+		return nil, fmt.Errorf("unsupported transport protocol %q", svc.TransportProtocol)
+	}
+	st := &STSID{}
+	sessions := map[netip.AddrPort]map[netip.Addr]int{}
+	add := func(ep api.MulticastEndpointAddressType, ls LS) {
+		dest := netip.AddrPortFrom(ep.Group, ep.DestPort)
+		if sessions[dest] == nil {
+			sessions[dest] = map[netip.Addr]int{}
+		}
+		i, ok := sessions[dest][ep.Source]
+		if !ok {
+			i = len(st.RS)
+			sessions[dest][ep.Source] = i
+			st.RS = append(st.RS, RS{SIPAddr: ep.Source, DIPAddr: ep.Group, DPort: ep.DestPort})
+		}
+		st.RS[i].LS = append(st.RS[i].LS, ls)
+	}
+	if len(s.Delivery) == 0 {
+		return nil, fmt.Errorf("session %d has no delivery method", s.ID)
+	}
+	for i, d := range s.Delivery {
+		if len(d.FEC) == 0 {
+			return nil, fmt.Errorf("stream %d has no FEC parameters", i)
+		}
+		start, end := schedule(s, i)
+		bw := uint(d.BitrateKbps.Maximum) * 1000
+		var sources []uint32
+		for j, p := range d.FEC {
+			for k, ep := range p.Endpoint {
+				tsi, err := lctTSI(ep)
+				if err != nil {
+					return nil, fmt.Errorf("stream %d: %w", i, err)
+				}
+				ls := LS{TSI: tsi, BW: bw, StartTime: start, EndTime: end}
+				if j == 0 {
+					ls.SrcFlow = srcFlow(s, d, k)
+					sources = append(sources, tsi)
+					if p.Encoding != api.COM_NO_C_FEC_ENC_ID {
+						if ls.RprFlow, err = rprFlow(p, []uint32{tsi}); err != nil {
+							return nil, fmt.Errorf("stream %d: %w", i, err)
+						}
+					}
+				} else if ls.RprFlow, err = rprFlow(p, sources); err != nil {
+					return nil, fmt.Errorf("stream %d: %w", i, err)
+				}
+				add(ep, ls)
+			}
+		}
+	}
+	return st, nil
+}
+
+// lctTSI returns the TSI of the LCT channel of ep, A/331 TSIs are 32 bits and
+// SLSTSI carries the SLS.
+func lctTSI(ep api.MulticastEndpointAddressType) (uint32, error) {
+	switch {
+	case ep.TSI == nil:
+		return 0, fmt.Errorf("endpoint %s has no TSI", ep.Group)
+	case *ep.TSI == SLSTSI:
+		return 0, fmt.Errorf("endpoint %s: TSI %d carries the SLS", ep.Group, SLSTSI)
+	case *ep.TSI > math.MaxUint32:
+		return 0, fmt.Errorf("endpoint %s: TSI %d exceeds 32 bits", ep.Group, *ep.TSI)
+	}
+	return uint32(*ep.TSI), nil
+}
+
+// srcFlow returns the source flow of endpoint k of delivery method d of
+// session s, the first of which carries the DASH representations.
+func srcFlow(s api.Session, d api.DeliveryMethod, k int) *SrcFlow {
+	f := &SrcFlow{
+		RT: s.Type == api.Live,
+		Payload: []Payload{{
+			CodePoint:       uint8(d.FEC[0].CodePoint),
+			FormatID:        FormatFile,
+			Order:           true,
+			SrcFECPayloadID: SrcFECPayloadIDStartOffset,
+		}},
+	}
+	if d.TransmissionMode == api.Entity {
+		f.Payload[0].FormatID = FormatEntity
+	}
+	if k == 0 {
+		for _, c := range d.DASHComponent {
+			f.ContentInfo = append(f.ContentInfo, ContentInfo{MediaInfo: &MediaInfo{RepID: string(c.RepresentationIdentifier)}})
+		}
+	}
+	return f
+}
+
+// rprFlow returns the repair flow of the FEC parameters p protecting the
+// source flows of TSIs sources.
+func rprFlow(p api.FECParamType, sources []uint32) (*RprFlow, error) {
+	if p.Encoding != api.RAPTORQ_FEC_ENC_ID {
+		return nil, fmt.Errorf("unsupported repair FEC encoding %d", p.Encoding)
+	}
+	oti, err := fec.NewOTI(p, uint64(p.MaxSrcBlockLen)*uint64(p.SymbolLen))
+	if err != nil {
+		return nil, err
+	}
+	b, err := oti.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	params := &FECParameters{Overhead: uint16(math.Round(p.Redundancy * 100)), FECOTI: b}
+	for _, tsi := range sources {
+		params.ProtectedObject = append(params.ProtectedObject, ProtectedObject{TSI: tsi})
+	}
+	return &RprFlow{FECParameters: params}, nil
+}
+
+// fecParam returns the FEC parameters of the repair flow parameters f.
+func (f *FECParameters) fecParam() (api.FECParamType, error) {
+	oti, err := fec.DecodeOTI(api.RAPTORQ_FEC_ENC_ID, f.FECOTI)
+	if err != nil {
+		return api.FECParamType{}, fmt.Errorf("invalid fecOTI: %w", err)
+	}
+	bs, err := oti.BlockingStructure()
+	if err != nil {
+		return api.FECParamType{}, fmt.Errorf("invalid fecOTI: %w", err)
+	}
+	p := oti.FECParam()
+	p.MaxSrcBlockLen = bs.MaxSbLen
+	p.Redundancy = float64(f.Overhead) / 100
+	return p, nil
+}
+
+// Delivery returns the delivery method of the LCT channels of st, with the
+// base patterns of the user service description usd if not nil. The source
+// flows are the endpoints of its first FEC parameters, the repair flows those
+// of the others.
+func (st *STSID) Delivery(usd *UserServiceDescription) (api.DeliveryMethod, error) {
+	d := api.DeliveryMethod{FEC: api.FECParamsType{{Encoding: api.COM_NO_C_FEC_ENC_ID}}}
+	var repairs api.FECParamsType
+	src := false
+	for _, rs := range st.RS {
+		for _, ls := range rs.LS {
+			tsi := uint64(ls.TSI)
+			ep := api.MulticastEndpointAddressType{Source: rs.SIPAddr, Group: rs.DIPAddr, DestPort: rs.DPort, TSI: &tsi}
+			if kbps := int(math.Ceil(float64(ls.BW) / 1000)); kbps > d.BitrateKbps.Maximum {
+				d.BitrateKbps = api.BitRateType{Average: kbps, Maximum: kbps}
+			}
+			if d.Duration == nil && ls.StartTime != nil && ls.EndTime != nil {
+				duration := api.Duration(ls.EndTime.Sub(*ls.StartTime))
+				d.Duration = &duration
+			}
+			var rpr *FECParameters
+			if ls.RprFlow != nil {
+				rpr = ls.RprFlow.FECParameters
+			}
+			if ls.SrcFlow == nil {
+				if rpr == nil {
+					continue
+				}
+				p, err := rpr.fecParam()
+				if err != nil {
+					return d, fmt.Errorf("LS %d: %w", ls.TSI, err)
+				}
+				p.Endpoint = api.MulticastEndpointAddressesType{ep}
+				repairs = append(repairs, p)
+				continue
+			}
+			if !src {
+				src = true
+				if rpr != nil {
+					p, err := rpr.fecParam()
+					if err != nil {
+						return d, fmt.Errorf("LS %d: %w", ls.TSI, err)
+					}
+					d.FEC[0] = p
+				}
+				if len(ls.SrcFlow.Payload) > 0 {
+					d.FEC[0].CodePoint = api.CodePoint(ls.SrcFlow.Payload[0].CodePoint)
+					if ls.SrcFlow.Payload[0].FormatID == FormatEntity {
+						d.TransmissionMode = api.Entity
+					}
+				}
+			}
+			d.FEC[0].Endpoint = append(d.FEC[0].Endpoint, ep)
+			for _, c := range ls.SrcFlow.ContentInfo {
+				if c.MediaInfo != nil {
+					d.DASHComponent = append(d.DASHComponent, dvb.DASHComponentIdentifierType{RepresentationIdentifier: dvb.StringNoWhitespaceType(c.MediaInfo.RepID)})
+				}
+			}
+		}
+	}
+	if !src {
+		return d, fmt.Errorf("no source flow")
+	}
+	d.FEC = append(d.FEC, repairs...)
+	if usd != nil {
+		d.BroadcastBasePattern, d.UnicastBasePattern = usd.basePatterns()
+	}
+	return d, nil
+}
+
+// MarshalSTSID returns the XML document of st.
+func MarshalSTSID(st *STSID) ([]byte, error) {
+	return marshal(st)
+}
+
+// UnmarshalSTSID parses the S-TSID XML document data.
+func UnmarshalSTSID(data []byte) (*STSID, error) {
+	st := &STSID{}
+	if err := unmarshal(data, st, "S-TSID"); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
@@ -0,0 +1,145 @@
+package atsc_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/atsc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSTSID(t *testing.T) {
+	tsi, videoTSI, rprTSI := uint64(1), uint64(2), uint64(10)
+	duration := api.Duration(2 * time.Hour)
+	source := netip.MustParseAddr("172.16.200.1")
+	group := netip.MustParseAddr("239.255.10.1")
+	svc := api.Service{ID: 1001, ServiceId: "https://example.com/svc/1001", TransportProtocol: api.ROUTE}
+	s := api.Session{
+		Type:          api.Live,
+		Reoccurrences: api.RRuleSet{Dtstart: api.TimeZ(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		Delivery: []api.DeliveryMethod{{
+			Duration:             &duration,
+			BitrateKbps:          api.BitRateType{Average: 4000, Maximum: 5000},
+			TransmissionMode:     api.Entity,
+			BroadcastBasePattern: []string{"https://example.com/svc/1001/"},
+			UnicastBasePattern:   []string{"https://cdn.example.com/svc/1001/"},
+			DASHComponent:        api.DASHComponents{{RepresentationIdentifier: "V1"}, {RepresentationIdentifier: "A1"}},
+			FEC: api.FECParamsType{
+				{Encoding: api.COM_NO_C_FEC_ENC_ID, CodePoint: 8, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: source, Group: group, DestPort: 5000, TSI: &tsi},
+					{Source: source, Group: group, DestPort: 5000, TSI: &videoTSI},
+				}},
+				{Encoding: api.RAPTORQ_FEC_ENC_ID, Redundancy: 0.2, SymbolLen: 1280, MaxSrcBlockLen: 64, NumEsPerGroup: 1, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: source, Group: netip.MustParseAddr("239.255.10.2"), DestPort: 5001, TSI: &rprTSI},
+				}},
+			},
+		}},
+	}
+	st, err := atsc.NewSTSID(svc, s)
+	require.NoError(t, err)
+	require.Len(t, st.RS, 2)
+	rs := st.RS[0]
+	assert.Equal(t, netip.MustParseAddr("239.255.10.1"), rs.DIPAddr)
+	require.Len(t, rs.LS, 2)
+	ls := rs.LS[0]
+	assert.Equal(t, uint32(1), ls.TSI)
+	assert.Equal(t, uint(5000000), ls.BW)
+	require.NotNil(t, ls.StartTime)
+	require.NotNil(t, ls.EndTime)
+	assert.Equal(t, 2*time.Hour, ls.EndTime.Sub(*ls.StartTime))
+	require.NotNil(t, ls.SrcFlow)
+	assert.Nil(t, ls.RprFlow, "compact no-code")
+	assert.True(t, ls.SrcFlow.RT)
+	assert.Equal(t, []atsc.Payload{{CodePoint: 8, FormatID: atsc.FormatEntity, Order: true, SrcFECPayloadID: atsc.SrcFECPayloadIDStartOffset}}, ls.SrcFlow.Payload)
+	assert.Equal(t, []atsc.ContentInfo{{MediaInfo: &atsc.MediaInfo{RepID: "V1"}}, {MediaInfo: &atsc.MediaInfo{RepID: "A1"}}}, ls.SrcFlow.ContentInfo)
+	assert.Empty(t, rs.LS[1].SrcFlow.ContentInfo)
+
+	rpr := st.RS[1].LS[0]
+	assert.Nil(t, rpr.SrcFlow)
+	require.NotNil(t, rpr.RprFlow)
+	params := rpr.RprFlow.FECParameters
+	assert.Equal(t, uint16(20), params.Overhead)
+	assert.Len(t, params.FECOTI, 12)
+	assert.Equal(t, []atsc.ProtectedObject{{TSI: 1}, {TSI: 2}}, params.ProtectedObject)
+
+	out, err := atsc.MarshalSTSID(st)
+	require.NoError(t, err)
+	doc := string(out)
+	assert.True(t, strings.HasPrefix(doc, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<S-TSID xmlns="tag:atsc.org,2016:XMLSchemas/ATSC3/Delivery/S-TSID/1.0/">`), doc)
+	assert.Contains(t, doc, `<RS sIpAddr="172.16.200.1" dIpAddr="239.255.10.1" dPort="5000">`)
+	assert.Contains(t, doc, `<LS tsi="1" bw="5000000" startTime="2024-01-01T00:00:00Z" endTime="2024-01-01T02:00:00Z">`)
+	assert.Contains(t, doc, `<Payload codePoint="8" formatId="2" frag="0" order="true" srcFecPayloadId="1"></Payload>`)
+
+	parsed, err := atsc.UnmarshalSTSID(out)
+	require.NoError(t, err)
+	assert.Equal(t, st, parsed)
+
+	usbd, err := atsc.NewUSBD(svc, s)
+	require.NoError(t, err)
+	d, err := parsed.Delivery(&usbd.UserServiceDescription[0])
+	require.NoError(t, err)
+	want := s.Delivery[0]
+	want.BitrateKbps.Average = want.BitrateKbps.Maximum
+	assert.Equal(t, want, d)
+}
+
+func TestSTSIDInBandRepair(t *testing.T) {
+	tsi, videoTSI := uint64(1), uint64(2)
+	source := netip.MustParseAddr("172.16.200.1")
+	group := netip.MustParseAddr("239.255.10.1")
+	svc := api.Service{TransportProtocol: api.ROUTE}
+	s := api.Session{Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{
+		{Encoding: api.RAPTORQ_FEC_ENC_ID, Redundancy: 0.5, SymbolLen: 1280, MaxSrcBlockLen: 32, NumEsPerGroup: 1, Endpoint: api.MulticastEndpointAddressesType{
+			{Source: source, Group: group, DestPort: 5000, TSI: &tsi},
+			{Source: source, Group: group, DestPort: 5000, TSI: &videoTSI},
+		}},
+	}}}}
+	st, err := atsc.NewSTSID(svc, s)
+	require.NoError(t, err)
+	ls := st.RS[0].LS[1]
+	require.NotNil(t, ls.SrcFlow)
+	require.NotNil(t, ls.RprFlow)
+	assert.Equal(t, []atsc.ProtectedObject{{TSI: 2}}, ls.RprFlow.FECParameters.ProtectedObject)
+
+	d, err := st.Delivery(nil)
+	require.NoError(t, err)
+	require.Len(t, d.FEC, 1)
+	assert.Equal(t, api.RAPTORQ_FEC_ENC_ID, d.FEC[0].Encoding)
+	assert.Equal(t, uint32(32), d.FEC[0].MaxSrcBlockLen)
+	assert.Equal(t, 0.5, d.FEC[0].Redundancy)
+	assert.Len(t, d.FEC[0].Endpoint, 2)
+}
+
+func TestSTSIDErrors(t *testing.T) {
+	tsi := uint64(atsc.SLSTSI)
+	source := netip.MustParseAddr("172.16.200.1")
+	svc := api.Service{TransportProtocol: api.ROUTE}
+	s := api.Session{Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{{Endpoint: api.MulticastEndpointAddressesType{
+		{Source: source, Group: netip.MustParseAddr("239.255.10.1"), DestPort: 5000, TSI: &tsi},
+	}}}}}}
+	_, err := atsc.NewSTSID(svc, s)
+	assert.ErrorContains(t, err, "carries the SLS")
+	tsi = 1 << 32
+	_, err = atsc.NewSTSID(svc, s)
+	assert.ErrorContains(t, err, "exceeds 32 bits")
+	s.Delivery[0].FEC[0].Endpoint[0].TSI = nil
+	_, err = atsc.NewSTSID(svc, s)
+	assert.ErrorContains(t, err, "no TSI")
+
+	srcTSI, rprTSI := uint64(1), uint64(10)
+	s.Delivery[0].FEC[0].Endpoint[0].TSI = &srcTSI
+	s.Delivery[0].FEC = append(s.Delivery[0].FEC, api.FECParamType{Encoding: api.RS_GF8_FEC_ENC_ID, Endpoint: api.MulticastEndpointAddressesType{
+		{Source: source, Group: netip.MustParseAddr("239.255.10.2"), DestPort: 5001, TSI: &rprTSI},
+	}})
+	_, err = atsc.NewSTSID(svc, s)
+	assert.ErrorContains(t, err, "unsupported repair FEC encoding 5")
+
+	_, err = (&atsc.STSID{}).Delivery(nil)
+	assert.ErrorContains(t, err, "no source flow")
+	_, err = atsc.UnmarshalSTSID([]byte(`<S-TSID><RS><LS tsi="1"><RprFlow><FECParameters fecOTI="zz"/></RprFlow></LS></RS></S-TSID>`))
+	assert.ErrorContains(t, err, "invalid S-TSID")
+}
@@ -0,0 +1,100 @@
+package atsc
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+)
+
+// BundleDescriptionROUTE is the User Service Bundle Description of a ROUTE
+// service.
+type BundleDescriptionROUTE struct {
+	UserServiceDescription []UserServiceDescription `xml:"UserServiceDescription"`
+}
+
+func (t *BundleDescriptionROUTE) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type T BundleDescriptionROUTE
+	start.Name = xml.Name{Space: NamespaceUSBD, Local: "BundleDescriptionROUTE"}
+	return e.EncodeElement((*T)(t), start)
+}
+
+// UserServiceDescription describes a service and the base patterns of the
+// resources delivered over broadcast and broadband.
+type UserServiceDescription struct {
+	GlobalServiceID string           `xml:"globalServiceID,attr"`
+	ServiceID       uint16           `xml:"serviceId,attr"`
+	ServiceStatus   *bool            `xml:"serviceStatus,attr,omitempty"` // true if nil
+	Name            []gsma.Name      `xml:"Name,omitempty"`
+	ServiceLanguage []string         `xml:"ServiceLanguage,omitempty"`
+	DeliveryMethod  []DeliveryMethod `xml:"DeliveryMethod,omitempty"`
+}
+
+// DeliveryMethod holds the base patterns of the resources delivered over
+// broadcast and broadband.
+type DeliveryMethod struct {
+	BroadcastAppService []AppService `xml:"BroadcastAppService"`
+	UnicastAppService   []AppService `xml:"UnicastAppService,omitempty"`
+}
+
+// AppService is the base patterns of the resources of a delivery.
+type AppService struct {
+	BasePattern []string `xml:"BasePattern"`
+}
+
+// NewUSBD returns the USBD of session s of service svc, a DeliveryMethod per
+// delivery method of broadcast base patterns.
+func NewUSBD(svc api.Service, s api.Session) (*BundleDescriptionROUTE, error) {
+	if svc.ID > 0xffff {
+		return nil, fmt.Errorf("service id %d exceeds 16 bits", svc.ID)
+	}
+	if svc.ServiceId == "" {
+		return nil, fmt.Errorf("no service identifier")
+	}
+	usd := UserServiceDescription{
+		GlobalServiceID: svc.ServiceId,
+		ServiceID:       uint16(svc.ID),
+		Name:            svc.Name,
+		ServiceLanguage: svc.Lang,
+	}
+	for _, d := range s.Delivery {
+		if len(d.BroadcastBasePattern) == 0 {
+			continue
+		}
+		dm := DeliveryMethod{BroadcastAppService: []AppService{{BasePattern: d.BroadcastBasePattern}}}
+		if len(d.UnicastBasePattern) > 0 {
+			dm.UnicastAppService = []AppService{{BasePattern: d.UnicastBasePattern}}
+		}
+		usd.DeliveryMethod = append(usd.DeliveryMethod, dm)
+	}
+	return &BundleDescriptionROUTE{UserServiceDescription: []UserServiceDescription{usd}}, nil
+}
+
+// basePatterns returns the broadcast and broadband base patterns of all the
+// delivery methods of usd.
+func (usd *UserServiceDescription) basePatterns() (broadcast, unicast []string) {
+	for _, dm := range usd.DeliveryMethod {
+		for _, a := range dm.BroadcastAppService {
+			broadcast = append(broadcast, a.BasePattern...)
+		}
+		for _, a := range dm.UnicastAppService {
+			unicast = append(unicast, a.BasePattern...)
+		}
+	}
+	return broadcast, unicast
+}
+
+// MarshalUSBD returns the XML document of b.
+func MarshalUSBD(b *BundleDescriptionROUTE) ([]byte, error) {
+	return marshal(b)
+}
+
+// UnmarshalUSBD parses the USBD XML document data.
+func UnmarshalUSBD(data []byte) (*BundleDescriptionROUTE, error) {
+	b := &BundleDescriptionROUTE{}
+	if err := unmarshal(data, b, "USBD"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -45,7 +45,12 @@ type DeliveryMethod struct {
 	//BufferSize int `json:"bufferSize" db:"bufferSize"`
 }
 
+// Key returns the key of the first endpoint of c, empty if it has none, see
+// Validate.
 func (c DeliveryMethod) Key() string {
+	if len(c.FEC) == 0 || len(c.FEC[0].Endpoint) == 0 {
+		return ""
+	}
 	return c.FEC[0].Endpoint[0].Key(true)
 }
 
@@ -347,10 +352,18 @@ func (s FECEncoding) String() string {
 	switch s {
 	case COM_NO_C_FEC_ENC_ID:
 		return "Compact-No-Code"
+	case RS_GEN_FEC_ENC_ID:
+		return "Reed-Solomon-GF(2^^m)"
 	case RS_GF8_FEC_ENC_ID:
 		return "Reed-Solomon-GF(2^^8)"
 	case RAPTORQ_FEC_ENC_ID:
 		return "RaptorQ"
+	case SB_LB_E_FEC_ENC_ID:
+		return "Small-Block-Large-Block-Expandable"
+	case SB_SYS_FEC_ENC_ID:
+		return "Small-Block-Systematic"
+	case COM_FEC_ENC_ID:
+		return "Compact"
 	default:
 		return "unknown"
 	}
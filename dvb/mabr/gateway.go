@@ -0,0 +1,388 @@
+package mabr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+)
+
+// Warning is a part of an imported document the API types cannot represent,
+// at Path, an XPath of the document, e.g.
+// "/MulticastGatewayConfiguration/MulticastSession[1]/@validUntil".
+type Warning struct {
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// Import is the result of importing a multicast session configuration.
+type Import struct {
+	Sessions []ServiceSession
+	// Options hold the document-wide values, those of the first transport
+	// session that sets them: exporting the sessions with them restores them.
+	Options  Options
+	Warnings []Warning
+}
+
+// UnmarshalGatewayConfiguration parses the MulticastGatewayConfiguration XML
+// document data and imports it, see ImportGatewayConfiguration.
+func UnmarshalGatewayConfiguration(data []byte) (*Import, error) {
+	c := &dvb.MulticastGatewayConfiguration{}
+	if err := xml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return ImportGatewayConfiguration(c)
+}
+
+// ImportGatewayConfiguration returns a live session per MulticastSession of c,
+// the reverse of NewServerConfiguration. Its transport sessions become
+// delivery methods: the session starts at the earliest of their start times, if
+// any has one, and each starts at its offset from it, for its duration. Their endpoints are
+// those of the first FEC parameters of the delivery method, which take the FEC
+// scheme and overhead of the in-band FEC parameters, if any, and the
+// out-of-band FEC parameters follow. Bit rates are converted from bit/s to
+// kbit/s, rounded up. The unicast repair base URL of the session is the first
+// of its first transport session, whose transportObjectReceptionTimeout is
+// the RepairWindow of the delivery method.
+//
+// The FEC symbol length and blocking, the store type and the service name are
+// not part of the document and are left to set. What the API types cannot
+// represent is listed in the warnings. An error is returned for a document
+// that is not valid, e.g. with an unknown transport protocol or address.
+func ImportGatewayConfiguration(c *dvb.MulticastGatewayConfiguration) (*Import, error) {
+	im := &importer{}
+	root := "/MulticastGatewayConfiguration"
+	if c.ValidityPeriod != "" {
+		d, err := parseXSDuration(c.ValidityPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("validityPeriod: %w", err)
+		}
+		im.Options.ValidityPeriod = d
+	}
+	if c.ValidUntil != "" {
+		im.warn(root+"/@validUntil", "validity end %s dropped", c.ValidUntil)
+	}
+	for i := range c.MulticastGatewayConfigurationTransportSession {
+		im.warn(fmt.Sprintf("%s/MulticastGatewayConfigurationTransportSession[%d]", root, i+1), "gateway configuration transport session dropped")
+	}
+	for i, ms := range c.MulticastSession {
+		s, err := im.session(fmt.Sprintf("%s/MulticastSession[%d]", root, i+1), ms)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", ms.ServiceIdentifier, err)
+		}
+		im.Sessions = append(im.Sessions, s)
+	}
+	return &im.Import, nil
+}
+
+type importer struct {
+	Import
+	idleTimeout, fixedBackOff, randomBackOff, protocolVersion setting
+}
+
+// setting is a document-wide option set by the first transport session.
+type setting struct {
+	value int
+	set   bool
+}
+
+// merge sets s to v, and warns at path when s is already set to another value.
+func (im *importer) merge(s *setting, path string, v int, unit string) int {
+	if !s.set {
+		s.value, s.set = v, true
+	} else if s.value != v {
+		im.warn(path, "%d%s differs from %d%s of the first transport session, dropped", v, unit, s.value, unit)
+	}
+	return s.value
+}
+
+func (im *importer) warn(path, format string, args ...any) {
+	im.Warnings = append(im.Warnings, Warning{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (im *importer) session(path string, ms dvb.MulticastSessionType) (ServiceSession, error) {
+	ss := ServiceSession{
+		Service: api.Service{ServiceId: ms.ServiceIdentifier},
+		Session: api.Session{Type: api.Live, PresentationManifestLocator: ms.PresentationManifestLocator},
+	}
+	if ms.ServiceIdentifier == "" {
+		return ss, fmt.Errorf("no service identifier")
+	}
+	if len(ms.MulticastTransportSession) == 0 {
+		return ss, fmt.Errorf("no transport session")
+	}
+	if ms.MulticastGatewaySessionReporting != nil {
+		im.warn(path+"/MulticastGatewaySessionReporting", "%d reporting locators dropped", len(ms.MulticastGatewaySessionReporting.ReportingLocator))
+	}
+	if ms.ContentPlaybackAvailabilityOffset != "" {
+		im.warn(path+"/@contentPlaybackAvailabilityOffset", "offset %s dropped", ms.ContentPlaybackAvailabilityOffset)
+	}
+
+	starts := make([]time.Time, len(ms.MulticastTransportSession))
+	var first time.Time
+	for i, ts := range ms.MulticastTransportSession {
+		if ts.Start == "" {
+			continue
+		}
+		t, err := parseTimePoint(ts.Start)
+		if err != nil {
+			return ss, fmt.Errorf("transport session %d: %w", i+1, err)
+		}
+		starts[i] = t
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+	if first.IsZero() {
+		im.warn(path, "no transport session has a start, the session has none")
+	}
+	ss.Session.Reoccurrences.Dtstart = api.TimeZ(first)
+
+	var end time.Time
+	ends := true // the session only ends if all its transport sessions do
+	for i, ts := range ms.MulticastTransportSession {
+		tsPath := fmt.Sprintf("%s/MulticastTransportSession[%d]", path, i+1)
+		d, err := im.delivery(tsPath, &ss, ts)
+		if err != nil {
+			return ss, fmt.Errorf("transport session %d: %w", i+1, err)
+		}
+		switch {
+		case !starts[i].IsZero():
+			d.StartOffset = api.Duration(starts[i].Sub(first))
+		case !first.IsZero():
+			im.warn(tsPath, "no start, starts with the session at %s", api.TimeZ(first))
+		}
+		if d.Duration == nil || starts[i].IsZero() {
+			ends = false
+		} else if e := starts[i].Add(time.Duration(*d.Duration)); e.After(end) {
+			end = e
+		}
+		ss.Session.Delivery = append(ss.Session.Delivery, d)
+	}
+	if ends {
+		ss.Session.Reoccurrences.Dtend = api.TimeZ(end)
+	}
+	return ss, nil
+}
+
+// delivery returns the delivery method of transport session ts of session ss,
+// and sets the service and repair parameters of ss from the first one.
+func (im *importer) delivery(path string, ss *ServiceSession, ts dvb.MulticastTransportSessionType) (api.DeliveryMethod, error) {
+	var d api.DeliveryMethod
+	first := len(ss.Session.Delivery) == 0
+
+	switch m := api.ContentAcquisitionMethodType(ts.ContentIngestMethod); m {
+	case "", api.Pull, api.Push:
+		d.ContentIngestMethod = m
+	default:
+		im.warn(path+"/@contentIngestMethod", "unsupported content ingest method %q dropped", m)
+	}
+
+	protocol, err := transportProtocol(ts.TransportProtocol.ProtocolIdentifier)
+	if err != nil {
+		return d, err
+	}
+	security := api.TransportSecurityType(ts.TransportSecurity)
+	if security == "none" {
+		security = ""
+	}
+	if first {
+		ss.Service.TransportProtocol = protocol
+		ss.Service.TransportSecurity = security
+	} else {
+		if protocol != ss.Service.TransportProtocol {
+			im.warn(path+"/TransportProtocol", "%s differs from %s of the service, dropped", protocol, ss.Service.TransportProtocol)
+		}
+		if security != ss.Service.TransportSecurity {
+			im.warn(path+"/@transportSecurity", "%q differs from %q of the service, dropped", ts.TransportSecurity, ss.Service.TransportSecurity)
+		}
+	}
+	im.Options.ProtocolVersion = im.merge(&im.protocolVersion, path+"/TransportProtocol/@protocolVersion", ts.TransportProtocol.ProtocolVersion, "")
+	if ts.SessionIdleTimeout > 0 {
+		ms := im.merge(&im.idleTimeout, path+"/@sessionIdleTimeout", ts.SessionIdleTimeout, "ms")
+		im.Options.SessionIdleTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	switch ts.TransmissionMode {
+	case "chunked":
+		d.TransmissionMode = api.Chunked
+	case "resource":
+		d.TransmissionMode = api.File
+	case "":
+	default:
+		im.warn(path+"/@transmissionMode", "unsupported transmission mode %q dropped", ts.TransmissionMode)
+	}
+	if ts.Duration != "" {
+		dur, err := parseXSDuration(ts.Duration)
+		if err != nil {
+			return d, err
+		}
+		duration := api.Duration(dur)
+		d.Duration = &duration
+	}
+
+	d.BitrateKbps = api.BitRateType{Average: kbps(ts.BitRate.Average), Maximum: kbps(ts.BitRate.Maximum)}
+	if ts.BitRate.Average%1000 != 0 || ts.BitRate.Maximum%1000 != 0 {
+		im.warn(path+"/BitRate", "%d/%d bit/s rounded up to %d/%d kbit/s", ts.BitRate.Average, ts.BitRate.Maximum, d.BitrateKbps.Average, d.BitrateKbps.Maximum)
+	}
+
+	eps, err := importEndpoints(ts.EndpointAddress)
+	if err != nil {
+		return d, err
+	}
+	d.FEC = api.FECParamsType{{Encoding: api.COM_NO_C_FEC_ENC_ID, Endpoint: eps}}
+	inBand := false
+	for j, p := range ts.ForwardErrorCorrectionParameters {
+		pPath := fmt.Sprintf("%s/ForwardErrorCorrectionParameters[%d]", path, j+1)
+		enc, ok := fecEncoding(p.SchemeIdentifier)
+		if !ok {
+			im.warn(pPath, "unsupported FEC scheme %s dropped", p.SchemeIdentifier)
+			continue
+		}
+		redundancy := float64(p.OverheadPercentage) / 100
+		if len(p.EndpointAddress) == 0 {
+			if inBand {
+				im.warn(pPath, "second in-band FEC scheme %s dropped", p.SchemeIdentifier)
+				continue
+			}
+			inBand = true
+			d.FEC[0].Encoding, d.FEC[0].Redundancy = enc, redundancy
+			continue
+		}
+		eps, err := importEndpoints(p.EndpointAddress)
+		if err != nil {
+			return d, fmt.Errorf("fec %d: %w", j, err)
+		}
+		d.FEC = append(d.FEC, api.FECParamType{Encoding: enc, Redundancy: redundancy, Endpoint: eps})
+	}
+
+	if r := ts.UnicastRepairParameters; r != nil {
+		d.RepairWindow = api.Duration(time.Duration(r.TransportObjectReceptionTimeout) * time.Millisecond)
+		im.repair(path+"/UnicastRepairParameters", ss, r)
+	} else if ss.Session.RprHost != "" {
+		im.warn(path, "no unicast repair, the repair base URL of the session applies")
+	}
+
+	for k, c := range ts.ServiceComponentIdentifier {
+		switch {
+		case c.DASHComponentIdentifierType != nil:
+			d.DASHComponent = append(d.DASHComponent, *c.DASHComponentIdentifierType)
+		case c.HLSComponentIdentifierType != nil:
+			d.HLSComponent = append(d.HLSComponent, *c.HLSComponentIdentifierType)
+		default:
+			im.warn(fmt.Sprintf("%s/ServiceComponentIdentifier[%d]", path, k+1), "service component neither DASH nor HLS dropped")
+		}
+	}
+	return d, nil
+}
+
+// repair sets the repair base URL of ss from the first transport session with
+// unicast repair parameters r, and warns about those it cannot keep.
+func (im *importer) repair(path string, ss *ServiceSession, r *dvb.UnicastRepairParametersType) {
+	fixed := im.merge(&im.fixedBackOff, path+"/@fixedBackOffPeriod", int(r.FixedBackOffPeriod), "ms")
+	im.Options.FixedBackOff = time.Duration(fixed) * time.Millisecond
+	random := im.merge(&im.randomBackOff, path+"/@randomBackOffPeriod", int(r.RandomBackOffPeriod), "ms")
+	im.Options.RandomBackOff = time.Duration(random) * time.Millisecond
+	if r.TransportObjectBaseURI != "" {
+		im.warn(path+"/@transportObjectBaseURI", "base URI %s dropped", r.TransportObjectBaseURI)
+	}
+	for k, b := range r.BaseURL {
+		bPath := fmt.Sprintf("%s/BaseURL[%d]", path, k+1)
+		if k > 0 {
+			im.warn(bPath, "alternative base URL %s, weight %d, dropped", b.Value, b.RelativeWeight)
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(b.Value))
+		if err != nil || u.Host == "" {
+			im.warn(bPath, "invalid base URL %s dropped", b.Value)
+			continue
+		}
+		host := u.Scheme + "://" + u.Host
+		switch {
+		case ss.Session.RprHost == "":
+			ss.Session.RprHost, ss.Session.RprUnicastPath = host, u.Path
+			if u.RawQuery != "" || u.Fragment != "" {
+				im.warn(bPath, "query and fragment of %s dropped", b.Value)
+			}
+		case host != ss.Session.RprHost || u.Path != ss.Session.RprUnicastPath:
+			im.warn(bPath, "base URL %s differs from %s of the session, dropped", b.Value, ss.Session.RprHost+ss.Session.RprUnicastPath)
+		}
+	}
+}
+
+// transportProtocol returns the transport protocol of MulticastTransportProtocolCS term t.
+func transportProtocol(t dvb.TermReferenceType) (api.TransportProtocolType, error) {
+	switch t {
+	case term(dvb.FLUTE):
+		return api.FLUTE, nil
+	case term(dvb.ROUTE):
+		return api.ROUTE, nil
+	}
+	return "", fmt.Errorf("unsupported transport protocol %q", t)
+}
+
+// fecEncoding returns the FEC encoding ID of ForwardErrorCorrectionSchemeCS
+// term t, if it is one of the API.
+func fecEncoding(t dvb.TermReferenceType) (api.FECEncoding, bool) {
+	id, ok := strings.CutPrefix(string(t), fecScheme+":")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(id, 10, 8)
+	if err != nil {
+		return 0, false
+	}
+	e := api.FECEncoding(n)
+	encodings, _ := e.NamedEnum()
+	for _, x := range encodings {
+		if x == e {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// kbps converts bit/s to kbit/s, rounded up.
+func kbps(bps int) int {
+	return (bps + 999) / 1000
+}
+
+// importEndpoints returns the endpoints of eps, the reverse of endpoints.
+func importEndpoints(eps []dvb.MulticastEndpointAddressType) (api.MulticastEndpointAddressesType, error) {
+	out := make(api.MulticastEndpointAddressesType, 0, len(eps))
+	for _, a := range eps {
+		var ep api.MulticastEndpointAddressType
+		var err error
+		if a.NetworkSourceAddress != "" {
+			if ep.Source, err = netip.ParseAddr(strings.TrimSpace(a.NetworkSourceAddress)); err != nil {
+				return nil, fmt.Errorf("invalid source address: %w", err)
+			}
+		}
+		if ep.Group, err = netip.ParseAddr(strings.TrimSpace(a.NetworkDestinationGroupAddress)); err != nil {
+			return nil, fmt.Errorf("invalid group address: %w", err)
+		}
+		if a.TransportDestinationPort <= 0 || a.TransportDestinationPort > 0xffff {
+			return nil, fmt.Errorf("invalid port %d", a.TransportDestinationPort)
+		}
+		ep.DestPort = uint16(a.TransportDestinationPort)
+		if a.MediaTransportSessionIdentfier != nil {
+			if *a.MediaTransportSessionIdentfier < 0 {
+				return nil, fmt.Errorf("invalid tsi %d", *a.MediaTransportSessionIdentfier)
+			}
+			tsi := uint64(*a.MediaTransportSessionIdentfier)
+			ep.TSI = &tsi
+		}
+		out = append(out, ep)
+	}
+	return out, nil
+}
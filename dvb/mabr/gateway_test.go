@@ -0,0 +1,202 @@
+package mabr_test
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/dvb/mabr"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func warningPaths(ws []mabr.Warning) []string {
+	paths := make([]string, len(ws))
+	for i, w := range ws {
+		paths[i] = w.Path
+	}
+	return paths
+}
+
+func TestImportGatewayConfiguration(t *testing.T) {
+	data, err := os.ReadFile("../models/gateway_config_example.xml")
+	require.NoError(t, err)
+	im, err := mabr.UnmarshalGatewayConfiguration(data)
+	require.NoError(t, err)
+	require.Len(t, im.Sessions, 2)
+
+	assert.Equal(t, mabr.Options{
+		ProtocolVersion:    1,
+		SessionIdleTimeout: 3840 * time.Millisecond,
+		FixedBackOff:       10 * time.Millisecond,
+		RandomBackOff:      20 * time.Millisecond,
+	}, im.Options)
+
+	one := im.Sessions[0]
+	assert.Equal(t, api.Service{
+		ServiceId:         "tag:bbc.co.uk;2019#bbc-one/scotland",
+		TransportProtocol: api.FLUTE,
+		TransportSecurity: api.IntegrityAuthenticity,
+	}, one.Service)
+	s := one.Session
+	assert.Equal(t, api.Live, s.Type)
+	assert.Len(t, s.PresentationManifestLocator, 2)
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, start.Equal(time.Time(s.Reoccurrences.Dtstart)))
+	assert.True(t, time.Time(s.Reoccurrences.Dtend).IsZero(), "vision-low and sound have no duration")
+	assert.Equal(t, "http://bbc.cdn1.com", s.RprHost)
+	assert.Equal(t, "/bbc-one_scotland/vision-low", s.RprUnicastPath)
+	require.Len(t, s.Delivery, 3)
+
+	d := s.Delivery[0]
+	assert.Equal(t, api.Chunked, d.TransmissionMode)
+	assert.Equal(t, api.BitRateType{Average: 150, Maximum: 150}, d.BitrateKbps)
+	assert.Equal(t, api.Duration(500*time.Millisecond), d.RepairWindow)
+	assert.Zero(t, d.StartOffset)
+	assert.Nil(t, d.Duration)
+	require.Len(t, d.FEC, 1)
+	assert.Equal(t, api.COM_NO_C_FEC_ENC_ID, d.FEC[0].Encoding, "Raptor is not supported")
+	require.Len(t, d.FEC[0].Endpoint, 1)
+	ep := d.FEC[0].Endpoint[0]
+	assert.Equal(t, netip.MustParseAddr("10.1.100.1"), ep.Source)
+	assert.Equal(t, netip.MustParseAddr("232.100.1.1"), ep.Group)
+	assert.Equal(t, uint16(3922), ep.DestPort)
+	require.NotNil(t, ep.TSI)
+	assert.Equal(t, uint64(1), *ep.TSI)
+	require.Len(t, d.DASHComponent, 1)
+	assert.Equal(t, "V1", string(d.DASHComponent[0].RepresentationIdentifier))
+	require.Len(t, d.HLSComponent, 1)
+	assert.Equal(t, "http://media.bbc.co.uk/simulcast/bbc-one/scotland/V1.m3u8", d.HLSComponent[0].MediaPlaylistLocator)
+
+	d = s.Delivery[1]
+	assert.Equal(t, api.Duration(time.Date(2019, 5, 3, 20, 0, 0, 0, time.UTC).Sub(start)), d.StartOffset)
+	require.NotNil(t, d.Duration)
+	assert.Equal(t, api.Duration(2*time.Hour), *d.Duration)
+
+	two := im.Sessions[1]
+	assert.Equal(t, api.ROUTE, two.Service.TransportProtocol)
+	d = two.Session.Delivery[0]
+	assert.Equal(t, api.File, d.TransmissionMode)
+	require.Len(t, d.FEC, 2)
+	assert.Equal(t, api.COM_NO_C_FEC_ENC_ID, d.FEC[0].Encoding)
+	assert.Equal(t, api.RAPTORQ_FEC_ENC_ID, d.FEC[1].Encoding)
+	assert.InDelta(t, 0.2, d.FEC[1].Redundancy, 1e-9)
+	require.Len(t, d.FEC[1].Endpoint, 1)
+	assert.Equal(t, netip.MustParseAddr("232.200.2.1"), d.FEC[1].Endpoint[0].Group)
+	assert.Equal(t, uint64(26), *d.FEC[1].Endpoint[0].TSI)
+
+	paths := warningPaths(im.Warnings)
+	ms1 := "/MulticastGatewayConfiguration/MulticastSession[1]"
+	for _, p := range []string{
+		"/MulticastGatewayConfiguration/@validUntil",
+		ms1 + "/MulticastGatewaySessionReporting",
+		ms1 + "/@contentPlaybackAvailabilityOffset",
+		ms1 + "/MulticastTransportSession[1]/ForwardErrorCorrectionParameters[1]",
+		ms1 + "/MulticastTransportSession[1]/UnicastRepairParameters/BaseURL[2]",
+		ms1 + "/MulticastTransportSession[2]/UnicastRepairParameters/BaseURL[1]",
+	} {
+		assert.Contains(t, paths, p)
+	}
+	assert.NotContains(t, paths, ms1+"/MulticastTransportSession[1]/@id", "the id is derived on export")
+	assert.NotContains(t, paths, ms1+"/MulticastTransportSession[2]/@sessionIdleTimeout", "same timeout")
+	assert.NotContains(t, paths, "/MulticastGatewayConfiguration/MulticastSession[2]/MulticastGatewaySessionReporting")
+}
+
+func TestImportGatewayConfigurationRoundTrip(t *testing.T) {
+	svc, s := testSession()
+	o := mabr.Options{ProtocolVersion: 1, SessionIdleTimeout: 5 * time.Second, FixedBackOff: 10 * time.Millisecond, ValidityPeriod: time.Hour}
+	c, err := mabr.NewServerConfiguration(&o, mabr.ServiceSession{Service: svc, Session: s})
+	require.NoError(t, err)
+	data, err := mabr.MarshalServerConfiguration(c)
+	require.NoError(t, err)
+
+	im, err := mabr.UnmarshalGatewayConfiguration(data)
+	require.NoError(t, err)
+	assert.Equal(t, o, im.Options)
+	assert.Empty(t, im.Warnings)
+	require.Len(t, im.Sessions, 1)
+	assert.Equal(t, svc, im.Sessions[0].Service)
+
+	got := im.Sessions[0].Session
+	assert.Equal(t, "https://cdn.example.com", got.RprHost)
+	assert.Equal(t, s.RprUnicastPath, got.RprUnicastPath)
+	assert.Equal(t, s.PresentationManifestLocator, got.PresentationManifestLocator)
+	start := time.Time(s.Reoccurrences.Dtstart).Add(time.Duration(s.Delivery[0].StartOffset))
+	assert.True(t, start.Equal(time.Time(got.Reoccurrences.Dtstart)))
+	assert.True(t, start.Add(2*time.Hour).Equal(time.Time(got.Reoccurrences.Dtend)))
+
+	want := s.Delivery[0]
+	want.StartOffset = 0
+	require.Len(t, got.Delivery, 1)
+	assert.Equal(t, want, got.Delivery[0])
+}
+
+func TestImportGatewayConfigurationWarnings(t *testing.T) {
+	ts := dvb.MulticastTransportSessionType{
+		TransportProtocol: dvb.MulticastTransportProtocolType{ProtocolIdentifier: "urn:dvb:metadata:cs:MulticastTransportProtocolCS:2019:FLUTE", ProtocolVersion: 1},
+		EndpointAddress: []dvb.MulticastEndpointAddressType{{
+			NetworkSourceAddress:           "10.0.0.1",
+			NetworkDestinationGroupAddress: "232.1.1.1",
+			TransportDestinationPort:       3000,
+		}},
+		BitRate:             dvb.BitRateType{Maximum: 1000000},
+		ContentIngestMethod: "carrier-pigeon",
+		TransmissionMode:    "streaming",
+		ServiceComponentIdentifier: []dvb.ServiceComponentIdentifierType{
+			{DASHComponentIdentifierType: &dvb.DASHComponentIdentifierType{RepresentationIdentifier: "V1"}},
+			{},
+		},
+	}
+	im, err := mabr.ImportGatewayConfiguration(&dvb.MulticastGatewayConfiguration{
+		MulticastSession: []dvb.MulticastSessionType{{
+			ServiceIdentifier:         "svc",
+			MulticastTransportSession: []dvb.MulticastTransportSessionType{ts},
+		}},
+	})
+	require.NoError(t, err)
+	s := im.Sessions[0].Session
+	assert.True(t, time.Time(s.Reoccurrences.Dtstart).IsZero())
+	d := s.Delivery[0]
+	assert.Empty(t, d.ContentIngestMethod)
+	assert.Empty(t, d.TransmissionMode)
+	assert.Len(t, d.DASHComponent, 1)
+
+	ms := "/MulticastGatewayConfiguration/MulticastSession[1]"
+	assert.ElementsMatch(t, []string{
+		ms,
+		ms + "/MulticastTransportSession[1]/@contentIngestMethod",
+		ms + "/MulticastTransportSession[1]/@transmissionMode",
+		ms + "/MulticastTransportSession[1]/ServiceComponentIdentifier[2]",
+	}, warningPaths(im.Warnings))
+}
+
+func TestImportGatewayConfigurationErrors(t *testing.T) {
+	const doc = `<MulticastGatewayConfiguration xmlns="urn:dvb:metadata:MulticastSessionConfiguration:2019">
+ <MulticastSession serviceIdentifier="svc">
+  <MulticastTransportSession id="ts" sessionIdleTimeout="1000">
+   <TransportProtocol protocolIdentifier="%s" protocolVersion="1"/>
+   <EndpointAddress>
+    <NetworkSourceAddress>10.0.0.1</NetworkSourceAddress>
+    <NetworkDestinationGroupAddress>%s</NetworkDestinationGroupAddress>
+    <TransportDestinationPort>3000</TransportDestinationPort>
+   </EndpointAddress>
+   <BitRate maximum="1500"/>
+  </MulticastTransportSession>
+ </MulticastSession>
+</MulticastGatewayConfiguration>`
+	const flute = "urn:dvb:metadata:cs:MulticastTransportProtocolCS:2019:FLUTE"
+
+	im, err := mabr.UnmarshalGatewayConfiguration([]byte(fmt.Sprintf(doc, flute, "232.1.1.1")))
+	require.NoError(t, err)
+	assert.Equal(t, api.BitRateType{Average: 0, Maximum: 2}, im.Sessions[0].Session.Delivery[0].BitrateKbps)
+	assert.Contains(t, warningPaths(im.Warnings), "/MulticastGatewayConfiguration/MulticastSession[1]/MulticastTransportSession[1]/BitRate")
+
+	_, err = mabr.UnmarshalGatewayConfiguration([]byte(fmt.Sprintf(doc, "urn:example:RTP", "232.1.1.1")))
+	assert.ErrorContains(t, err, "unsupported transport protocol")
+	_, err = mabr.UnmarshalGatewayConfiguration([]byte(fmt.Sprintf(doc, flute, "232.1.1")))
+	assert.ErrorContains(t, err, "invalid group address")
+}
@@ -0,0 +1,169 @@
+// Package mabr converts the sessions of this API to and from the DVB-MABR
+// multicast session configuration of ETSI TS 103 769, MulticastSessionConfig.xsd.
+package mabr
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+)
+
+const (
+	DefaultSessionIdleTimeout = 10 * time.Second
+	DefaultReceptionTimeout   = 500 * time.Millisecond
+)
+
+// Options set the configuration the API types do not carry. The zero value
+// uses the defaults.
+type Options struct {
+	ProtocolVersion    int           // of the transport protocol, 1 if 0
+	SessionIdleTimeout time.Duration // sessionIdleTimeout, DefaultSessionIdleTimeout if 0
+	// transportObjectReceptionTimeout, the RepairWindow of the delivery method
+	// if 0, DefaultReceptionTimeout if that is 0 too
+	ReceptionTimeout time.Duration
+	FixedBackOff     time.Duration // fixedBackOffPeriod of unicast repair
+	RandomBackOff    time.Duration // randomBackOffPeriod of unicast repair
+	ValidityPeriod   time.Duration // validityPeriod of the document, omitted if 0
+}
+
+func (o *Options) orDefault() *Options {
+	if o == nil {
+		o = &Options{}
+	}
+	c := *o
+	if c.ProtocolVersion == 0 {
+		c.ProtocolVersion = 1
+	}
+	if c.SessionIdleTimeout == 0 {
+		c.SessionIdleTimeout = DefaultSessionIdleTimeout
+	}
+	return &c
+}
+
+// term returns the term reference of the term of classification scheme cs.
+func term(cs dvb.ClassificationSchemeType) dvb.TermReferenceType {
+	return dvb.TermReferenceType(cs.Uri + ":" + cs.Term[0].TermID)
+}
+
+// protocolTerm returns the MulticastTransportProtocolCS term of p.
+func protocolTerm(p api.TransportProtocolType) (dvb.TermReferenceType, error) {
+	switch p {
+	case api.FLUTE:
+		return term(dvb.FLUTE), nil
+	case api.ROUTE:
+		return term(dvb.ROUTE), nil
+	}
+	return "", fmt.Errorf("unsupported transport protocol %q", p)
+}
+
+// fecScheme is the URN of the FEC encoding IDs, ForwardErrorCorrectionSchemeCS.
+var fecScheme = dvb.RAPTORQ.Uri
+
+// fecTerm returns the ForwardErrorCorrectionSchemeCS term of FEC encoding ID e.
+func fecTerm(e api.FECEncoding) dvb.TermReferenceType {
+	return dvb.TermReferenceType(fecScheme + ":" + strconv.Itoa(int(e)))
+}
+
+// xsDuration formats d as an xs:duration, e.g. PT1H30M or PT3.84S.
+func xsDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+	h, m := d/time.Hour, d%time.Hour/time.Minute
+	if h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if d %= time.Minute; d > 0 || h == 0 && m == 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// repairURL returns the unicast repair base URL of host and path, https if
+// host has no scheme.
+func repairURL(host, path string) (string, error) {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid repair host %q: %w", host, err)
+	}
+	return u.JoinPath(path).String(), nil
+}
+
+// parseXSDuration parses an xs:duration of days, hours, minutes and seconds,
+// e.g. PT1H30M or P1DT3.84S. Years and months have no fixed length.
+func parseXSDuration(s string) (time.Duration, error) {
+	in := s
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if !strings.HasPrefix(s, "P") || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", in)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return 0, fmt.Errorf("invalid duration %q", in)
+			}
+			inTime, s = true, s[1:]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", in)
+		}
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", in, err)
+		}
+		var unit time.Duration
+		switch {
+		case s[i] == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case s[i] == 'H' && inTime:
+			unit = time.Hour
+		case s[i] == 'M' && inTime:
+			unit = time.Minute
+		case s[i] == 'S' && inTime:
+			unit = time.Second
+		case s[i] == 'Y' || s[i] == 'M':
+			return 0, fmt.Errorf("duration %q has years or months", in)
+		default:
+			return 0, fmt.Errorf("invalid duration %q", in)
+		}
+		d += time.Duration(n * float64(unit))
+		s = s[i+1:]
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// timePointLayouts are those of xs:dateTime, UTC when without time zone.
+var timePointLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"}
+
+// parseTimePoint parses the xs:dateTime t.
+func parseTimePoint(t dvb.TimePointType) (time.Time, error) {
+	for _, layout := range timePointLayouts {
+		if tp, err := time.Parse(layout, string(t)); err == nil {
+			return tp, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time point %q", t)
+}
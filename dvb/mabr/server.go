@@ -0,0 +1,225 @@
+package mabr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+)
+
+// ServiceSession is a session and the service it delivers.
+type ServiceSession struct {
+	Service api.Service
+	Session api.Session
+}
+
+// ServerConfiguration is a MulticastServerConfiguration whose transport
+// sessions carry their id attribute, which the generated types lack.
+type ServerConfiguration struct {
+	dvb.MulticastServerConfiguration
+	MulticastSession []MulticastSession `xml:"urn:dvb:metadata:MulticastSessionConfiguration:2019 MulticastSession,omitempty" json:"MulticastSession,omitempty"`
+}
+
+// MulticastSession is a MulticastSessionType whose transport sessions carry
+// their id attribute.
+type MulticastSession struct {
+	dvb.MulticastSessionType
+	MulticastTransportSession []TransportSession `xml:"urn:dvb:metadata:MulticastSessionConfiguration:2019 MulticastTransportSession" json:"MulticastTransportSession"`
+}
+
+// TransportSession is a MulticastTransportSessionType and its required id
+// attribute.
+type TransportSession struct {
+	dvb.MulticastTransportSessionType
+	ID string `xml:"id,attr" json:"id"`
+}
+
+// NewServerConfiguration returns the MulticastServerConfiguration of the
+// sessions, a MulticastSession each.
+func NewServerConfiguration(o *Options, sessions ...ServiceSession) (*ServerConfiguration, error) {
+	o = o.orDefault()
+	c := &ServerConfiguration{}
+	if o.ValidityPeriod > 0 {
+		c.ValidityPeriod = xsDuration(o.ValidityPeriod)
+	}
+	for _, s := range sessions {
+		ms, err := NewMulticastSession(s.Service, s.Session, o)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", s.Service.ServiceId, err)
+		}
+		c.MulticastSession = append(c.MulticastSession, ms)
+	}
+	return c, nil
+}
+
+// NewMulticastSession returns the MulticastSession of session s of service
+// svc, a MulticastTransportSession per delivery method.
+func NewMulticastSession(svc api.Service, s api.Session, o *Options) (MulticastSession, error) {
+	o = o.orDefault()
+	ms := MulticastSession{MulticastSessionType: dvb.MulticastSessionType{
+		ServiceIdentifier:           svc.ServiceId,
+		PresentationManifestLocator: s.PresentationManifestLocator,
+	}}
+	if ms.ServiceIdentifier == "" {
+		return ms, fmt.Errorf("no service identifier")
+	}
+	if len(s.Delivery) == 0 {
+		return ms, fmt.Errorf("session %d has no delivery method", s.ID)
+	}
+	for i := range s.Delivery {
+		ts, err := NewTransportSession(svc, s, i, o)
+		if err != nil {
+			return ms, fmt.Errorf("stream %d: %w", i, err)
+		}
+		ms.MulticastTransportSession = append(ms.MulticastTransportSession, ts)
+	}
+	return ms, nil
+}
+
+// NewTransportSession returns the MulticastTransportSession of delivery method
+// i of session s. The endpoints of its first FEC parameters are those of the
+// transport session, which carries their repair symbols in band, and those of
+// the others carry their repair symbols out of band. Bit rates are converted
+// from kbit/s to bit/s.
+func NewTransportSession(svc api.Service, s api.Session, i int, o *Options) (TransportSession, error) {
+	o = o.orDefault()
+	d := s.Delivery[i]
+	ts := TransportSession{ID: fmt.Sprintf("session%d-stream%d", s.ID, i)}
+	ts.MulticastTransportSessionType = dvb.MulticastTransportSessionType{
+		ContentIngestMethod: dvb.ContentAcquisitionMethodType(d.ContentIngestMethod),
+		TransmissionMode:    transmissionMode(d.TransmissionMode),
+		SessionIdleTimeout:  int(o.SessionIdleTimeout.Milliseconds()),
+		TransportSecurity:   dvb.TransportSecurityType(svc.TransportSecurity),
+	}
+	protocol, err := protocolTerm(svc.TransportProtocol)
+	if err != nil {
+		return ts, err
+	}
+	ts.TransportProtocol = dvb.MulticastTransportProtocolType{ProtocolIdentifier: protocol, ProtocolVersion: o.ProtocolVersion}
+
+	if len(d.FEC) == 0 || len(d.FEC[0].Endpoint) == 0 {
+		return ts, fmt.Errorf("no endpoint")
+	}
+	if ts.EndpointAddress, err = endpoints(d.FEC[0].Endpoint); err != nil {
+		return ts, err
+	}
+	if d.BitrateKbps.Maximum <= 0 {
+		return ts, fmt.Errorf("no maximum bit rate")
+	}
+	ts.BitRate = dvb.BitRateType{Average: max(d.BitrateKbps.Average, 0) * 1000, Maximum: d.BitrateKbps.Maximum * 1000}
+	for j, f := range d.FEC {
+		if f.Encoding == api.COM_NO_C_FEC_ENC_ID || f.Redundancy <= 0 {
+			continue
+		}
+		p := dvb.ForwardErrorCorrectionParametersType{
+			SchemeIdentifier:   fecTerm(f.Encoding),
+			OverheadPercentage: max(int(f.Redundancy*100+0.5), 1),
+		}
+		if j > 0 {
+			if p.EndpointAddress, err = endpoints(f.Endpoint); err != nil {
+				return ts, fmt.Errorf("fec %d: %w", j, err)
+			}
+		}
+		ts.ForwardErrorCorrectionParameters = append(ts.ForwardErrorCorrectionParameters, p)
+	}
+
+	if s.RprHost != "" {
+		base, err := repairURL(s.RprHost, s.RprUnicastPath)
+		if err != nil {
+			return ts, err
+		}
+		timeout := o.ReceptionTimeout
+		if timeout == 0 {
+			timeout = time.Duration(d.RepairWindow)
+		}
+		if timeout == 0 {
+			timeout = DefaultReceptionTimeout
+		}
+		ts.UnicastRepairParameters = &dvb.UnicastRepairParametersType{
+			BaseURL:                         []dvb.WeightedURIType{{Value: base}},
+			TransportObjectReceptionTimeout: uint(timeout.Milliseconds()),
+			FixedBackOffPeriod:              uint(o.FixedBackOff.Milliseconds()),
+			RandomBackOffPeriod:             uint(o.RandomBackOff.Milliseconds()),
+		}
+	}
+
+	for _, c := range d.DASHComponent {
+		ts.ServiceComponentIdentifier = append(ts.ServiceComponentIdentifier, dvb.ServiceComponentIdentifierType{DASHComponentIdentifierType: &c})
+	}
+	for _, c := range d.HLSComponent {
+		ts.ServiceComponentIdentifier = append(ts.ServiceComponentIdentifier, dvb.ServiceComponentIdentifierType{HLSComponentIdentifierType: &c})
+	}
+	if len(ts.ServiceComponentIdentifier) == 0 {
+		return ts, fmt.Errorf("no DASH or HLS component")
+	}
+
+	if start := time.Time(s.Reoccurrences.Dtstart); !start.IsZero() {
+		ts.Start = dvb.TimePointType(api.TimeZ(start.Add(time.Duration(d.StartOffset))).String())
+	}
+	if d.Duration != nil && *d.Duration > 0 {
+		ts.Duration = xsDuration(time.Duration(*d.Duration))
+	}
+	return ts, nil
+}
+
+// transmissionMode maps the transmission mode to resource or chunked.
+func transmissionMode(m api.TransmissionModeType) dvb.TransmissionModeType {
+	switch m {
+	case "":
+		return ""
+	case api.Chunked:
+		return "chunked"
+	}
+	return "resource"
+}
+
+// endpoints returns the endpoint addresses of eps. DVB-MABR requires a source
+// address, i.e. source-specific multicast.
+func endpoints(eps api.MulticastEndpointAddressesType) ([]dvb.MulticastEndpointAddressType, error) {
+	out := make([]dvb.MulticastEndpointAddressType, 0, len(eps))
+	for _, ep := range eps {
+		if !ep.Source.IsValid() || ep.Source.IsUnspecified() {
+			return nil, fmt.Errorf("endpoint %s has no source address", ep.Group)
+		}
+		if !ep.Group.IsValid() || ep.DestPort == 0 {
+			return nil, fmt.Errorf("invalid endpoint %s port %d", ep.Group, ep.DestPort)
+		}
+		a := dvb.MulticastEndpointAddressType{
+			NetworkSourceAddress:           ep.Source.String(),
+			NetworkDestinationGroupAddress: ep.Group.String(),
+			TransportDestinationPort:       int(ep.DestPort),
+		}
+		// MediaTransportSessionIdentfier is a positive integer: TSI 0 is implied
+		if ep.TSI != nil && *ep.TSI > 0 {
+			tsi := int(*ep.TSI)
+			a.MediaTransportSessionIdentfier = &tsi
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// MarshalServerConfiguration returns the XML document of c.
+func MarshalServerConfiguration(c *ServerConfiguration) ([]byte, error) {
+	return marshal("MulticastServerConfiguration", c)
+}
+
+func marshal(root string, v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(wrapper{name: xml.Name{Space: dvb.Namespace, Local: root}, v: v}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// wrapper encodes v as the root element name of the DVB namespace.
+type wrapper struct {
+	name xml.Name
+	v    any
+}
+
+func (w wrapper) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return e.EncodeElement(w.v, xml.StartElement{Name: w.name})
+}
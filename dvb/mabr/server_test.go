@@ -0,0 +1,132 @@
+package mabr_test
+
+import (
+	"encoding/xml"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/dvb/mabr"
+	dvb "github.com/Blockcast/multicast-api/dvb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSession() (api.Service, api.Session) {
+	tsi, rprTSI := uint64(1), uint64(2)
+	duration := api.Duration(2 * time.Hour)
+	svc := api.Service{
+		ServiceId:         "tag:example.com,2024:svc1",
+		TransportProtocol: api.FLUTE,
+		TransportSecurity: api.Integrity,
+	}
+	s := api.Session{
+		ID:             7,
+		RprHost:        "cdn.example.com",
+		RprUnicastPath: "/repair/svc1",
+		Reoccurrences:  api.RRuleSet{Dtstart: api.TimeZ(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		PresentationManifestLocator: []dvb.PresentationManifestLocator{
+			{Location: "http://origin.example.com/manifest.mpd", ManifestId: "mpd", ContentType: "application/dash+xml"},
+			{Location: "http://origin.example.com/master.m3u8", ManifestId: "hls", ContentType: "application/vnd.apple.mpegURL"},
+		},
+		Delivery: []api.DeliveryMethod{{
+			BitrateKbps:         api.BitRateType{Average: 150, Maximum: 200},
+			StartOffset:         api.Duration(time.Minute),
+			Duration:            &duration,
+			RepairWindow:        api.Duration(750 * time.Millisecond),
+			ContentIngestMethod: api.Push,
+			TransmissionMode:    api.Chunked,
+			FEC: api.FECParamsType{
+				{Encoding: api.RAPTORQ_FEC_ENC_ID, Redundancy: 0.2, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: netip.MustParseAddr("10.0.0.1"), Group: netip.MustParseAddr("232.1.1.1"), DestPort: 3000, TSI: &tsi},
+				}},
+				{Encoding: api.RAPTORQ_FEC_ENC_ID, Redundancy: 0.1, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: netip.MustParseAddr("10.0.0.1"), Group: netip.MustParseAddr("232.1.1.2"), DestPort: 3000, TSI: &rprTSI},
+				}},
+			},
+			DASHComponent: api.DASHComponents{{PeriodIdentifier: "p1", AdaptationSetIdentifier: 1, RepresentationIdentifier: "V1", ManifestIdRef: "mpd"}},
+			HLSComponent:  api.HLSComponents{{MediaPlaylistLocator: "http://origin.example.com/V1.m3u8", ManifestIdRef: "hls"}},
+		}},
+	}
+	return svc, s
+}
+
+func TestServerConfiguration(t *testing.T) {
+	svc, s := testSession()
+	c, err := mabr.NewServerConfiguration(&mabr.Options{ValidityPeriod: 24 * time.Hour, FixedBackOff: 10 * time.Millisecond},
+		mabr.ServiceSession{Service: svc, Session: s})
+	require.NoError(t, err)
+	assert.Equal(t, "PT24H", c.ValidityPeriod)
+	require.Len(t, c.MulticastSession, 1)
+	ms := c.MulticastSession[0]
+	assert.Equal(t, svc.ServiceId, ms.ServiceIdentifier)
+	require.Len(t, ms.MulticastTransportSession, 1)
+	ts := ms.MulticastTransportSession[0]
+
+	assert.Equal(t, "session7-stream0", ts.ID)
+	assert.Equal(t, dvb.TermReferenceType("urn:dvb:metadata:cs:MulticastTransportProtocolCS:2019:FLUTE"), ts.TransportProtocol.ProtocolIdentifier)
+	assert.Equal(t, 1, ts.TransportProtocol.ProtocolVersion)
+	assert.Equal(t, dvb.TimePointType("2024-01-01T00:01:00+00:00"), ts.Start)
+	assert.Equal(t, "PT2H", ts.Duration)
+	assert.Equal(t, dvb.TransmissionModeType("chunked"), ts.TransmissionMode)
+	assert.Equal(t, dvb.BitRateType{Average: 150000, Maximum: 200000}, ts.BitRate)
+	require.Len(t, ts.EndpointAddress, 1)
+	assert.Equal(t, "232.1.1.1", ts.EndpointAddress[0].NetworkDestinationGroupAddress)
+	assert.Equal(t, 1, *ts.EndpointAddress[0].MediaTransportSessionIdentfier)
+
+	require.Len(t, ts.ForwardErrorCorrectionParameters, 2)
+	assert.Equal(t, dvb.TermReferenceType("urn:ietf:rmt:fec:encoding:6"), ts.ForwardErrorCorrectionParameters[0].SchemeIdentifier)
+	assert.Equal(t, 20, ts.ForwardErrorCorrectionParameters[0].OverheadPercentage)
+	assert.Empty(t, ts.ForwardErrorCorrectionParameters[0].EndpointAddress)
+	require.Len(t, ts.ForwardErrorCorrectionParameters[1].EndpointAddress, 1)
+	assert.Equal(t, "232.1.1.2", ts.ForwardErrorCorrectionParameters[1].EndpointAddress[0].NetworkDestinationGroupAddress)
+
+	require.NotNil(t, ts.UnicastRepairParameters)
+	assert.Equal(t, "https://cdn.example.com/repair/svc1", ts.UnicastRepairParameters.BaseURL[0].Value)
+	assert.Equal(t, uint(750), ts.UnicastRepairParameters.TransportObjectReceptionTimeout)
+	assert.Equal(t, uint(10), ts.UnicastRepairParameters.FixedBackOffPeriod)
+	require.Len(t, ts.ServiceComponentIdentifier, 2)
+
+	b, err := mabr.MarshalServerConfiguration(c)
+	require.NoError(t, err)
+	var back dvb.MulticastServerConfiguration
+	require.NoError(t, xml.Unmarshal(b, &back))
+	require.Len(t, back.MulticastSession, 1)
+	got := back.MulticastSession[0].MulticastTransportSession[0]
+	assert.Contains(t, string(b), `id="session7-stream0"`)
+	assert.Equal(t, 1, strings.Count(string(b), "<MulticastTransportSession "), "the generated MulticastTransportSession is shadowed")
+	require.Len(t, got.ServiceComponentIdentifier, 2)
+	assert.Equal(t, "V1", string(got.ServiceComponentIdentifier[0].DASHComponentIdentifierType.RepresentationIdentifier))
+	assert.Equal(t, "http://origin.example.com/V1.m3u8", got.ServiceComponentIdentifier[1].HLSComponentIdentifierType.MediaPlaylistLocator)
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not found, schema validation skipped")
+	}
+	doc := filepath.Join(t.TempDir(), "server.xml")
+	require.NoError(t, os.WriteFile(doc, b, 0o644))
+	out, err := exec.Command(xmllint, "--noout", "--schema", "../models/MulticastSessionConfig.xsd", doc).CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestServerConfigurationErrors(t *testing.T) {
+	svc, s := testSession()
+	s.Delivery[0].FEC[0].Endpoint[0].Source = netip.Addr{}
+	_, err := mabr.NewServerConfiguration(nil, mabr.ServiceSession{Service: svc, Session: s})
+	assert.ErrorContains(t, err, "no source address")
+
+	svc, s = testSession()
+	s.Delivery[0].DASHComponent, s.Delivery[0].HLSComponent = nil, nil
+	_, err = mabr.NewServerConfiguration(nil, mabr.ServiceSession{Service: svc, Session: s})
+	assert.ErrorContains(t, err, "no DASH or HLS component")
+
+	svc, s = testSession()
+	svc.TransportProtocol = "RTP"
+	_, err = mabr.NewServerConfiguration(nil, mabr.ServiceSession{Service: svc, Session: s})
+	assert.Error(t, err)
+}
@@ -2,9 +2,17 @@ package models
 
 import (
 	"encoding/xml"
+	"fmt"
 	"strings"
 )
 
+const (
+	// Namespace is the target namespace of MulticastSessionConfig.xsd.
+	Namespace = "urn:dvb:metadata:MulticastSessionConfiguration:2019"
+	// XSINamespace qualifies the xsi:type attribute of ServiceComponentIdentifier.
+	XSINamespace = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
 const (
 	Pull ContentAcquisitionMethodType = "pull"
 	Push                              = "push"
@@ -52,3 +60,18 @@ func (s *ServiceComponentIdentifierType) UnmarshalXML(d *xml.Decoder, start xml.
 	}
 	return nil
 }
+
+func (s ServiceComponentIdentifierType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var v any
+	var typ string
+	switch {
+	case s.DASHComponentIdentifierType != nil:
+		v, typ = s.DASHComponentIdentifierType, "DASHComponentIdentifierType"
+	case s.HLSComponentIdentifierType != nil:
+		v, typ = s.HLSComponentIdentifierType, "HLSComponentIdentifierType"
+	default:
+		return fmt.Errorf("empty ServiceComponentIdentifier")
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: XSINamespace, Local: "type"}, Value: typ})
+	return e.EncodeElement(v, start)
+}
@@ -0,0 +1,191 @@
+package fec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	api "github.com/Blockcast/multicast-api"
+	sync "github.com/linkdata/deadlock"
+)
+
+var ErrDigestMismatch = fmt.Errorf("object digest mismatch")
+
+// Sink stores the octets of an object being assembled, e.g. an *os.File. It is
+// read back to verify the object once complete.
+type Sink interface {
+	io.WriterAt
+	io.ReaderAt
+}
+
+// Assembler writes the decoded source blocks of a transport object to a sink at
+// their SrcOffset. Once every block is written it verifies the object against
+// the expected Content-MD5 and Repr-Digest, if any, and moves to FINISHED when
+// they match and to ERROR otherwise.
+type Assembler struct {
+	TOI        uint64
+	URI        string
+	MD5        MD5        // expected Content-MD5, nil to skip
+	ReprDigest ReprDigest // expected Repr-Digest, RFC 9530, nil to skip
+	bs         *BlockingStructure
+	sink       Sink
+	mux        sync.RWMutex
+	written    map[uint32]bool
+	state      api.FState
+	err        error
+	feed       api.FeedOf[StateChange]
+}
+
+// NewAssembler returns the assembler of object toi laid out by bs into sink, in
+// state WANTED.
+func NewAssembler(toi uint64, bs *BlockingStructure, sink Sink) (*Assembler, error) {
+	if bs == nil || bs.ESLen == 0 || bs.N.Load() == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("nil sink")
+	}
+	return &Assembler{
+		TOI:     toi,
+		bs:      bs,
+		sink:    sink,
+		written: make(map[uint32]bool),
+		state:   api.WANTED,
+	}, nil
+}
+
+// Subscribe delivers the state changes of the object to ch.
+func (a *Assembler) Subscribe(ch chan<- StateChange) api.Subscription {
+	return a.feed.Subscribe(ch)
+}
+
+func (a *Assembler) State() api.FState {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return a.state
+}
+
+// Err returns why the object is in state ERROR.
+func (a *Assembler) Err() error {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return a.err
+}
+
+func (a *Assembler) setState(s api.FState, changes []StateChange) []StateChange {
+	if a.state == s {
+		return changes
+	}
+	changes = append(changes, StateChange{TOI: a.TOI, From: a.state, To: s})
+	a.state = s
+	return changes
+}
+
+// WriteBlock writes the decoded source block sbn, SrcBlockSize(sbn) octets,
+// and verifies the object once it is the last block missing. It returns the
+// state of the object.
+func (a *Assembler) WriteBlock(sbn uint32, block []byte) (api.FState, error) {
+	if sbn >= a.bs.N.Load() {
+		return a.State(), fmt.Errorf("sbn %d out of range, N=%d", sbn, a.bs.N.Load())
+	}
+	if size := a.bs.SrcBlockSize(sbn); uint64(len(block)) != size {
+		return a.State(), fmt.Errorf("block %d is %d octets, expected %d", sbn, len(block), size)
+	}
+	var changes []StateChange
+	a.mux.Lock()
+	defer func() {
+		a.mux.Unlock()
+		a.publish(changes)
+	}()
+	switch a.state {
+	case api.FINISHED, api.ERROR:
+		return a.state, nil
+	case api.WANTED:
+		changes = a.setState(api.RECEIVING, changes)
+	}
+	if a.written[sbn] {
+		return a.state, nil
+	}
+	if _, err := a.sink.WriteAt(block, int64(a.bs.SrcOffset(sbn))); err != nil {
+		return a.state, fmt.Errorf("toi %d: write block %d: %w", a.TOI, sbn, err)
+	}
+	a.written[sbn] = true
+	if uint32(len(a.written)) < a.bs.N.Load() {
+		return a.state, nil
+	}
+	if a.err = a.verify(); a.err != nil {
+		changes = a.setState(api.ERROR, changes)
+		return a.state, a.err
+	}
+	changes = a.setState(api.FINISHED, changes)
+	return a.state, nil
+}
+
+// verify reads the object back and checks its digests.
+func (a *Assembler) verify() error {
+	if a.MD5 == nil && a.ReprDigest == nil {
+		return nil
+	}
+	algs := make([]string, 0, len(a.ReprDigest))
+	for alg := range a.ReprDigest {
+		algs = append(algs, alg)
+	}
+	d := NewDigests(algs...)
+	if _, err := io.Copy(d, io.NewSectionReader(a.sink, 0, int64(a.bs.TransferLen.Load()))); err != nil {
+		return fmt.Errorf("toi %d: read back: %w", a.TOI, err)
+	}
+	if a.MD5 != nil && !bytes.Equal(a.MD5, d.MD5()) {
+		return fmt.Errorf("%w: toi %d content-md5 %s, expected %s", ErrDigestMismatch, a.TOI, d.MD5(), a.MD5)
+	}
+	if len(a.ReprDigest) == 0 {
+		return nil
+	}
+	got := d.ReprDigest()
+	if len(got) == 0 {
+		return fmt.Errorf("toi %d: no supported repr-digest algorithm in %s", a.TOI, a.ReprDigest)
+	}
+	for alg, sum := range got {
+		if !bytes.Equal(a.ReprDigest[alg], sum) {
+			return fmt.Errorf("%w: toi %d %s mismatch", ErrDigestMismatch, a.TOI, alg)
+		}
+	}
+	return nil
+}
+
+func (a *Assembler) publish(changes []StateChange) {
+	for _, c := range changes {
+		a.feed.Send(c)
+	}
+}
+
+// Written returns the octet ranges of the object written so far.
+func (a *Assembler) Written() RangeList {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	rl := make(RangeList, 0, len(a.written))
+	for sbn := range a.written {
+		offset := int64(a.bs.SrcOffset(sbn))
+		rl = append(rl, Range{offset, offset + int64(a.bs.SrcBlockSize(sbn)) - 1})
+	}
+	return flatten(rl)
+}
+
+// FileURI returns the reception report entry of the object. ReceptionSuccess
+// is only set once the object is FINISHED or in ERROR.
+func (a *Assembler) FileURI() api.BlockcastFileURI {
+	rl := a.Written()
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	f := api.BlockcastFileURI{
+		URI:            a.URI,
+		TOI:            a.TOI,
+		RangeReceived:  rl.String(),
+		TransferLength: a.bs.TransferLen.Load(),
+		FileState:      a.state,
+	}
+	if a.state == api.FINISHED || a.state == api.ERROR {
+		success := a.state == api.FINISHED
+		f.ReceptionSuccess = &success
+	}
+	return f
+}
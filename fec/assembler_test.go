@@ -0,0 +1,124 @@
+package fec_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memSink is an in-memory fec.Sink.
+type memSink []byte
+
+func (m memSink) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m)) {
+		return 0, fmt.Errorf("write beyond %d octets", len(m))
+	}
+	return copy(m[off:], p), nil
+}
+
+func (m memSink) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestReprDigest(t *testing.T) {
+	d, err := fec.ParseReprDigest("sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, SHA-512=:AAAA:;p=1")
+	require.NoError(t, err)
+	assert.Len(t, d[fec.DigestSHA256], 32)
+	assert.Equal(t, []byte{0, 0, 0}, d[fec.DigestSHA512])
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, sha-512=:AAAA:", d.String())
+
+	// RFC 9530 section 3, the representation {"hello": "world"}
+	digests := fec.NewDigests()
+	_, _ = digests.Write([]byte(`{"hello": "world"}`))
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", digests.ReprDigest().String())
+
+	for _, invalid := range []string{"", "sha-256", "sha-256=abc", "sha-256=:!!:"} {
+		_, err = fec.ParseReprDigest(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	obj := make([]byte, 10000)
+	rand.New(rand.NewSource(16)).Read(obj)
+	md5sum := md5.Sum(obj)
+	sha := sha256.Sum256(obj)
+	bs, err := fec.NewBlockingStructure5052(uint64(len(obj)), 40, 64, 1, false)
+	require.NoError(t, err)
+	block := func(sbn uint32) []byte {
+		offset := bs.SrcOffset(sbn)
+		return obj[offset : offset+bs.SrcBlockSize(sbn)]
+	}
+
+	for _, corrupt := range []bool{false, true} {
+		sink := make(memSink, len(obj))
+		a, err := fec.NewAssembler(5, bs, sink)
+		require.NoError(t, err)
+		a.URI = "http://example.com/obj"
+		a.MD5 = md5sum[:]
+		a.ReprDigest = fec.ReprDigest{fec.DigestSHA256: sha[:]}
+		ch := make(chan fec.StateChange, 10)
+		sub := a.Subscribe(ch)
+
+		assert.Nil(t, a.FileURI().ReceptionSuccess)
+		_, err = a.WriteBlock(0, block(0)[1:])
+		assert.Error(t, err)
+		for sbn := bs.N.Load() - 1; sbn > 0; sbn-- {
+			state, err := a.WriteBlock(sbn, block(sbn))
+			require.NoError(t, err)
+			assert.Equal(t, api.RECEIVING, state)
+		}
+		assert.Equal(t, fec.RangeList{{int64(bs.SrcOffset(1)), int64(len(obj)) - 1}}, a.Written())
+		first := append([]byte(nil), block(0)...)
+		if corrupt {
+			first[3] ^= 1
+		}
+		state, err := a.WriteBlock(0, first)
+		f := a.FileURI()
+		require.NotNil(t, f.ReceptionSuccess)
+		if corrupt {
+			assert.ErrorIs(t, err, fec.ErrDigestMismatch)
+			assert.ErrorIs(t, a.Err(), fec.ErrDigestMismatch)
+			assert.Equal(t, api.ERROR, state)
+			assert.False(t, *f.ReceptionSuccess)
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, api.FINISHED, state)
+			assert.True(t, *f.ReceptionSuccess)
+			assert.Equal(t, []byte(obj), []byte(sink))
+		}
+		assert.Equal(t, uint64(5), f.TOI)
+		assert.Equal(t, "0-9999", f.RangeReceived)
+		assert.Equal(t, uint64(len(obj)), f.TransferLength)
+		assert.Equal(t, state, f.FileState)
+		sub.Unsubscribe()
+		assert.Equal(t, fec.StateChange{TOI: 5, From: api.WANTED, To: api.RECEIVING}, <-ch)
+		assert.Equal(t, fec.StateChange{TOI: 5, From: api.RECEIVING, To: state}, <-ch)
+	}
+
+	// a digest of an unsupported algorithm alone cannot be verified
+	sink := make(memSink, len(obj))
+	a, err := fec.NewAssembler(6, bs, sink)
+	require.NoError(t, err)
+	a.ReprDigest = fec.ReprDigest{"md5": md5sum[:]}
+	for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+		_, err = a.WriteBlock(sbn, block(sbn))
+	}
+	assert.Error(t, err)
+	assert.Equal(t, api.ERROR, a.State())
+}
@@ -3,7 +3,11 @@ package fec
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"sort"
 	"sync/atomic"
+
+	api "github.com/Blockcast/multicast-api"
 )
 
 type BlockingStructure struct {
@@ -17,9 +21,25 @@ type BlockingStructure struct {
 	MaxSbLen      uint32
 	MaxNumEs      uint32
 	NumEsPerGroup uint32
+	Encoding      api.FECEncoding // FEC scheme whose blocking rules apply, RFC 5052 unless RaptorQ
+	Instance      api.FECInstance // FEC Instance ID of the under-specified schemes 128-130
+	Al            uint8           // RFC 6330 symbol alignment parameter
+	SubBlocks     uint16          // RFC 6330 number of sub-blocks per source block
+	WS            uint64          // RFC 6330 maximum sub-block size in octets, zero disables sub-blocking
+	stream        atomic.Bool     // streamed, every block but the last one has MaxSbLen symbols
+	final         atomic.Bool     // the length of a streamed object is final
+	sealed        atomic.Uint32   // number of leading blocks of a streamed object whose source symbols are final
+
+	repair atomic.Pointer[repairCounts] // repair symbols of the leading blocks set by SetRepair, nil for none
 	//mux           sync.RWMutex
 }
 
+// repairCounts is the repair symbols of the leading blocks set by one SetRepair.
+type repairCounts struct {
+	n   []uint32 // repair symbols of each of the leading blocks
+	off []uint64 // repair symbols before each of the leading blocks, and after the last one
+}
+
 func (bs *BlockingStructure) String() string {
 	if bs == nil {
 		return "nil"
@@ -53,9 +73,12 @@ func UpdateBlockingStructure5052(bs *BlockingStructure, transferObjLen uint64, m
 	if bs == nil {
 		return fmt.Errorf("nil blocking structure")
 	}
-	if transferObjLen == 0 {
+	if transferObjLen == 0 && !stream {
 		return fmt.Errorf("length must be greater than zero")
 	}
+	if bs.stream.Load() && (bs.ESLen != encodingSymbolLen || bs.MaxSbLen != maxSourceBlockLen) {
+		return fmt.Errorf("streamed object blocking cannot change from esLen=%d,maxSb=%d", bs.ESLen, bs.MaxSbLen)
+	}
 	//bs.mux.Lock()
 	//defer bs.mux.Unlock()
 	if bs.ESLen != encodingSymbolLen {
@@ -70,20 +93,84 @@ func UpdateBlockingStructure5052(bs *BlockingStructure, transferObjLen uint64, m
 	if bs.MaxNumEs == 0 {
 		bs.MaxNumEs = maxSourceBlockLen
 	}
+	if stream || bs.stream.Load() {
+		return bs.updateStream(transferObjLen, !stream)
+	}
 	bs.ASmall.Store(maxSourceBlockLen)
 	bs.A.Store(maxSourceBlockLen)
 	bs.TransferLen.Store(transferObjLen)
-	if !stream {
-		return bs.recompute()
+	return bs.recompute()
+}
+
+// NewStreamBlockingStructure returns the blocking structure of an object whose
+// length is not known yet, e.g. sent in chunked or entity mode. Its length grows
+// with UpdateLength(length, true) until a last UpdateLength(length, false).
+func NewStreamBlockingStructure(maxSourceBlockLen uint32, encodingSymbolLen uint16, numEsPerGroup uint32) (*BlockingStructure, error) {
+	return NewBlockingStructure5052(0, maxSourceBlockLen, encodingSymbolLen, numEsPerGroup, true)
+}
+
+// updateStream lays out a streamed object of length octets so far. Blocks are
+// not balanced as in RFC 5052 9.1 since the blocks already sent cannot change:
+// they all have MaxSbLen symbols but the last one, so that SrcOffset and
+// SourceSBN stay the same as the object grows. A block is sealed once the
+// object extends beyond it, and every block is once the length is final.
+func (bs *BlockingStructure) updateStream(length uint64, final bool) error {
+	if err := bs.checkParams(); err != nil {
+		return err
+	}
+	if bs.final.Load() {
+		if length != bs.TransferLen.Load() {
+			return fmt.Errorf("streamed object length is final at %d, got %d", bs.TransferLen.Load(), length)
+		}
+		return nil
+	}
+	if length < bs.TransferLen.Load() {
+		return fmt.Errorf("streamed object length %d shorter than %d", length, bs.TransferLen.Load())
+	}
+	if final && length == 0 {
+		return fmt.Errorf("length must be greater than zero")
+	}
+	bs.stream.Store(true)
+	bs.A.Store(bs.MaxSbLen)
+	bs.ASmall.Store(bs.MaxSbLen)
+	bs.TransferLen.Store(length)
+	bs.T = (length + uint64(bs.ESLen) - 1) / uint64(bs.ESLen) // the number of source symbols in the object
+	bs.N.Store(uint32((bs.T + uint64(bs.MaxSbLen) - 1) / uint64(bs.MaxSbLen)))
+	bs.I.Store(bs.N.Load())
+	if final {
+		bs.sealed.Store(bs.N.Load())
+		bs.final.Store(true)
 	} else {
-		bs.T = (bs.TransferLen.Load() + uint64(bs.ESLen) - 1) / uint64(bs.ESLen) // the number of source symbols in the object
-		bs.N.Store(uint32((bs.T + uint64(bs.MaxSbLen) - 1) / uint64(bs.MaxSbLen)))
-		bs.I.Store(bs.N.Load())
+		bs.sealed.Store(uint32(length / (uint64(bs.MaxSbLen) * uint64(bs.ESLen))))
 	}
 	return nil
 }
 
-func (bs *BlockingStructure) recompute() error {
+// Streamed reports whether the object is laid out as a stream.
+func (bs *BlockingStructure) Streamed() bool {
+	return bs.stream.Load()
+}
+
+// Final reports whether the length of the object is final, always true unless streamed.
+func (bs *BlockingStructure) Final() bool {
+	return !bs.stream.Load() || bs.final.Load()
+}
+
+// Sealed returns the number of leading source blocks whose source symbols are
+// final, so that they can be encoded and sent.
+func (bs *BlockingStructure) Sealed() uint32 {
+	if !bs.stream.Load() {
+		return bs.N.Load()
+	}
+	return bs.sealed.Load()
+}
+
+// IsSealed reports whether the source symbols of block sbn are final.
+func (bs *BlockingStructure) IsSealed(sbn uint32) bool {
+	return sbn < bs.Sealed()
+}
+
+func (bs *BlockingStructure) checkParams() error {
 	if bs.MaxSbLen == 0 {
 		return fmt.Errorf("maxSourceBlockLen must be greater than zero")
 	}
@@ -96,6 +183,13 @@ func (bs *BlockingStructure) recompute() error {
 	if bs.NumEsPerGroup > bs.MaxSbLen {
 		return fmt.Errorf("numEsPerGroup %d must be less than or equal to maxSourceBlockLen %d", bs.NumEsPerGroup, bs.MaxSbLen)
 	}
+	return nil
+}
+
+func (bs *BlockingStructure) recompute() error {
+	if err := bs.checkParams(); err != nil {
+		return err
+	}
 	bs.T = (bs.TransferLen.Load() + uint64(bs.ESLen) - 1) / uint64(bs.ESLen) // the number of source symbols in the object
 	bs.N.Store(uint32((bs.T + uint64(bs.MaxSbLen) - 1) / uint64(bs.MaxSbLen)))
 
@@ -139,17 +233,121 @@ func (bs *BlockingStructure) NumSrcSym(sbn uint32) uint32 {
 //	func (bs *BlockingStructure) HasRpr() bool {
 //		return  (uint64(bs.MaxNumEs) - uint64(bs.MaxSbLen)) > 0
 //	}
+//
+// RprBlockSize returns the size of the repair symbols of a block without a
+// repair count of its own, see NumRprSym.
 func (bs *BlockingStructure) RprBlockSize() uint64 {
 	return (uint64(bs.MaxNumEs) - uint64(bs.MaxSbLen)) * uint64(bs.ESLen)
 	//return (uint64(bs.MaxNumEs) - uint64(bs.ASmall.Load())) * uint64(bs.ESLen)
 }
 
+// RprSize returns the size of the repair symbols of block sbn.
+func (bs *BlockingStructure) RprSize(sbn uint32) uint64 {
+	return uint64(bs.NumRprSym(sbn)) * uint64(bs.ESLen)
+}
+
+// uniformRepair reports whether every block has RprBlockSize repair octets.
+func (bs *BlockingStructure) uniformRepair() bool {
+	return bs.repair.Load() == nil && !reedSolomonScaled(bs)
+}
+
+// rprSymOffset returns the number of repair symbols of the blocks before sbn.
+func (bs *BlockingStructure) rprSymOffset(sbn uint32) uint64 {
+	sbn = min(sbn, bs.N.Load())
+	var off uint64
+	first := uint32(0)
+	if r := bs.repair.Load(); r != nil {
+		first = min(sbn, uint32(len(r.n)))
+		off = r.off[first]
+	}
+	if !reedSolomonScaled(bs) && sbn > first {
+		// the following blocks all have MaxNumEs - MaxSbLen repair symbols
+		return off + uint64(sbn-first)*uint64(bs.NumRprSym(first))
+	}
+	for i := first; i < sbn; i++ {
+		off += uint64(bs.NumRprSym(i))
+	}
+	return off
+}
+
+// RprOffset returns the offset of the repair symbols of block sbn in the
+// concatenation of the repair symbols of all the blocks.
 func (bs *BlockingStructure) RprOffset(sbn uint32) uint64 {
-	rprBlockLen := bs.RprBlockSize()
-	if sbn > bs.N.Load() {
-		sbn = bs.N.Load()
+	if bs.uniformRepair() {
+		rprBlockLen := bs.RprBlockSize()
+		if sbn > bs.N.Load() {
+			sbn = bs.N.Load()
+		}
+		return rprBlockLen * uint64(sbn)
 	}
-	return rprBlockLen * uint64(sbn)
+	return bs.rprSymOffset(sbn) * uint64(bs.ESLen)
+}
+
+// NumRprSym returns the number of repair symbols of block sbn: its count set by
+// SetRepair, else n - k for schemes 2, 5 and 129 and MaxNumEs - MaxSbLen for the
+// others.
+func (bs *BlockingStructure) NumRprSym(sbn uint32) uint32 {
+	k := bs.NumSrcSym(sbn)
+	if k == 0 {
+		return 0
+	}
+	if r, ok := bs.repairCount(sbn); ok {
+		return r
+	}
+	switch {
+	case reedSolomonScaled(bs):
+		return reedSolomonN(bs, k) - k
+	case bs.MaxNumEs > bs.MaxSbLen:
+		return bs.MaxNumEs - bs.MaxSbLen
+	}
+	return 0
+}
+
+// repairCount returns the repair count of block sbn set by SetRepair, if any.
+func (bs *BlockingStructure) repairCount(sbn uint32) (uint32, bool) {
+	r := bs.repair.Load()
+	if r == nil || sbn >= uint32(len(r.n)) {
+		return 0, false
+	}
+	return r.n[sbn], true
+}
+
+// hasRepair reports whether block sbn has a repair count set by SetRepair.
+func (bs *BlockingStructure) hasRepair(sbn uint32) bool {
+	_, ok := bs.repairCount(sbn)
+	return ok
+}
+
+// SetRepair sets the number of repair symbols of the leading blocks, e.g. more
+// on a short last block or on an important object; the following blocks keep
+// the count of the scheme. A nil counts restores it for every block. RFC 5052
+// blocking, encoding 0, has repair symbols only if MaxNumEs exceeds MaxSbLen.
+// The counts are swapped in at once: concurrent readers see those of one call.
+func (bs *BlockingStructure) SetRepair(counts []uint32) error {
+	codec, err := NewPayloadIDCodec(bs.Encoding, bs.Instance, nil)
+	if err != nil {
+		return err
+	}
+	off := make([]uint64, len(counts)+1)
+	for sbn, r := range counts {
+		k := uint64(bs.NumSrcSym(uint32(sbn)))
+		n := k + uint64(r)
+		switch {
+		case bs.Encoding == api.COM_NO_C_FEC_ENC_ID && r > 0 && bs.MaxNumEs <= bs.MaxSbLen:
+			return fmt.Errorf("FEC encoding %d has no repair symbols with MaxNumEs %d and MaxSbLen %d", bs.Encoding, bs.MaxNumEs, bs.MaxSbLen)
+		case reedSolomonCode(bs) && n > ReedSolomonMaxN:
+			return fmt.Errorf("sbn %d: n=%d exceeds %d for GF(2^8)", sbn, n, ReedSolomonMaxN)
+		case n > codec.maxESI(uint32(sbn))+1:
+			return fmt.Errorf("sbn %d: n=%d exceeds the FEC Payload ID", sbn, n)
+		}
+		off[sbn+1] = off[sbn] + uint64(r)
+	}
+	if len(counts) == 0 {
+		bs.repair.Store(nil)
+		return nil
+	}
+	bs.repair.Store(&repairCounts{n: append([]uint32(nil), counts...), off: off})
+	return nil
 }
 
 // GetBlockOffset computes the source block offset from the start of the file
@@ -186,8 +384,15 @@ func (bs *BlockingStructure) SourceSBN(offset uint64) uint32 {
 }
 
 func (bs *BlockingStructure) RepairSBN(offset uint64) uint32 {
-	rprBlockLen := bs.RprBlockSize()
-	return uint32(offset / rprBlockLen)
+	if bs.uniformRepair() {
+		rprBlockLen := bs.RprBlockSize()
+		return uint32(offset / rprBlockLen)
+	}
+	sym := offset / uint64(bs.ESLen)
+	N := bs.N.Load()
+	return uint32(sort.Search(int(N), func(i int) bool {
+		return bs.rprSymOffset(uint32(i)+1) > sym
+	}))
 }
 
 func (bs *BlockingStructure) SrcBlockSize(sbn uint32) (blockLen uint64) {
@@ -202,6 +407,256 @@ func (bs *BlockingStructure) SrcBlockSize(sbn uint32) (blockLen uint64) {
 	return 0
 }
 
+// NumEncSym returns the number of encoding symbols, source and repair, of source
+// block sbn. Schemes 2, 5 and 129 scale max_n with the length k of the block,
+// n = floor(k * max_n / B); the others add MaxNumEs - MaxSbLen repair symbols to
+// every block. Counts set by SetRepair take precedence.
+func (bs *BlockingStructure) NumEncSym(sbn uint32) uint32 {
+	return bs.NumSrcSym(sbn) + bs.NumRprSym(sbn)
+}
+
+// reedSolomonScaled reports whether the scheme scales max_n with k.
+func reedSolomonScaled(bs *BlockingStructure) bool {
+	switch bs.Encoding {
+	case api.RS_GEN_FEC_ENC_ID, api.RS_GF8_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID:
+		return true
+	}
+	return false
+}
+
 func (bs *BlockingStructure) UpdateLength(length uint64, stream bool) error {
+	switch bs.Encoding {
+	case api.RAPTORQ_FEC_ENC_ID:
+		if stream {
+			return fmt.Errorf("raptorq blocking requires the transfer length")
+		}
+		return UpdateBlockingStructure6330(bs, length, bs.MaxSbLen, bs.ESLen, bs.Al, bs.WS, bs.NumEsPerGroup)
+	case api.SB_LB_E_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		if stream {
+			return fmt.Errorf("%s blocking requires the transfer length", bs.Encoding)
+		}
+		return UpdateBlockingStructure3452(bs, bs.Encoding, bs.Instance, length, bs.MaxSbLen, bs.ESLen, bs.MaxNumEs, bs.NumEsPerGroup)
+	}
 	return UpdateBlockingStructure5052(bs, length, bs.MaxSbLen, bs.ESLen, bs.NumEsPerGroup, stream)
 }
+
+func NewBlockingStructure3452(encoding api.FECEncoding, instance api.FECInstance, transferObjLen uint64, maxSourceBlockLen uint32, encodingSymbolLen uint16, maxNumEs uint32, numEsPerGroup uint32) (*BlockingStructure, error) {
+	bs := BlockingStructure{}
+	err := UpdateBlockingStructure3452(&bs, encoding, instance, transferObjLen, maxSourceBlockLen, encodingSymbolLen, maxNumEs, numEsPerGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &bs, err
+}
+
+// This function calculates the blocking of the under-specified FEC schemes of RFC3452
+// and RFC3695, whose source blocks follow RFC5052 9.1 within the bounds of their
+// FEC Payload ID and FEC OTI
+//
+//	128 -- Small Block, Large Block and Expandable: 32 bit SBN and ESI
+//	129 -- Small Block Systematic: B and max_n of 16 bits, the source block length
+//	       is carried with every symbol and max_n scales with it, see NumEncSym
+//	130 -- Compact: 16 bit SBN and ESI
+//
+// maxNumEs is max_n, 0 for no repair symbols. The instance selects the code, e.g.
+// api.ReedSolomonFECInst for scheme 129.
+func UpdateBlockingStructure3452(bs *BlockingStructure, encoding api.FECEncoding, instance api.FECInstance, transferObjLen uint64, maxSourceBlockLen uint32, encodingSymbolLen uint16, maxNumEs uint32, numEsPerGroup uint32) error {
+	if bs == nil {
+		return fmt.Errorf("nil blocking structure")
+	}
+	var maxSBN, maxESI uint64
+	switch encoding {
+	case api.SB_LB_E_FEC_ENC_ID:
+		maxSBN, maxESI = math.MaxUint32, math.MaxUint32
+	case api.SB_SYS_FEC_ENC_ID:
+		maxSBN, maxESI = math.MaxUint32, math.MaxUint16
+	case api.COM_FEC_ENC_ID:
+		maxSBN, maxESI = math.MaxUint16, math.MaxUint16
+	default:
+		return fmt.Errorf("FEC encoding %d is not under-specified", encoding)
+	}
+	if maxNumEs == 0 {
+		maxNumEs = maxSourceBlockLen
+	}
+	if maxNumEs < maxSourceBlockLen {
+		return fmt.Errorf("maxNumEs %d must be greater than or equal to maxSourceBlockLen %d", maxNumEs, maxSourceBlockLen)
+	}
+	if encoding == api.SB_SYS_FEC_ENC_ID && maxNumEs > math.MaxUint16 {
+		return fmt.Errorf("maxNumEs %d exceeds %d", maxNumEs, math.MaxUint16)
+	}
+	if uint64(maxNumEs)-1 > maxESI {
+		return fmt.Errorf("maxNumEs %d exceeds %d encoding symbols per block", maxNumEs, maxESI+1)
+	}
+	bs.MaxNumEs = maxNumEs
+	if err := UpdateBlockingStructure5052(bs, transferObjLen, maxSourceBlockLen, encodingSymbolLen, numEsPerGroup, false); err != nil {
+		return err
+	}
+	bs.Encoding = encoding
+	bs.Instance = instance
+	if uint64(bs.N.Load())-1 > maxSBN {
+		return fmt.Errorf("%d source blocks exceed %d, increase maxSourceBlockLen", bs.N.Load(), maxSBN+1)
+	}
+	return nil
+}
+
+func NewBlockingStructure6330(transferObjLen uint64, maxSourceBlockLen uint32, encodingSymbolLen uint16, al uint8, ws uint64, numEsPerGroup uint32) (*BlockingStructure, error) {
+	bs := BlockingStructure{}
+	err := UpdateBlockingStructure6330(&bs, transferObjLen, maxSourceBlockLen, encodingSymbolLen, al, ws, numEsPerGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &bs, err
+}
+
+// This function calculates source block and sub-block partitioning as defined in RFC6330 4.4.1.2
+//
+//	F  -- Transfer Length in octets
+//	T  -- Symbol size in octets, a multiple of Al
+//	Al -- Symbol alignment parameter
+//	WS -- Maximum size of a decodable sub-block in octets, 0 for no sub-blocking
+//
+// maxSourceBlockLen further bounds the number of source symbols per block, 0 stands for
+// RaptorQDefaultSrcSymbols, the largest block the dense solver of the codec handles
+// in about a second, and it is capped at RaptorQMaxSrcSymbols. The lower bound on
+// the sub-symbol size SS is taken as 1.
+func UpdateBlockingStructure6330(bs *BlockingStructure, transferObjLen uint64, maxSourceBlockLen uint32, encodingSymbolLen uint16, al uint8, ws uint64, numEsPerGroup uint32) error {
+	if bs == nil {
+		return fmt.Errorf("nil blocking structure")
+	}
+	if transferObjLen == 0 {
+		return fmt.Errorf("length must be greater than zero")
+	}
+	if transferObjLen > raptorQMaxTransferLen {
+		return fmt.Errorf("length %d exceeds %d", transferObjLen, uint64(raptorQMaxTransferLen))
+	}
+	if al == 0 || encodingSymbolLen == 0 || encodingSymbolLen%uint16(al) != 0 {
+		return fmt.Errorf("encodingSymbolLen %d must be a non zero multiple of Al %d", encodingSymbolLen, al)
+	}
+	switch {
+	case maxSourceBlockLen == 0:
+		maxSourceBlockLen = RaptorQDefaultSrcSymbols
+	case maxSourceBlockLen > RaptorQMaxSrcSymbols:
+		maxSourceBlockLen = RaptorQMaxSrcSymbols
+	}
+	if numEsPerGroup == 0 {
+		return fmt.Errorf("numEsPerGroup must be greater than zero")
+	}
+	if numEsPerGroup > maxSourceBlockLen {
+		return fmt.Errorf("numEsPerGroup %d must be less than or equal to maxSourceBlockLen %d", numEsPerGroup, maxSourceBlockLen)
+	}
+	T := uint64(encodingSymbolLen)
+	Kt := (transferObjLen + T - 1) / T
+
+	// KL(n) is the largest number of source symbols decodable within WS with n sub-blocks
+	KL := func(n uint64) uint64 {
+		if ws == 0 {
+			return uint64(maxSourceBlockLen)
+		}
+		subSymbolLen := uint64(al) * ((T + uint64(al)*n - 1) / (uint64(al) * n))
+		return min(raptorQLargestKp(ws/subSymbolLen), uint64(maxSourceBlockLen))
+	}
+	nMax := uint64(1)
+	if ws > 0 {
+		nMax = T / uint64(al)
+	}
+	if KL(nMax) == 0 {
+		return fmt.Errorf("WS %d too small for symbol length %d", ws, encodingSymbolLen)
+	}
+	Z := (Kt + KL(nMax) - 1) / KL(nMax)
+	if Z > raptorQMaxSrcBlocks {
+		return fmt.Errorf("%d source blocks exceed %d, increase symbol length or WS", Z, raptorQMaxSrcBlocks)
+	}
+	N := uint64(1)
+	for ; N < nMax; N++ {
+		if (Kt+Z-1)/Z <= KL(N) {
+			break
+		}
+	}
+
+	bs.WS = ws
+	bs.MaxSbLen = maxSourceBlockLen
+	bs.NumEsPerGroup = numEsPerGroup
+	if bs.MaxNumEs == 0 {
+		bs.MaxNumEs = maxSourceBlockLen
+	}
+	bs.partition6330(transferObjLen, encodingSymbolLen, al, uint32(Z), uint16(N))
+	return nil
+}
+
+// partition6330 lays out F octets in Z source blocks of N sub-blocks each, the
+// values carried by the RaptorQ FEC OTI.
+func (bs *BlockingStructure) partition6330(F uint64, T uint16, al uint8, Z uint32, N uint16) {
+	Kt := (F + uint64(T) - 1) / uint64(T)
+	bs.Encoding = api.RAPTORQ_FEC_ENC_ID
+	bs.ESLen = T
+	bs.Al = al
+	bs.SubBlocks = N
+	bs.TransferLen.Store(F)
+	bs.T = Kt
+	// (KL, KS, ZL, ZS) = Partition[Kt, Z]
+	bs.N.Store(Z)
+	bs.A.Store(uint32((Kt + uint64(Z) - 1) / uint64(Z)))
+	bs.ASmall.Store(uint32(Kt / uint64(Z)))
+	bs.I.Store(uint32(Kt - uint64(bs.ASmall.Load())*uint64(Z)))
+}
+
+// SubSymbolLen returns the length in octets of the sub-symbols of sub-block j,
+// from (TL, TS, NL, NS) = Partition[T/Al, N] of RFC6330 4.4.1.2. Without
+// sub-blocking the only sub-block is the whole source block.
+func (bs *BlockingStructure) SubSymbolLen(j uint16) uint32 {
+	if bs.SubBlocks <= 1 || bs.Al == 0 {
+		return uint32(bs.ESLen)
+	}
+	t := uint32(bs.ESLen) / uint32(bs.Al)
+	n := uint32(bs.SubBlocks)
+	TS := t / n
+	NL := t - TS*n
+	if uint32(j) < NL {
+		return (TS + 1) * uint32(bs.Al)
+	}
+	return TS * uint32(bs.Al)
+}
+
+// sourceSymbols cuts source block sbn into its k source symbols, zero padding the
+// last one. With sub-blocking a source symbol is the concatenation of the
+// sub-symbols at the same position in every sub-block.
+func (bs *BlockingStructure) sourceSymbols(sbn uint32, block []byte) [][]byte {
+	k := int(bs.NumSrcSym(sbn))
+	if bs.SubBlocks <= 1 {
+		return splitSymbols(block, k, int(bs.ESLen))
+	}
+	padded := make([]byte, k*int(bs.ESLen))
+	copy(padded, block)
+	out := splitSymbols(nil, k, int(bs.ESLen))
+	subOffset, symOffset := 0, 0
+	for j := uint16(0); j < bs.SubBlocks; j++ {
+		ss := int(bs.SubSymbolLen(j))
+		for i := range out {
+			copy(out[i][symOffset:symOffset+ss], padded[subOffset+i*ss:])
+		}
+		subOffset += k * ss
+		symOffset += ss
+	}
+	return out
+}
+
+// sourceBlock is the inverse of sourceSymbols, it returns the SrcBlockSize(sbn) octets of the block.
+func (bs *BlockingStructure) sourceBlock(sbn uint32, symbols [][]byte) []byte {
+	k := len(symbols)
+	out := make([]byte, 0, k*int(bs.ESLen))
+	if bs.SubBlocks <= 1 {
+		for _, sym := range symbols {
+			out = append(out, sym...)
+		}
+	} else {
+		symOffset := 0
+		for j := uint16(0); j < bs.SubBlocks; j++ {
+			ss := int(bs.SubSymbolLen(j))
+			for _, sym := range symbols {
+				out = append(out, sym[symOffset:symOffset+ss]...)
+			}
+			symOffset += ss
+		}
+	}
+	return out[:bs.SrcBlockSize(sbn)]
+}
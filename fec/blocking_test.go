@@ -0,0 +1,351 @@
+package fec_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockingStructure6330(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(10485760, 600, 1430, 2, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, api.RAPTORQ_FEC_ENC_ID, bs.Encoding)
+	assert.Equal(t, uint64(7333), bs.T)
+	assert.Equal(t, uint32(13), bs.N.Load())
+	assert.Equal(t, uint32(565), bs.A.Load())
+	assert.Equal(t, uint32(564), bs.ASmall.Load())
+	assert.Equal(t, uint32(1), bs.I.Load())
+	assert.Equal(t, uint16(1), bs.SubBlocks)
+
+	var total uint64
+	for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+		assert.Equal(t, total, bs.SrcOffset(sbn))
+		assert.Equal(t, sbn, bs.SourceSBN(total))
+		total += bs.SrcBlockSize(sbn)
+	}
+	assert.Equal(t, bs.TransferLen.Load(), total)
+
+	require.NoError(t, bs.UpdateLength(1430*600, false))
+	assert.Equal(t, uint32(1), bs.N.Load())
+	assert.Equal(t, uint32(600), bs.NumSrcSym(0))
+
+	_, err = fec.NewBlockingStructure6330(1000, 0, 1430, 4, 0, 1)
+	assert.Error(t, err)
+}
+
+func TestBlockingStructure6330SubBlocks(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(100000, 0, 1004, 4, 65536, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), bs.N.Load())
+	assert.Equal(t, uint32(100), bs.NumSrcSym(0))
+	assert.Equal(t, uint16(2), bs.SubBlocks)
+	assert.Equal(t, uint32(504), bs.SubSymbolLen(0))
+	assert.Equal(t, uint32(500), bs.SubSymbolLen(1))
+
+	esis := fec.ESIRange{0: {{5, 5}}}
+	rl := esis.ToRangeList(bs, true)
+	assert.Equal(t, fec.RangeList{{2520, 3023}, {52900, 53399}}, rl)
+	er, err := fec.ESIRangeFromRangeList(bs, rl, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, esis, er)
+	er, err = fec.ESIRangeFromRangeList(bs, rl[:1], true, false)
+	require.NoError(t, err)
+	assert.Empty(t, er)
+	er, err = fec.ESIRangeFromRangeList(bs, rl[:1], true, true)
+	require.NoError(t, err)
+	assert.Equal(t, esis, er)
+
+	// the padding of the last symbol counts as received
+	last := fec.ESIRange{0: {{99, 99}}}
+	rl = last.ToRangeList(bs, true)
+	er, err = fec.ESIRangeFromRangeList(bs, rl, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, last, er)
+
+	obj := make([]byte, 100000)
+	rand.New(rand.NewSource(4)).Read(obj)
+	enc, err := fec.NewRaptorQEncoder(bs, 0, obj)
+	require.NoError(t, err)
+	sym, err := enc.Symbol(5)
+	require.NoError(t, err)
+	assert.Equal(t, append(append([]byte{}, obj[2520:3024]...), obj[52900:53400]...), sym)
+
+	dec, err := fec.NewRaptorQDecoder(bs, 0)
+	require.NoError(t, err)
+	for esi := uint32(10); esi < 112; esi++ {
+		sym, err := enc.Symbol(esi)
+		require.NoError(t, err)
+		require.NoError(t, dec.AddSymbol(esi, sym))
+	}
+	out, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, obj, out)
+}
+
+func TestBlockingStructure6330Partition(t *testing.T) {
+	cases := []struct {
+		F         uint64
+		T         uint16
+		Al        uint8
+		WS        uint64
+		Kt        uint64
+		Z, KL, ZL uint32
+		N         uint16
+		TL, TS    uint32
+	}{
+		// KL(250) = 56403, Z = ceil(100001/56403) = 2 blocks of 50001 and 50000
+		// symbols; KL(n) >= 50001 needs a K' of 50511, so 16MiB/(4*ceil(250/n)) >=
+		// 50511, n >= 4 and Partition[250, 4] gives sub-symbols of 63 and 62 Al.
+		{F: 100000500, T: 1000, Al: 4, WS: 16 << 20, Kt: 100001, Z: 2, KL: 50001, ZL: 1, N: 4, TL: 252, TS: 248},
+		// Z = ceil(4194304/56403) = 75 blocks, 4 of 55925 symbols; KL(n) >= 55925
+		// needs a K' of 56403, so 8MiB/(8*ceil(128/n)) >= 56403 and n >= 8.
+		{F: 4 << 30, T: 1024, Al: 8, WS: 8 << 20, Kt: 4194304, Z: 75, KL: 55925, ZL: 4, N: 8, TL: 128, TS: 128},
+	}
+	for _, c := range cases {
+		bs, err := fec.NewBlockingStructure6330(c.F, fec.RaptorQMaxSrcSymbols, c.T, c.Al, c.WS, 1)
+		require.NoError(t, err)
+		assert.Equal(t, c.Kt, bs.T, "F %d", c.F)
+		assert.Equal(t, c.Z, bs.N.Load(), "F %d", c.F)
+		assert.Equal(t, c.KL, bs.A.Load(), "F %d", c.F)
+		assert.Equal(t, c.KL-1, bs.ASmall.Load(), "F %d", c.F)
+		assert.Equal(t, c.ZL, bs.I.Load(), "F %d", c.F)
+		assert.Equal(t, c.N, bs.SubBlocks, "F %d", c.F)
+		assert.Equal(t, c.TL, bs.SubSymbolLen(0), "F %d", c.F)
+		assert.Equal(t, c.TS, bs.SubSymbolLen(c.N-1), "F %d", c.F)
+	}
+}
+
+func TestBlockingStructure6330DefaultBlockLen(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(100000500, 0, 1000, 4, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(fec.RaptorQDefaultSrcSymbols), bs.MaxSbLen)
+	assert.Equal(t, uint32(25), bs.N.Load(), "ceil(100001/4096)")
+	assert.LessOrEqual(t, bs.NumSrcSym(0), uint32(fec.RaptorQDefaultSrcSymbols))
+
+	bs, err = fec.NewBlockingStructureFromFECParam(api.FECParamType{Encoding: api.RAPTORQ_FEC_ENC_ID, SymbolLen: 1000, NumEsPerGroup: 1}, 100000500)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(fec.RaptorQDefaultSrcSymbols), bs.MaxSbLen)
+
+	_, err = fec.NewBlockingStructure6330(4<<30, 0, 1024, 8, 0, 1)
+	assert.ErrorContains(t, err, "source blocks exceed 255", "a larger block must be asked for")
+}
+
+func TestBlockingStructure3452(t *testing.T) {
+	bs, err := fec.NewBlockingStructure3452(api.SB_SYS_FEC_ENC_ID, api.ReedSolomonFECInst, 10000, 64, 100, 96, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Small-Block-Systematic", bs.Encoding.String())
+	assert.Equal(t, uint32(2), bs.N.Load())
+	assert.Equal(t, uint32(50), bs.NumSrcSym(1))
+	assert.Equal(t, uint32(75), bs.NumEncSym(1))
+
+	obj := make([]byte, 10000)
+	rand.New(rand.NewSource(5)).Read(obj)
+	for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+		block := obj[bs.SrcOffset(sbn) : bs.SrcOffset(sbn)+bs.SrcBlockSize(sbn)]
+		enc, err := fec.NewBlockEncoder(bs, sbn, block)
+		require.NoError(t, err)
+		dec, err := fec.NewBlockDecoder(bs, sbn)
+		require.NoError(t, err)
+		k := bs.NumSrcSym(sbn)
+		for esi := bs.NumEncSym(sbn) - k; esi < bs.NumEncSym(sbn); esi++ {
+			sym, err := enc.Symbol(esi)
+			require.NoError(t, err)
+			require.NoError(t, dec.AddSymbol(esi, sym))
+		}
+		out, err := dec.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, block, out)
+	}
+
+	o := bs.OTI()
+	assert.Equal(t, api.ReedSolomonFECInst, o.Instance)
+	got, err := o.BlockingStructure()
+	require.NoError(t, err)
+	assert.Equal(t, bs.NumEncSym(1), got.NumEncSym(1))
+
+	_, err = fec.NewBlockEncoder(&fec.BlockingStructure{Encoding: api.SB_SYS_FEC_ENC_ID, Instance: 1}, 0, nil)
+	assert.Error(t, err)
+	_, err = fec.NewBlockingStructure3452(api.SB_SYS_FEC_ENC_ID, 0, 10000, 64, 100, 70000, 1)
+	assert.Error(t, err)
+	_, err = fec.NewBlockingStructure3452(api.COM_FEC_ENC_ID, 0, 1<<20, 1, 2, 1, 1)
+	assert.Error(t, err, "more than 2^16 source blocks")
+	bs, err = fec.NewBlockingStructure3452(api.COM_FEC_ENC_ID, 3, 1<<20, 16, 2, 20, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(20), bs.NumEncSym(0))
+	require.NoError(t, bs.UpdateLength(1000, false))
+	assert.Equal(t, api.FECInstance(3), bs.Instance)
+	assert.Equal(t, uint32(32), bs.N.Load())
+}
+
+func TestBlockingStructureStream(t *testing.T) {
+	bs, err := fec.NewStreamBlockingStructure(10, 100, 1)
+	require.NoError(t, err)
+	assert.True(t, bs.Streamed())
+	assert.False(t, bs.Final())
+	assert.Equal(t, uint32(0), bs.N.Load())
+
+	offsets := map[uint32]uint64{}
+	for _, length := range []uint64{999, 1000, 2500, 4321} {
+		require.NoError(t, bs.UpdateLength(length, true))
+		assert.Equal(t, uint32(length/1000), bs.Sealed())
+		for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+			if off, ok := offsets[sbn]; ok {
+				assert.Equal(t, off, bs.SrcOffset(sbn), "offset of block %d moved", sbn)
+			}
+			offsets[sbn] = bs.SrcOffset(sbn)
+			assert.Equal(t, sbn, bs.SourceSBN(bs.SrcOffset(sbn)))
+			if bs.IsSealed(sbn) {
+				assert.Equal(t, uint32(10), bs.NumSrcSym(sbn))
+			}
+		}
+	}
+	assert.Equal(t, uint32(5), bs.N.Load())
+	assert.Equal(t, uint32(4), bs.Sealed())
+	assert.Equal(t, uint32(4), bs.NumSrcSym(4))
+	assert.Error(t, bs.UpdateLength(4000, true), "streams do not shrink")
+
+	require.NoError(t, bs.UpdateLength(4500, false))
+	assert.True(t, bs.Final())
+	assert.Equal(t, uint32(5), bs.Sealed())
+	assert.Equal(t, uint64(4000), bs.SrcOffset(4))
+	assert.Equal(t, uint32(5), bs.NumSrcSym(4))
+	require.NoError(t, bs.UpdateLength(4500, false))
+	assert.Error(t, bs.UpdateLength(5000, true))
+	assert.Error(t, fec.UpdateBlockingStructure5052(bs, 4500, 20, 100, 1, false))
+
+	// RFC 5052 balances the blocks once the length is known
+	bs, err = fec.NewBlockingStructure5052(4500, 10, 100, 1, false)
+	require.NoError(t, err)
+	assert.False(t, bs.Streamed())
+	assert.True(t, bs.Final())
+	assert.Equal(t, bs.N.Load(), bs.Sealed())
+	assert.Equal(t, uint32(9), bs.NumSrcSym(4))
+
+	_, err = fec.NewStreamBlockingStructure(10, 0, 1)
+	assert.Error(t, err)
+}
+
+func TestBlockingStructureRepair(t *testing.T) {
+	// 5 blocks of 10 and 5 of 9 symbols, 2 repair symbols each
+	bs, err := fec.NewBlockingStructure3452(api.COM_FEC_ENC_ID, 0, 9500, 10, 100, 12, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), bs.N.Load())
+	assert.Equal(t, uint64(600), bs.RprOffset(3))
+	assert.Equal(t, uint32(3), bs.RepairSBN(650))
+
+	// more repair on the short last block
+	require.NoError(t, bs.SetRepair([]uint32{2, 2, 2, 2, 2, 2, 2, 2, 2, 6}))
+	assert.Equal(t, uint64(1800), bs.RprOffset(9))
+	assert.Equal(t, uint64(600), bs.RprSize(9))
+	assert.Equal(t, uint64(2400), bs.RprOffset(10))
+	assert.Equal(t, uint32(9), bs.RepairSBN(2399))
+	assert.Equal(t, uint32(15), bs.NumEncSym(9))
+
+	// the blocks after the counts set keep MaxNumEs - MaxSbLen
+	require.NoError(t, bs.SetRepair([]uint32{5, 0}))
+	assert.Equal(t, uint32(0), bs.NumRprSym(1))
+	assert.Equal(t, uint32(2), bs.NumRprSym(2))
+	assert.Equal(t, []uint64{0, 500, 500, 700, 900}, []uint64{bs.RprOffset(0), bs.RprOffset(1), bs.RprOffset(2), bs.RprOffset(3), bs.RprOffset(4)})
+	for offset, sbn := range map[uint64]uint32{0: 0, 499: 0, 500: 2, 699: 2, 700: 3, 2099: 9} {
+		assert.Equal(t, sbn, bs.RepairSBN(offset), "offset %d", offset)
+	}
+	er := fec.ESIRange{0: {{11, 14}}, 2: {{10, 11}}, 3: {{10, 10}}}
+	rl := er.ToRangeList(bs, false)
+	assert.Equal(t, fec.RangeList{{Start: 100, End: 799}}, rl)
+	got, err := fec.ESIRangeFromRangeList(bs, rl, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, er, got)
+	p, err := fec.NewPlanner(bs, 0.5, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{5, 0, 5}, []uint32{p.Repair(0), p.Repair(1), p.Repair(2)})
+
+	require.NoError(t, bs.SetRepair(nil))
+	assert.Equal(t, uint64(200), bs.RprOffset(1))
+
+	// Reed-Solomon blocks scale n with k unless set
+	bs, err = fec.NewBlockingStructure5052(9500, 10, 100, 1, false)
+	require.NoError(t, err)
+	bs.Encoding, bs.MaxNumEs = api.RS_GF8_FEC_ENC_ID, 20
+	assert.Equal(t, uint32(9), bs.NumRprSym(9))
+	assert.Equal(t, uint64(50*100+9*100), bs.RprOffset(6))
+	assert.Equal(t, uint32(6), bs.RepairSBN(5900))
+	require.NoError(t, bs.SetRepair([]uint32{4}))
+	assert.Equal(t, uint64(400+40*100), bs.RprOffset(5))
+	enc, err := fec.NewReedSolomonEncoder(bs, 0, make([]byte, bs.SrcBlockSize(0)))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(14), enc.NumEncSym())
+	assert.Error(t, bs.SetRepair([]uint32{250}))
+	bs.Encoding, bs.MaxNumEs = api.COM_NO_C_FEC_ENC_ID, 10
+	assert.Error(t, bs.SetRepair([]uint32{1}))
+}
+
+func TestBlockingStructureRepairConcurrent(t *testing.T) {
+	bs, err := fec.NewBlockingStructure3452(api.COM_FEC_ENC_ID, 0, 9500, 10, 100, 12, 1)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 1000 {
+			counts := []uint32{2, 2, 2, 2, 2, 2, 2, 2, 2, 6}
+			if i%2 == 1 {
+				counts = nil
+			}
+			assert.NoError(t, bs.SetRepair(counts))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 1000 {
+			// the offset of the last block is that of either set of counts
+			assert.Equal(t, uint64(1800), bs.RprOffset(9))
+			assert.Contains(t, []uint64{2400, 2000}, bs.RprOffset(10))
+			assert.Contains(t, []uint32{2, 6}, bs.NumRprSym(9))
+		}
+	}()
+	wg.Wait()
+}
+
+func TestBlockingStructure5052Repair(t *testing.T) {
+	// 10 blocks of 10 and 9 symbols with MaxNumEs - MaxSbLen = 2 repair symbols
+	bs, err := fec.NewBlockingStructure5052(9500, 10, 100, 1, false)
+	require.NoError(t, err)
+	bs.MaxNumEs = 12
+	assert.Equal(t, uint32(2), bs.NumRprSym(0))
+	assert.Equal(t, uint64(600), bs.RprOffset(3))
+	require.NoError(t, bs.SetRepair([]uint32{2, 2, 2, 2, 2, 2, 2, 2, 2, 6}))
+	assert.Equal(t, uint32(15), bs.NumEncSym(9))
+
+	codec, err := fec.NewPayloadIDCodec(bs.Encoding, 0, bs)
+	require.NoError(t, err)
+	_, err = codec.Encode(9, 14)
+	assert.NoError(t, err)
+	_, err = codec.Encode(9, 15)
+	assert.Error(t, err)
+	p, err := fec.NewPlanner(bs, 1, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2, 6}, []uint32{p.Repair(0), p.Repair(9)})
+	tr, err := fec.NewTracker(1, bs)
+	require.NoError(t, err)
+	_, err = tr.AddSymbol(9, 14)
+	assert.NoError(t, err)
+	_, err = tr.AddSymbol(9, 15)
+	assert.Error(t, err)
+
+	// without MaxNumEs above MaxSbLen the blocks have no repair symbols
+	require.NoError(t, bs.SetRepair(nil))
+	bs.MaxNumEs = 10
+	assert.Error(t, bs.SetRepair([]uint32{1}))
+	p, err = fec.NewPlanner(bs, 1, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), p.Repair(0))
+	tr, err = fec.NewTracker(1, bs)
+	require.NoError(t, err)
+	_, err = tr.AddSymbol(0, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package fec
+
+import (
+	"fmt"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+var ErrNotDecodable = fmt.Errorf("not enough encoding symbols to decode source block")
+
+// BlockEncoder generates the encoding symbols of one source block laid out by a
+// BlockingStructure. Source symbols have ESIs 0..NumSrcSym()-1 and repair symbols
+// follow them.
+type BlockEncoder interface {
+	NumSrcSym() uint32
+	Symbol(esi uint32) ([]byte, error)
+}
+
+// BlockDecoder recovers one source block from the encoding symbols received for it.
+type BlockDecoder interface {
+	AddSymbol(esi uint32, symbol []byte) error
+	Received() uint32
+	Decodable() bool
+	Decode() ([]byte, error)
+}
+
+var (
+	_ BlockEncoder = (*RaptorQEncoder)(nil)
+	_ BlockDecoder = (*RaptorQDecoder)(nil)
+	_ BlockEncoder = (*ReedSolomonEncoder)(nil)
+	_ BlockDecoder = (*ReedSolomonDecoder)(nil)
+)
+
+// reedSolomonCode reports whether bs uses the RFC 5510 Reed-Solomon code over
+// GF(2^8), including the Reed-Solomon instance of the Small Block Systematic scheme.
+func reedSolomonCode(bs *BlockingStructure) bool {
+	switch bs.Encoding {
+	case api.RS_GEN_FEC_ENC_ID, api.RS_GF8_FEC_ENC_ID:
+		return true
+	case api.SB_SYS_FEC_ENC_ID:
+		return bs.Instance == api.ReedSolomonFECInst
+	}
+	return false
+}
+
+// NewBlockEncoder returns the encoder of source block sbn for the FEC scheme of bs.
+func NewBlockEncoder(bs *BlockingStructure, sbn uint32, block []byte) (BlockEncoder, error) {
+	if bs == nil {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	if bs.Encoding == api.RAPTORQ_FEC_ENC_ID {
+		return NewRaptorQEncoder(bs, sbn, block)
+	}
+	if reedSolomonCode(bs) {
+		return NewReedSolomonEncoder(bs, sbn, block)
+	}
+	return nil, fmt.Errorf("no codec for FEC encoding %d instance %d", bs.Encoding, bs.Instance)
+}
+
+// NewBlockDecoder returns the decoder of source block sbn for the FEC scheme of bs.
+func NewBlockDecoder(bs *BlockingStructure, sbn uint32) (BlockDecoder, error) {
+	if bs == nil {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	if bs.Encoding == api.RAPTORQ_FEC_ENC_ID {
+		return NewRaptorQDecoder(bs, sbn)
+	}
+	if reedSolomonCode(bs) {
+		return NewReedSolomonDecoder(bs, sbn)
+	}
+	return nil, fmt.Errorf("no codec for FEC encoding %d instance %d", bs.Encoding, bs.Instance)
+}
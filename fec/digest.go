@@ -0,0 +1,121 @@
+package fec
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// Repr-Digest algorithms of the IANA Hash Algorithms for HTTP Digest Fields
+// registry, RFC 9530 section 5, that can be verified.
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+)
+
+// digestHashes returns the hash of the supported digest algorithms.
+var digestHashes = map[string]func() hash.Hash{
+	DigestSHA256: sha256.New,
+	DigestSHA512: sha512.New,
+}
+
+// ReprDigest is a Repr-Digest field value, RFC 9530: the digests of a
+// representation keyed by algorithm, e.g. "sha-256=:X48E9q...PE=:".
+type ReprDigest map[string][]byte
+
+// ParseReprDigest parses a Repr-Digest field value, a structured field
+// dictionary of byte sequences, RFC 8941 section 3.2. Parameters are ignored.
+func ParseReprDigest(s string) (ReprDigest, error) {
+	d := make(ReprDigest)
+	for _, member := range strings.Split(s, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		alg, value, ok := strings.Cut(member, "=")
+		value, _, _ = strings.Cut(value, ";")
+		if !ok || alg == "" || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return nil, fmt.Errorf("invalid repr-digest member %q", member)
+		}
+		digest, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid repr-digest %s: %w", alg, err)
+		}
+		d[strings.ToLower(alg)] = digest
+	}
+	if len(d) == 0 {
+		return nil, fmt.Errorf("empty repr-digest")
+	}
+	return d, nil
+}
+
+// String returns the field value, algorithms in lexical order.
+func (d ReprDigest) String() string {
+	algs := make([]string, 0, len(d))
+	for alg := range d {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	members := make([]string, len(algs))
+	for i, alg := range algs {
+		members[i] = alg + "=:" + base64.StdEncoding.EncodeToString(d[alg]) + ":"
+	}
+	return strings.Join(members, ", ")
+}
+
+func (d ReprDigest) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *ReprDigest) UnmarshalText(text []byte) (err error) {
+	*d, err = ParseReprDigest(string(text))
+	return err
+}
+
+// Digests computes the MD5 and the Repr-Digest of a representation written to it.
+type Digests struct {
+	md5  hash.Hash
+	algs map[string]hash.Hash
+}
+
+// NewDigests returns the digests of the algorithms given, sha-256 if none.
+// Unsupported algorithms are ignored.
+func NewDigests(algs ...string) *Digests {
+	if len(algs) == 0 {
+		algs = []string{DigestSHA256}
+	}
+	d := &Digests{md5: md5.New(), algs: make(map[string]hash.Hash)}
+	for _, alg := range algs {
+		if h, ok := digestHashes[alg]; ok {
+			d.algs[alg] = h()
+		}
+	}
+	return d
+}
+
+func (d *Digests) Write(p []byte) (int, error) {
+	d.md5.Write(p)
+	for _, h := range d.algs {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// MD5 returns the Content-MD5 of the octets written.
+func (d *Digests) MD5() MD5 {
+	return d.md5.Sum(nil)
+}
+
+// ReprDigest returns the Repr-Digest of the octets written.
+func (d *Digests) ReprDigest() ReprDigest {
+	rd := make(ReprDigest, len(d.algs))
+	for alg, h := range d.algs {
+		rd[alg] = h.Sum(nil)
+	}
+	return rd
+}
@@ -0,0 +1,249 @@
+package fec
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Set operations on ESIRange. An empty RangeList stands for all source symbols
+// of its block: Union and Intersection keep such whole blocks as they are, while
+// Subtract and Contains expand them to 0..k-1 with the blocking structure. A
+// block left without ESIs is removed, never kept as an empty RangeList.
+
+// SBNs returns the SBNs of er in ascending order.
+func (er ESIRange) SBNs() []uint32 {
+	sbns := make([]uint32, 0, len(er))
+	for sbn := range er {
+		sbns = append(sbns, sbn)
+	}
+	sort.Slice(sbns, func(i, j int) bool { return sbns[i] < sbns[j] })
+	return sbns
+}
+
+// Clone returns a deep copy of er.
+func (er ESIRange) Clone() ESIRange {
+	out := make(ESIRange, len(er))
+	for sbn, rl := range er {
+		out[sbn] = append(RangeList{}, rl...)
+	}
+	return out
+}
+
+// wholeBlock returns the source ESIs of block sbn when rl stands for all of
+// them, rl itself otherwise. Without bs whole blocks stay empty.
+func wholeBlock(bs *BlockingStructure, sbn uint32, rl RangeList) RangeList {
+	if len(rl) > 0 || bs == nil {
+		return rl
+	}
+	if k := int64(bs.NumSrcSym(sbn)); k > 0 {
+		return RangeList{{0, k - 1}}
+	}
+	return rl
+}
+
+// Union returns the ESIs in er or in ol.
+func (er ESIRange) Union(ol ESIRange) ESIRange {
+	out := er.Clone()
+	for sbn, ll := range ol {
+		rl, ok := out[sbn]
+		switch {
+		case !ok:
+			out[sbn] = append(RangeList{}, ll...)
+		case len(rl) == 0:
+		case len(ll) == 0:
+			out[sbn] = RangeList{}
+		default:
+			out[sbn] = rl.Union(ll)
+		}
+	}
+	return out
+}
+
+// Intersection returns the ESIs both in er and in ol.
+func (er ESIRange) Intersection(ol ESIRange) ESIRange {
+	out := make(ESIRange)
+	for sbn, rl := range er {
+		ll, ok := ol[sbn]
+		switch {
+		case !ok:
+		case len(rl) == 0:
+			out[sbn] = append(RangeList{}, ll...)
+		case len(ll) == 0:
+			out[sbn] = append(RangeList{}, rl...)
+		default:
+			if il := rl.Intersection(ll); len(il) > 0 {
+				out[sbn] = il
+			}
+		}
+	}
+	return out
+}
+
+// Subtract returns the ESIs of er not in ol, e.g. a repair request less what was
+// already resent. bs expands the whole blocks of er; without it they are only
+// removed by whole blocks of ol.
+func (er ESIRange) Subtract(ol ESIRange, bs *BlockingStructure) ESIRange {
+	out := make(ESIRange)
+	for sbn, rl := range er {
+		ll, ok := ol[sbn]
+		switch {
+		case !ok:
+			out[sbn] = append(RangeList{}, rl...)
+		case len(ll) == 0:
+		default:
+			rl = wholeBlock(bs, sbn, rl)
+			if len(rl) == 0 {
+				out[sbn] = RangeList{}
+			} else if sl := rl.Subtract(ll); len(sl) > 0 {
+				out[sbn] = sl
+			}
+		}
+	}
+	return out
+}
+
+// Contains reports whether every ESI of ol is in er. bs expands the whole blocks
+// of ol; without it they are only contained in whole blocks of er.
+func (er ESIRange) Contains(ol ESIRange, bs *BlockingStructure) bool {
+	for sbn, ll := range ol {
+		rl, ok := er[sbn]
+		switch {
+		case !ok:
+			return false
+		case len(rl) == 0:
+		default:
+			ll = wholeBlock(bs, sbn, ll)
+			if len(ll) == 0 || !rl.Contains(ll) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Equal reports whether er and ol hold the same ESIs, see Contains.
+func (er ESIRange) Equal(ol ESIRange, bs *BlockingStructure) bool {
+	return er.Contains(ol, bs) && ol.Contains(er, bs)
+}
+
+// esiRangeVersion is the first octet of the binary encoding of an ESIRange.
+const esiRangeVersion = 1
+
+// MarshalBinary encodes er compactly as unsigned varints: the version, the
+// number of blocks, then for every block in ascending order the SBN less the
+// previous SBN plus one, its number of ranges, zero for a whole block, and for
+// every range its start less the previous end plus one and its length less one.
+func (er ESIRange) MarshalBinary() ([]byte, error) {
+	b := []byte{esiRangeVersion}
+	b = binary.AppendUvarint(b, uint64(len(er)))
+	next := uint64(0)
+	for _, sbn := range er.SBNs() {
+		rl := er[sbn]
+		for i, r := range rl {
+			if r.Start < 0 || r.End < r.Start || i > 0 && r.Start <= rl[i-1].End {
+				return nil, fmt.Errorf("esi range of sbn %d not sorted: %s", sbn, rl)
+			}
+		}
+		rl = flatten(append(RangeList{}, rl...))
+		b = binary.AppendUvarint(b, uint64(sbn)-next)
+		next = uint64(sbn) + 1
+		b = binary.AppendUvarint(b, uint64(len(rl)))
+		start := int64(0)
+		for _, r := range rl {
+			b = binary.AppendUvarint(b, uint64(r.Start-start))
+			b = binary.AppendUvarint(b, uint64(r.End-r.Start))
+			start = r.End + 1
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes an ESIRange encoded by MarshalBinary.
+func (er *ESIRange) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != esiRangeVersion {
+		return fmt.Errorf("%w: unknown esi range encoding", parseError)
+	}
+	b := data[1:]
+	uvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, fmt.Errorf("%w: truncated esi range", parseError)
+		}
+		b = b[n:]
+		return v, nil
+	}
+	blocks, err := uvarint()
+	if err != nil {
+		return err
+	}
+	if blocks > uint64(len(b)) {
+		return fmt.Errorf("%w: %d blocks in %d octets", parseError, blocks, len(b))
+	}
+	out := make(ESIRange, blocks)
+	next := uint64(0)
+	for ; blocks > 0; blocks-- {
+		delta, err := uvarint()
+		if err != nil {
+			return err
+		}
+		if delta > math.MaxUint32 || next+delta > math.MaxUint32 {
+			return fmt.Errorf("%w: sbn overflow", parseError)
+		}
+		sbn := next + delta
+		next = sbn + 1
+		n, err := uvarint()
+		if err != nil {
+			return err
+		}
+		if n > uint64(len(b)) {
+			return fmt.Errorf("%w: %d ranges in %d octets", parseError, n, len(b))
+		}
+		rl := make(RangeList, 0, n)
+		start := uint64(0)
+		for ; n > 0; n-- {
+			gap, err := uvarint()
+			if err != nil {
+				return err
+			}
+			length, err := uvarint()
+			if err != nil {
+				return err
+			}
+			if start > math.MaxInt64 || gap > math.MaxInt64-start || length > math.MaxInt64-start-gap {
+				return fmt.Errorf("%w: esi overflow", parseError)
+			}
+			r := Range{int64(start + gap), int64(start + gap + length)}
+			rl = append(rl, r)
+			start = uint64(r.End) + 1
+		}
+		out[uint32(sbn)] = rl
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("%w: %d trailing octets after esi range", parseError, len(b))
+	}
+	*er = out
+	return nil
+}
+
+// Scan implements the database/sql Scanner interface, from the binary encoding.
+func (er *ESIRange) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return er.UnmarshalBinary(src)
+	case nil:
+		*er = nil
+		return nil
+	}
+	return fmt.Errorf("invalid ESIRange type %T", src)
+}
+
+// Value implements the database/sql/driver Valuer interface.
+func (er ESIRange) Value() (driver.Value, error) {
+	if er == nil {
+		return nil, nil
+	}
+	return er.MarshalBinary()
+}
@@ -0,0 +1,73 @@
+package fec_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestESIRangeAlgebra(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(10000, 10, 100, 1, false)
+	require.NoError(t, err)
+	a := fec.ESIRange{0: {}, 1: {{2, 4}}, 2: {{0, 1}}}
+	b := fec.ESIRange{0: {{3, 5}}, 1: {{4, 6}}, 3: {}}
+
+	assert.Equal(t, fec.ESIRange{0: {}, 1: {{2, 6}}, 2: {{0, 1}}, 3: {}}, a.Union(b))
+	assert.Equal(t, fec.ESIRange{0: {{3, 5}}, 1: {{4, 4}}}, a.Intersection(b))
+	assert.Equal(t, fec.ESIRange{0: {{0, 2}, {6, 9}}, 1: {{2, 3}}, 2: {{0, 1}}}, a.Subtract(b, bs))
+	assert.Equal(t, fec.ESIRange{0: {}, 1: {{2, 3}}, 2: {{0, 1}}}, a.Subtract(b, nil), "whole blocks kept without bs")
+	assert.Equal(t, fec.ESIRange{1: {{5, 6}}, 3: {}}, b.Subtract(a, bs))
+	assert.Equal(t, fec.ESIRange{0: {{0, 1}}}, fec.ESIRange{0: {{0, 1}}, 1: {{2, 3}}}.Subtract(fec.ESIRange{1: {}}, nil), "emptied blocks are removed")
+
+	assert.True(t, a.Union(b).Contains(a, bs))
+	assert.True(t, a.Contains(a.Intersection(b), bs))
+	assert.False(t, a.Contains(b, bs))
+	assert.True(t, fec.ESIRange{0: {{0, 9}}}.Contains(fec.ESIRange{0: {}}, bs))
+	assert.False(t, fec.ESIRange{0: {{0, 9}}}.Contains(fec.ESIRange{0: {}}, nil))
+	assert.True(t, fec.ESIRange{0: {{0, 9}}}.Equal(fec.ESIRange{0: {}}, bs))
+
+	// the operands are left untouched
+	assert.Equal(t, fec.ESIRange{0: {}, 1: {{2, 4}}, 2: {{0, 1}}}, a)
+	assert.Equal(t, []uint32{0, 1, 2}, a.SBNs())
+}
+
+func TestESIRangeBinary(t *testing.T) {
+	rnd := rand.New(rand.NewSource(14))
+	for i := 0; i < 100; i++ {
+		er := fec.ESIRange{}
+		for j := rnd.Intn(20); j > 0; j-- {
+			sbn := uint32(rnd.Int63n(1 << 32))
+			if rnd.Intn(4) == 0 {
+				er[sbn] = fec.RangeList{}
+			} else {
+				er[sbn] = fec.MakeRandRangeList(0, 1<<20, 1+rnd.Intn(30))
+			}
+		}
+		b, err := er.MarshalBinary()
+		require.NoError(t, err)
+		var got fec.ESIRange
+		require.NoError(t, got.UnmarshalBinary(b))
+		require.Equal(t, er, got)
+	}
+
+	er := fec.ESIRange{0: {}, 1: {{2, 4}, {5, 5}, {10, 200}}, 300: {{0, 0}}}
+	b, err := er.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 3, 0, 0, 0, 2, 2, 3, 4, 190, 1, 170, 2, 1, 0, 0}, b)
+
+	var got fec.ESIRange
+	require.NoError(t, got.Scan(b))
+	assert.Equal(t, fec.ESIRange{0: {}, 1: {{2, 5}, {10, 200}}, 300: {{0, 0}}}, got)
+	v, err := got.Value()
+	require.NoError(t, err)
+	assert.Equal(t, b, v)
+
+	for _, invalid := range [][]byte{nil, {2, 0}, {1, 1}, {1, 1, 0, 1, 0}, {1, 0, 0}, {1, 5, 0}, {1, 2, 0xff, 0xff, 0xff, 0xff, 0x0f, 0, 1, 0}} {
+		assert.Error(t, got.UnmarshalBinary(invalid), "%v", invalid)
+	}
+	_, err = fec.ESIRange{0: {{5, 6}, {1, 2}}}.MarshalBinary()
+	assert.Error(t, err)
+}
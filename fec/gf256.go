@@ -0,0 +1,75 @@
+package fec
+
+// Octet arithmetic over GF(2^8) as defined in RFC 6330 section 5.7, using the
+// irreducible polynomial x^8 + x^4 + x^3 + x^2 + 1 with generator alpha = 2.
+// The same field is used by the RFC 5510 Reed-Solomon scheme over GF(2^8).
+
+const gf256Poly = 0x11d
+
+var (
+	octExp [510]byte      // OCT_EXP, doubled so that octExp[log(u)+log(v)] needs no modulo
+	octLog [256]int       // OCT_LOG, octLog[0] is undefined
+	octMul [256][256]byte // full multiplication table used by the row operations
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		octExp[i] = byte(x)
+		octExp[i+255] = byte(x)
+		octLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gf256Poly
+		}
+	}
+	for u := 1; u < 256; u++ {
+		for v := 1; v < 256; v++ {
+			octMul[u][v] = octExp[octLog[u]+octLog[v]]
+		}
+	}
+}
+
+func octMulByte(u, v byte) byte {
+	return octMul[u][v]
+}
+
+func octDiv(u, v byte) byte {
+	if u == 0 {
+		return 0
+	}
+	return octExp[octLog[u]-octLog[v]+255]
+}
+
+// octAlphaPow returns alpha^^i.
+func octAlphaPow(i int) byte {
+	return octExp[i%255]
+}
+
+// octAddMul computes dst += c * src element-wise over GF(2^8).
+func octAddMul(dst, src []byte, c byte) {
+	switch c {
+	case 0:
+		return
+	case 1:
+		for i := range src {
+			dst[i] ^= src[i]
+		}
+	default:
+		mul := &octMul[c]
+		for i := range src {
+			dst[i] ^= mul[src[i]]
+		}
+	}
+}
+
+// octScale computes dst = c * dst element-wise over GF(2^8).
+func octScale(dst []byte, c byte) {
+	if c == 1 {
+		return
+	}
+	mul := &octMul[c]
+	for i := range dst {
+		dst[i] = mul[dst[i]]
+	}
+}
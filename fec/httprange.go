@@ -0,0 +1,211 @@
+package fec
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP range requests, IETF RFC 9110 section 14 and If-Range, section 13.1.5.
+//
+// A Range of a request keeps End == -1 for an open range "first-" and has
+// Start == -1 for a suffix range "-length", End holding the suffix length. Resolve
+// turns them into closed ranges of a representation.
+
+const RangeUnitBytes = "bytes"
+
+var ErrRangeNotSatisfiable = fmt.Errorf("range not satisfiable")
+
+// parsePos parses a non-negative 1*DIGIT position.
+func parsePos(s string) (int64, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid range position %q", s)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ParseRange parses a Range header field value such as "bytes=0-499, -500, 9500-".
+// A range with a last position before its first one invalidates the header.
+func ParseRange(header string) (RangeList, error) {
+	unit, set, ok := strings.Cut(strings.TrimSpace(header), "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), RangeUnitBytes) {
+		return nil, fmt.Errorf("invalid range unit in %q", header)
+	}
+	var rl RangeList
+	for _, spec := range strings.Split(set, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		r := Range{Start: -1, End: -1}
+		var err error
+		if first != "" {
+			if r.Start, err = parsePos(first); err != nil {
+				return nil, err
+			}
+		}
+		if last != "" {
+			if r.End, err = parsePos(last); err != nil {
+				return nil, err
+			}
+		}
+		if first == "" && last == "" || first != "" && last != "" && r.End < r.Start {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		rl = append(rl, r)
+	}
+	if len(rl) == 0 {
+		return nil, fmt.Errorf("empty range set in %q", header)
+	}
+	return rl, nil
+}
+
+// FormatRange returns the Range header field value requesting rl.
+func FormatRange(rl RangeList) string {
+	return RangeUnitBytes + "=" + rl.StringHTTP()
+}
+
+// Resolve returns the ranges of rl satisfiable for a representation of length
+// octets, sorted and coalesced: open ranges end with the representation, suffix
+// ranges cover its last octets and ranges starting beyond its end are dropped.
+func (rl RangeList) Resolve(length int64) (RangeList, error) {
+	out := make(RangeList, 0, len(rl))
+	for _, r := range rl {
+		switch {
+		case r.Start < 0:
+			if r.End > 0 && length > 0 {
+				out = append(out, Range{max(length-r.End, 0), length - 1})
+			}
+		case r.Start < length:
+			if r.End < 0 || r.End >= length {
+				r.End = length - 1
+			}
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrRangeNotSatisfiable
+	}
+	return flatten(out), nil
+}
+
+// ContentRange is a Content-Range header field value, RFC 9110 section 14.4.
+type ContentRange struct {
+	Range       Range // the range enclosed, unused if Unsatisfied
+	Length      int64 // complete length, -1 if unknown
+	Unsatisfied bool  // "bytes */length" of a 416 response
+}
+
+// NewContentRange returns the Content-Range of the closed range r of a
+// representation of length octets, -1 if unknown.
+func NewContentRange(r Range, length int64) ContentRange {
+	return ContentRange{Range: r, Length: length}
+}
+
+func (c ContentRange) String() string {
+	length := "*"
+	if c.Length >= 0 {
+		length = strconv.FormatInt(c.Length, 10)
+	}
+	if c.Unsatisfied {
+		return RangeUnitBytes + " */" + length
+	}
+	return fmt.Sprintf("%s %d-%d/%s", RangeUnitBytes, c.Range.Start, c.Range.End, length)
+}
+
+func (c ContentRange) MarshalText() ([]byte, error) {
+	if c.Unsatisfied && c.Length < 0 {
+		return nil, fmt.Errorf("unsatisfied content range without complete length")
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses "bytes first-last/length", "bytes first-last/*" and
+// "bytes */length".
+func (c *ContentRange) UnmarshalText(text []byte) error {
+	s := string(text)
+	unit, resp, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok || !strings.EqualFold(unit, RangeUnitBytes) {
+		return fmt.Errorf("invalid content range unit in %q", s)
+	}
+	rng, length, ok := strings.Cut(strings.TrimSpace(resp), "/")
+	if !ok {
+		return fmt.Errorf("invalid content range %q", s)
+	}
+	out := ContentRange{Length: -1}
+	var err error
+	if length != "*" {
+		if out.Length, err = parsePos(length); err != nil {
+			return err
+		}
+	}
+	if rng == "*" {
+		if out.Length < 0 {
+			return fmt.Errorf("unsatisfied content range without complete length %q", s)
+		}
+		out.Unsatisfied = true
+		*c = out
+		return nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return fmt.Errorf("invalid content range %q", s)
+	}
+	if out.Range.Start, err = parsePos(first); err != nil {
+		return err
+	}
+	if out.Range.End, err = parsePos(last); err != nil {
+		return err
+	}
+	if out.Range.End < out.Range.Start || out.Length >= 0 && out.Range.End >= out.Length {
+		return fmt.Errorf("invalid content range %q", s)
+	}
+	*c = out
+	return nil
+}
+
+// IfRange is an If-Range header field value: an entity tag or an HTTP-date.
+type IfRange struct {
+	ETag string // entity tag with its quotes and W/ prefix, empty for a date
+	Date time.Time
+}
+
+// ParseIfRange parses an If-Range header field value.
+func ParseIfRange(s string) (IfRange, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, `W/"`) {
+		opaque := strings.TrimPrefix(s, "W/")
+		if len(opaque) < 2 || !strings.HasSuffix(opaque, `"`) || strings.Contains(opaque[1:len(opaque)-1], `"`) {
+			return IfRange{}, fmt.Errorf("invalid entity tag %q", s)
+		}
+		return IfRange{ETag: s}, nil
+	}
+	date, err := http.ParseTime(s)
+	if err != nil {
+		return IfRange{}, fmt.Errorf("invalid if-range %q: %w", s, err)
+	}
+	return IfRange{Date: date}, nil
+}
+
+func (i IfRange) String() string {
+	if i.ETag != "" {
+		return i.ETag
+	}
+	return i.Date.UTC().Format(http.TimeFormat)
+}
+
+// Match reports whether the Range of a request conditioned by i applies to the
+// representation with the given entity tag and last modification date. Entity
+// tags use the strong comparison so weak ones never match, dates must be equal.
+func (i IfRange) Match(etag string, lastModified time.Time) bool {
+	if i.ETag != "" {
+		return !strings.HasPrefix(i.ETag, "W/") && i.ETag == etag
+	}
+	return !lastModified.IsZero() && i.Date.Equal(lastModified.Truncate(time.Second))
+}
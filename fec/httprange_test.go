@@ -0,0 +1,109 @@
+package fec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	rl, err := fec.ParseRange("bytes=0-499, 1000-, -500,,")
+	require.NoError(t, err)
+	assert.Equal(t, fec.RangeList{{Start: 0, End: 499}, {Start: 1000, End: -1}, {Start: -1, End: 500}}, rl)
+	assert.Equal(t, "bytes=0-499,1000-,-500", fec.FormatRange(rl))
+
+	resolved, err := rl.Resolve(10000)
+	require.NoError(t, err)
+	assert.Equal(t, fec.RangeList{{Start: 0, End: 499}, {Start: 1000, End: 9999}}, resolved)
+	resolved, err = rl.Resolve(300)
+	require.NoError(t, err)
+	assert.Equal(t, fec.RangeList{{Start: 0, End: 299}}, resolved)
+
+	rl, err = fec.ParseRange("Bytes=500-600")
+	require.NoError(t, err)
+	_, err = rl.Resolve(500)
+	assert.ErrorIs(t, err, fec.ErrRangeNotSatisfiable)
+	rl, err = fec.ParseRange("bytes=-0")
+	require.NoError(t, err)
+	_, err = rl.Resolve(500)
+	assert.ErrorIs(t, err, fec.ErrRangeNotSatisfiable)
+
+	for _, invalid := range []string{"", "bytes=", "items=0-1", "bytes=5-1", "bytes=-", "bytes=1", "bytes=a-b", "bytes=+1-2"} {
+		_, err = fec.ParseRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRangeTextRoundTrip(t *testing.T) {
+	for text, r := range map[string]fec.Range{
+		"-500":    {Start: -1, End: 500},
+		"1000-":   {Start: 1000, End: -1},
+		"0-499":   {Start: 0, End: 499},
+		"500-999": {Start: 500, End: 999},
+	} {
+		out, err := r.MarshalText()
+		require.NoError(t, err)
+		assert.Equal(t, text, string(out))
+		var parsed fec.Range
+		require.NoError(t, parsed.UnmarshalText(out), text)
+		assert.Equal(t, r, parsed, text)
+	}
+	assert.Equal(t, int64(500), fec.Range{Start: -1, End: 500}.Count())
+
+	rl, err := fec.ParseRange("bytes=-100, 0-9, -500, 5-19")
+	require.NoError(t, err)
+	resolved, err := rl.Resolve(1000)
+	require.NoError(t, err)
+	assert.Equal(t, fec.RangeList{{Start: 0, End: 19}, {Start: 500, End: 999}}, resolved)
+}
+
+func TestContentRangeHeader(t *testing.T) {
+	cases := []struct {
+		s  string
+		cr fec.ContentRange
+	}{
+		{"bytes 0-499/1234", fec.ContentRange{Range: fec.Range{Start: 0, End: 499}, Length: 1234}},
+		{"bytes 42-1233/*", fec.ContentRange{Range: fec.Range{Start: 42, End: 1233}, Length: -1}},
+		{"bytes */1234", fec.ContentRange{Length: 1234, Unsatisfied: true}},
+	}
+	for _, c := range cases {
+		var cr fec.ContentRange
+		require.NoError(t, cr.UnmarshalText([]byte(c.s)), c.s)
+		assert.Equal(t, c.cr, cr)
+		text, err := cr.MarshalText()
+		require.NoError(t, err)
+		assert.Equal(t, c.s, string(text))
+	}
+	assert.Equal(t, "bytes 0-9/*", fec.NewContentRange(fec.Range{Start: 0, End: 9}, -1).String())
+	for _, invalid := range []string{"bytes */*", "bytes 5-1/10", "bytes 0-10/10", "bytes 0-1", "bits 0-1/2", "bytes -1/2"} {
+		var cr fec.ContentRange
+		assert.Error(t, cr.UnmarshalText([]byte(invalid)), invalid)
+	}
+}
+
+func TestIfRange(t *testing.T) {
+	ir, err := fec.ParseIfRange(`"xyzzy"`)
+	require.NoError(t, err)
+	assert.True(t, ir.Match(`"xyzzy"`, time.Time{}))
+	assert.False(t, ir.Match(`W/"xyzzy"`, time.Time{}))
+	assert.False(t, ir.Match(`"other"`, time.Time{}))
+
+	ir, err = fec.ParseIfRange(`W/"xyzzy"`)
+	require.NoError(t, err)
+	assert.False(t, ir.Match(`W/"xyzzy"`, time.Time{}), "weak tags never match")
+
+	ir, err = fec.ParseIfRange("Sun, 06 Nov 1994 08:49:37 GMT")
+	require.NoError(t, err)
+	modified := time.Date(1994, 11, 6, 8, 49, 37, 500, time.UTC)
+	assert.True(t, ir.Match(`"xyzzy"`, modified))
+	assert.False(t, ir.Match("", modified.Add(time.Second)))
+	assert.Equal(t, "Sun, 06 Nov 1994 08:49:37 GMT", ir.String())
+
+	for _, invalid := range []string{`"a"b"`, `"open`, "yesterday"} {
+		_, err = fec.ParseIfRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
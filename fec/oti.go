@@ -0,0 +1,486 @@
+package fec
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// FDT attributes carrying the FEC OTI, RFC 6726 section 3.4.2
+const (
+	FDTAttrTransferLen    = "Transfer-Length"
+	FDTAttrEncodingID     = "FEC-OTI-FEC-Encoding-ID"
+	FDTAttrInstanceID     = "FEC-OTI-FEC-Instance-ID"
+	FDTAttrMaxSbLen       = "FEC-OTI-Maximum-Source-Block-Length"
+	FDTAttrESLen          = "FEC-OTI-Encoding-Symbol-Length"
+	FDTAttrMaxNumEs       = "FEC-OTI-Max-Number-of-Encoding-Symbols"
+	FDTAttrSchemeSpecific = "FEC-OTI-Scheme-Specific-Info"
+)
+
+// OTI is the FEC Object Transmission Information of an object, RFC 5052 section 4.2:
+// the Common FEC OTI elements and the Scheme-Specific ones of the FEC Encoding ID.
+//
+// The encoded form is the Common FEC OTI followed by the Scheme-Specific FEC OTI
+// as laid out in the EXT_FTI header of each scheme, without HET and HEL:
+//
+//	0   (RFC 5445)  L(48) Reserved(16) E(16) B(32)
+//	2   (RFC 5510)  L(48) m(8) G(8) E(16) B(16) max_n(16)
+//	5   (RFC 5510)  L(48) E(16) B(8) max_n(8)
+//	6   (RFC 6330)  F(40) Reserved(8) T(16) Z(8) N(16) Al(8)
+//	128 (RFC 3926)  L(48) FEC Instance ID(16) E(16) B(32)
+//	129 (RFC 3926)  L(48) FEC Instance ID(16) E(16) B(16) max_n(16)
+//	130 (RFC 3926)  L(48) FEC Instance ID(16) E(16) B(32)
+type OTI struct {
+	Encoding    api.FECEncoding
+	Instance    api.FECInstance // FEC Instance ID of the under-specified schemes 128-130
+	TransferLen uint64          // L, F for RaptorQ
+	ESLen       uint16          // E, T for RaptorQ
+	MaxSbLen    uint32          // B, not carried by RaptorQ
+	MaxNumEs    uint32          // max_n, carried by schemes 2, 5 and 129
+	M           uint8           // RFC 5510 finite field size parameter, scheme 2
+	G           uint8           // RFC 5510 number of encoding symbols per packet, scheme 2
+	Z           uint8           // RFC 6330 number of source blocks
+	SubBlocks   uint16          // RFC 6330 number of sub-blocks N
+	Al          uint8           // RFC 6330 symbol alignment parameter
+}
+
+func (o *OTI) String() string {
+	if o == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("enc=%d,inst=%d,len=%d,esLen=%d,maxSb=%d,maxEs=%d", o.Encoding, o.Instance, o.TransferLen, o.ESLen, o.MaxSbLen, o.MaxNumEs)
+}
+
+func otiField(name string, v, max uint64) error {
+	if v > max {
+		return fmt.Errorf("oti: %s %d exceeds %d", name, v, max)
+	}
+	return nil
+}
+
+func putUint48(b []byte, v uint64) {
+	binary.BigEndian.PutUint16(b, uint16(v>>32))
+	binary.BigEndian.PutUint32(b[2:], uint32(v))
+}
+
+func uint48(b []byte) uint64 {
+	return uint64(binary.BigEndian.Uint16(b))<<32 | uint64(binary.BigEndian.Uint32(b[2:]))
+}
+
+// otiLen returns the length of the encoded FEC OTI of the scheme.
+func otiLen(encoding api.FECEncoding) (int, error) {
+	switch encoding {
+	case api.RS_GF8_FEC_ENC_ID:
+		return 10, nil
+	case api.RAPTORQ_FEC_ENC_ID:
+		return 12, nil
+	case api.COM_NO_C_FEC_ENC_ID, api.RS_GEN_FEC_ENC_ID, api.SB_LB_E_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		return 14, nil
+	}
+	return 0, fmt.Errorf("oti: unsupported FEC encoding %d", encoding)
+}
+
+// validate checks that every element fits its field in the encoded form.
+func (o *OTI) validate() error {
+	switch o.Encoding {
+	case api.COM_NO_C_FEC_ENC_ID, api.SB_LB_E_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		return otiField("transfer length", o.TransferLen, 1<<48-1)
+	case api.RS_GEN_FEC_ENC_ID:
+		if o.M < 2 || o.M > 16 {
+			return fmt.Errorf("oti: m %d out of range [2, 16]", o.M)
+		}
+		return errors.Join(
+			otiField("transfer length", o.TransferLen, 1<<48-1),
+			otiField("max source block length", uint64(o.MaxSbLen), 1<<o.M-1),
+			otiField("max number of encoding symbols", uint64(o.MaxNumEs), 1<<o.M-1))
+	case api.RS_GF8_FEC_ENC_ID:
+		return errors.Join(
+			otiField("transfer length", o.TransferLen, 1<<48-1),
+			otiField("max source block length", uint64(o.MaxSbLen), math.MaxUint8),
+			otiField("max number of encoding symbols", uint64(o.MaxNumEs), math.MaxUint8))
+	case api.RAPTORQ_FEC_ENC_ID:
+		if o.Z == 0 || o.SubBlocks == 0 || o.Al == 0 || o.ESLen%uint16(o.Al) != 0 {
+			return fmt.Errorf("oti: invalid raptorq Z=%d N=%d Al=%d T=%d", o.Z, o.SubBlocks, o.Al, o.ESLen)
+		}
+		return otiField("transfer length", o.TransferLen, raptorQMaxTransferLen)
+	case api.SB_SYS_FEC_ENC_ID:
+		return errors.Join(
+			otiField("transfer length", o.TransferLen, 1<<48-1),
+			otiField("max source block length", uint64(o.MaxSbLen), math.MaxUint16),
+			otiField("max number of encoding symbols", uint64(o.MaxNumEs), math.MaxUint16))
+	}
+	return fmt.Errorf("oti: unsupported FEC encoding %d", o.Encoding)
+}
+
+// MarshalBinary returns the Common FEC OTI followed by the Scheme-Specific FEC OTI.
+func (o *OTI) MarshalBinary() ([]byte, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	n, _ := otiLen(o.Encoding)
+	b := make([]byte, n)
+	switch o.Encoding {
+	case api.COM_NO_C_FEC_ENC_ID, api.SB_LB_E_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		putUint48(b, o.TransferLen)
+		if o.Encoding != api.COM_NO_C_FEC_ENC_ID {
+			binary.BigEndian.PutUint16(b[6:], uint16(o.Instance))
+		}
+		binary.BigEndian.PutUint16(b[8:], o.ESLen)
+		binary.BigEndian.PutUint32(b[10:], o.MaxSbLen)
+	case api.RS_GEN_FEC_ENC_ID:
+		putUint48(b, o.TransferLen)
+		b[6], b[7] = o.M, o.G
+		binary.BigEndian.PutUint16(b[8:], o.ESLen)
+		binary.BigEndian.PutUint16(b[10:], uint16(o.MaxSbLen))
+		binary.BigEndian.PutUint16(b[12:], uint16(o.MaxNumEs))
+	case api.RS_GF8_FEC_ENC_ID:
+		putUint48(b, o.TransferLen)
+		binary.BigEndian.PutUint16(b[6:], o.ESLen)
+		b[8], b[9] = uint8(o.MaxSbLen), uint8(o.MaxNumEs)
+	case api.RAPTORQ_FEC_ENC_ID:
+		putUint48(b, o.TransferLen<<8)
+		binary.BigEndian.PutUint16(b[6:], o.ESLen)
+		copy(b[8:], o.SchemeSpecificInfo())
+	case api.SB_SYS_FEC_ENC_ID:
+		putUint48(b, o.TransferLen)
+		binary.BigEndian.PutUint16(b[6:], uint16(o.Instance))
+		binary.BigEndian.PutUint16(b[8:], o.ESLen)
+		binary.BigEndian.PutUint16(b[10:], uint16(o.MaxSbLen))
+		binary.BigEndian.PutUint16(b[12:], uint16(o.MaxNumEs))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the FEC OTI of the scheme already set in o.Encoding.
+func (o *OTI) UnmarshalBinary(b []byte) error {
+	n, err := otiLen(o.Encoding)
+	if err != nil {
+		return err
+	}
+	if len(b) != n {
+		return fmt.Errorf("oti: %d octets, expected %d for FEC encoding %d", len(b), n, o.Encoding)
+	}
+	*o = OTI{Encoding: o.Encoding}
+	switch o.Encoding {
+	case api.COM_NO_C_FEC_ENC_ID, api.SB_LB_E_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		o.TransferLen = uint48(b)
+		if o.Encoding != api.COM_NO_C_FEC_ENC_ID {
+			o.Instance = api.FECInstance(binary.BigEndian.Uint16(b[6:]))
+		}
+		o.ESLen = binary.BigEndian.Uint16(b[8:])
+		o.MaxSbLen = binary.BigEndian.Uint32(b[10:])
+	case api.RS_GEN_FEC_ENC_ID:
+		o.TransferLen = uint48(b)
+		o.M, o.G = b[6], b[7]
+		o.ESLen = binary.BigEndian.Uint16(b[8:])
+		o.MaxSbLen = uint32(binary.BigEndian.Uint16(b[10:]))
+		o.MaxNumEs = uint32(binary.BigEndian.Uint16(b[12:]))
+	case api.RS_GF8_FEC_ENC_ID:
+		o.TransferLen = uint48(b)
+		o.ESLen = binary.BigEndian.Uint16(b[6:])
+		o.MaxSbLen, o.MaxNumEs = uint32(b[8]), uint32(b[9])
+	case api.RAPTORQ_FEC_ENC_ID:
+		o.TransferLen = uint48(b) >> 8
+		o.ESLen = binary.BigEndian.Uint16(b[6:])
+		if err := o.SetSchemeSpecificInfo(b[8:]); err != nil {
+			return err
+		}
+	case api.SB_SYS_FEC_ENC_ID:
+		o.TransferLen = uint48(b)
+		o.Instance = api.FECInstance(binary.BigEndian.Uint16(b[6:]))
+		o.ESLen = binary.BigEndian.Uint16(b[8:])
+		o.MaxSbLen = uint32(binary.BigEndian.Uint16(b[10:]))
+		o.MaxNumEs = uint32(binary.BigEndian.Uint16(b[12:]))
+	}
+	return o.validate()
+}
+
+// DecodeOTI decodes the binary FEC OTI of the given scheme.
+func DecodeOTI(encoding api.FECEncoding, b []byte) (*OTI, error) {
+	o := &OTI{Encoding: encoding}
+	if err := o.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Base64 returns the base64 encoding of MarshalBinary.
+func (o *OTI) Base64() (string, error) {
+	b, err := o.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecodeOTIBase64 decodes the base64 encoded binary FEC OTI of the given scheme.
+func DecodeOTIBase64(encoding api.FECEncoding, s string) (*OTI, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("oti: %w", err)
+	}
+	return DecodeOTI(encoding, b)
+}
+
+// SchemeSpecificInfo returns the Scheme-Specific FEC OTI elements that are not
+// Common FEC OTI elements, as carried by FEC-OTI-Scheme-Specific-Info and the
+// SDP a=FEC-OTI-extension attribute, or nil if the scheme has none.
+func (o *OTI) SchemeSpecificInfo() []byte {
+	switch o.Encoding {
+	case api.RS_GEN_FEC_ENC_ID:
+		return []byte{o.M, o.G}
+	case api.RAPTORQ_FEC_ENC_ID:
+		b := []byte{o.Z, 0, 0, o.Al}
+		binary.BigEndian.PutUint16(b[1:], o.SubBlocks)
+		return b
+	}
+	return nil
+}
+
+// SetSchemeSpecificInfo sets the elements returned by SchemeSpecificInfo.
+func (o *OTI) SetSchemeSpecificInfo(b []byte) error {
+	switch o.Encoding {
+	case api.RS_GEN_FEC_ENC_ID:
+		if len(b) != 2 {
+			return fmt.Errorf("oti: scheme-specific info is %d octets, expected 2", len(b))
+		}
+		o.M, o.G = b[0], b[1]
+	case api.RAPTORQ_FEC_ENC_ID:
+		if len(b) != 4 {
+			return fmt.Errorf("oti: scheme-specific info is %d octets, expected 4", len(b))
+		}
+		o.Z, o.SubBlocks, o.Al = b[0], binary.BigEndian.Uint16(b[1:]), b[3]
+	default:
+		if len(b) != 0 {
+			return fmt.Errorf("oti: FEC encoding %d has no scheme-specific info", o.Encoding)
+		}
+	}
+	return nil
+}
+
+// FDTAttrs returns the FDT File attributes carrying the OTI.
+func (o *OTI) FDTAttrs() []xml.Attr {
+	attr := func(name string, v uint64) xml.Attr {
+		return xml.Attr{Name: xml.Name{Local: name}, Value: strconv.FormatUint(v, 10)}
+	}
+	attrs := []xml.Attr{
+		attr(FDTAttrTransferLen, o.TransferLen),
+		attr(FDTAttrEncodingID, uint64(o.Encoding)),
+	}
+	if o.Encoding >= api.SB_LB_E_FEC_ENC_ID {
+		attrs = append(attrs, attr(FDTAttrInstanceID, uint64(o.Instance)))
+	}
+	if o.Encoding != api.RAPTORQ_FEC_ENC_ID {
+		attrs = append(attrs, attr(FDTAttrMaxSbLen, uint64(o.MaxSbLen)))
+	}
+	attrs = append(attrs, attr(FDTAttrESLen, uint64(o.ESLen)))
+	switch o.Encoding {
+	case api.RS_GEN_FEC_ENC_ID, api.RS_GF8_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID:
+		attrs = append(attrs, attr(FDTAttrMaxNumEs, uint64(o.MaxNumEs)))
+	}
+	if info := o.SchemeSpecificInfo(); info != nil {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: FDTAttrSchemeSpecific}, Value: base64.StdEncoding.EncodeToString(info)})
+	}
+	return attrs
+}
+
+// OTIFromFDTAttrs collects the OTI from FDT attributes, typically the ones of the
+// FDT-Instance followed by the ones of the File so that the latter take precedence.
+// Other attributes are ignored and a missing FEC-OTI-FEC-Encoding-ID stands for 0.
+func OTIFromFDTAttrs(attrs []xml.Attr) (*OTI, error) {
+	o := &OTI{}
+	var info string
+	for _, a := range attrs {
+		var dst any
+		switch a.Name.Local {
+		case FDTAttrTransferLen:
+			dst = &o.TransferLen
+		case FDTAttrEncodingID:
+			dst = &o.Encoding
+		case FDTAttrInstanceID:
+			dst = &o.Instance
+		case FDTAttrMaxSbLen:
+			dst = &o.MaxSbLen
+		case FDTAttrESLen:
+			dst = &o.ESLen
+		case FDTAttrMaxNumEs:
+			dst = &o.MaxNumEs
+		case FDTAttrSchemeSpecific:
+			info = a.Value
+			continue
+		default:
+			continue
+		}
+		if err := parseOTIAttr(dst, a.Value); err != nil {
+			return nil, fmt.Errorf("oti: %s: %w", a.Name.Local, err)
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(info)
+	if err != nil {
+		return nil, fmt.Errorf("oti: %s: %w", FDTAttrSchemeSpecific, err)
+	}
+	if err = o.SetSchemeSpecificInfo(b); err != nil {
+		return nil, err
+	}
+	if o.Encoding == api.RS_GF8_FEC_ENC_ID && o.MaxNumEs == 0 {
+		o.MaxNumEs = o.MaxSbLen
+	}
+	if err = o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func parseOTIAttr(dst any, s string) error {
+	var bits int
+	switch dst.(type) {
+	case *api.FECEncoding:
+		bits = 8
+	case *api.FECInstance, *uint16:
+		bits = 16
+	case *uint32:
+		bits = 32
+	default:
+		bits = 64
+	}
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return err
+	}
+	switch dst := dst.(type) {
+	case *api.FECEncoding:
+		*dst = api.FECEncoding(v)
+	case *api.FECInstance:
+		*dst = api.FECInstance(v)
+	case *uint16:
+		*dst = uint16(v)
+	case *uint32:
+		*dst = uint32(v)
+	case *uint64:
+		*dst = v
+	}
+	return nil
+}
+
+// OTI returns the FEC OTI describing bs.
+func (bs *BlockingStructure) OTI() *OTI {
+	o := &OTI{
+		Encoding:    bs.Encoding,
+		Instance:    bs.Instance,
+		TransferLen: bs.TransferLen.Load(),
+		ESLen:       bs.ESLen,
+		MaxSbLen:    bs.MaxSbLen,
+		MaxNumEs:    bs.MaxNumEs,
+	}
+	switch bs.Encoding {
+	case api.RS_GEN_FEC_ENC_ID:
+		o.M, o.G = 8, uint8(bs.NumEsPerGroup)
+	case api.RAPTORQ_FEC_ENC_ID:
+		o.Z, o.SubBlocks, o.Al = uint8(bs.N.Load()), bs.SubBlocks, bs.Al
+	}
+	return o
+}
+
+// BlockingStructure returns the blocking structure of the object described by o.
+// For RaptorQ the partitioning is taken as is from Z and N; without a maximum
+// source block length the larger source block length stands for it. Reed-Solomon
+// over GF(2^^m) needs m = 8, the only field of the codec.
+func (o *OTI) BlockingStructure() (*BlockingStructure, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if o.Encoding == api.RAPTORQ_FEC_ENC_ID {
+		bs := &BlockingStructure{NumEsPerGroup: 1}
+		bs.partition6330(o.TransferLen, o.ESLen, o.Al, uint32(o.Z), o.SubBlocks)
+		bs.MaxSbLen = max(o.MaxSbLen, bs.A.Load())
+		bs.MaxNumEs = max(o.MaxNumEs, bs.MaxSbLen)
+		return bs, nil
+	}
+	if o.Encoding >= api.SB_LB_E_FEC_ENC_ID {
+		return NewBlockingStructure3452(o.Encoding, o.Instance, o.TransferLen, o.MaxSbLen, o.ESLen, o.MaxNumEs, 1)
+	}
+	numEsPerGroup := uint32(1)
+	if o.Encoding == api.RS_GEN_FEC_ENC_ID {
+		if o.M != 8 {
+			return nil, fmt.Errorf("oti: m %d unsupported, the Reed-Solomon codec is over GF(2^^8)", o.M)
+		}
+		if o.G > 0 {
+			numEsPerGroup = uint32(o.G)
+		}
+	}
+	bs, err := NewBlockingStructure5052(o.TransferLen, o.MaxSbLen, o.ESLen, numEsPerGroup, false)
+	if err != nil {
+		return nil, err
+	}
+	bs.Encoding = o.Encoding
+	bs.MaxNumEs = max(o.MaxNumEs, o.MaxSbLen)
+	return bs, nil
+}
+
+// FECParam returns the FEC parameters of o. The redundancy is the ratio of repair
+// to source symbols per source block.
+func (o *OTI) FECParam() api.FECParamType {
+	p := api.FECParamType{
+		Encoding:       o.Encoding,
+		Instance:       o.Instance,
+		SymbolLen:      o.ESLen,
+		MaxSrcBlockLen: o.MaxSbLen,
+		NumEsPerGroup:  1,
+	}
+	if o.Encoding == api.RS_GEN_FEC_ENC_ID && o.G > 0 {
+		p.NumEsPerGroup = uint32(o.G)
+	}
+	if o.MaxSbLen > 0 && o.MaxNumEs > o.MaxSbLen {
+		p.Redundancy = float64(o.MaxNumEs-o.MaxSbLen) / float64(o.MaxSbLen)
+	}
+	return p
+}
+
+// NewBlockingStructureFromFECParam lays out an object of transferLen octets with
+// the FEC parameters p, MaxNumEs following from the redundancy. RaptorQ uses the
+// alignment Al = 4 when the symbol length allows it and no sub-blocking, and
+// blocks of at most RaptorQDefaultSrcSymbols without a MaxSrcBlockLen.
+func NewBlockingStructureFromFECParam(p api.FECParamType, transferLen uint64) (*BlockingStructure, error) {
+	var bs *BlockingStructure
+	var err error
+	maxNumEs := func(maxSbLen uint32) uint32 {
+		return maxSbLen + uint32(math.Ceil(float64(maxSbLen)*p.Redundancy))
+	}
+	switch p.Encoding {
+	case api.RAPTORQ_FEC_ENC_ID:
+		al := uint8(4)
+		if p.SymbolLen%4 != 0 {
+			al = 1
+		}
+		bs, err = NewBlockingStructure6330(transferLen, p.MaxSrcBlockLen, p.SymbolLen, al, 0, p.NumEsPerGroup)
+	case api.SB_LB_E_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		return NewBlockingStructure3452(p.Encoding, p.Instance, transferLen, p.MaxSrcBlockLen, p.SymbolLen, maxNumEs(p.MaxSrcBlockLen), p.NumEsPerGroup)
+	default:
+		bs, err = NewBlockingStructure5052(transferLen, p.MaxSrcBlockLen, p.SymbolLen, p.NumEsPerGroup, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bs.Encoding = p.Encoding
+	bs.MaxNumEs = maxNumEs(bs.MaxSbLen)
+	return bs, nil
+}
+
+// NewOTI returns the FEC OTI of an object of transferLen octets sent with the FEC parameters p.
+func NewOTI(p api.FECParamType, transferLen uint64) (*OTI, error) {
+	bs, err := NewBlockingStructureFromFECParam(p, transferLen)
+	if err != nil {
+		return nil, err
+	}
+	o := bs.OTI()
+	if err = o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
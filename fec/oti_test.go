@@ -0,0 +1,114 @@
+package fec_test
+
+import (
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTIRoundTrip(t *testing.T) {
+	cases := []fec.OTI{
+		{Encoding: api.COM_NO_C_FEC_ENC_ID, TransferLen: 1<<40 + 5, ESLen: 1428, MaxSbLen: 64},
+		{Encoding: api.RS_GEN_FEC_ENC_ID, TransferLen: 123456, ESLen: 1024, MaxSbLen: 200, MaxNumEs: 255, M: 8, G: 2},
+		{Encoding: api.RS_GF8_FEC_ENC_ID, TransferLen: 123456, ESLen: 1024, MaxSbLen: 200, MaxNumEs: 250},
+		{Encoding: api.RAPTORQ_FEC_ENC_ID, TransferLen: 10485760, ESLen: 1432, Z: 13, SubBlocks: 2, Al: 4},
+		{Encoding: api.SB_LB_E_FEC_ENC_ID, Instance: 3, TransferLen: 99, ESLen: 10, MaxSbLen: 70000},
+		{Encoding: api.SB_SYS_FEC_ENC_ID, Instance: api.ReedSolomonFECInst, TransferLen: 99, ESLen: 10, MaxSbLen: 20, MaxNumEs: 30},
+		{Encoding: api.COM_FEC_ENC_ID, Instance: 7, TransferLen: 99, ESLen: 10, MaxSbLen: 20},
+	}
+	for _, o := range cases {
+		b, err := o.MarshalBinary()
+		require.NoError(t, err, o.String())
+		got, err := fec.DecodeOTI(o.Encoding, b)
+		require.NoError(t, err, o.String())
+		assert.Equal(t, o, *got)
+
+		s, err := o.Base64()
+		require.NoError(t, err)
+		got, err = fec.DecodeOTIBase64(o.Encoding, s)
+		require.NoError(t, err)
+		assert.Equal(t, o, *got)
+
+		got, err = fec.OTIFromFDTAttrs(o.FDTAttrs())
+		require.NoError(t, err)
+		assert.Equal(t, o, *got)
+
+		_, err = fec.DecodeOTI(o.Encoding, b[1:])
+		assert.Error(t, err)
+	}
+}
+
+func TestOTIRaptorQ(t *testing.T) {
+	o := fec.OTI{Encoding: api.RAPTORQ_FEC_ENC_ID, TransferLen: 0x0102030405, ESLen: 0x0608, Z: 9, SubBlocks: 0x0a0b, Al: 4}
+	b, err := o.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 0, 6, 8, 9, 0x0a, 0x0b, 4}, b)
+	assert.Equal(t, []byte{9, 0x0a, 0x0b, 4}, o.SchemeSpecificInfo())
+
+	bs, err := fec.NewBlockingStructure6330(100000, 0, 1004, 4, 65536, 1)
+	require.NoError(t, err)
+	o = *bs.OTI()
+	assert.Equal(t, uint8(1), o.Z)
+	assert.Equal(t, uint16(2), o.SubBlocks)
+	got, err := o.BlockingStructure()
+	require.NoError(t, err)
+	assert.Equal(t, bs.N.Load(), got.N.Load())
+	assert.Equal(t, bs.NumSrcSym(0), got.NumSrcSym(0))
+	assert.Equal(t, bs.SubBlocks, got.SubBlocks)
+	assert.Equal(t, bs.SubSymbolLen(1), got.SubSymbolLen(1))
+
+	o.Al = 3
+	_, err = o.MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestOTIReedSolomon(t *testing.T) {
+	// RFC 5510 section 4.2.3.1: L(48) m(8) G(8) E(16) B(16) max_n(16)
+	o := fec.OTI{Encoding: api.RS_GEN_FEC_ENC_ID, TransferLen: 0x010203040506, M: 8, G: 2, ESLen: 0x0400, MaxSbLen: 200, MaxNumEs: 255}
+	b, err := o.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 8, 2, 0x04, 0x00, 0, 200, 0, 255}, b)
+	got, err := fec.DecodeOTI(api.RS_GEN_FEC_ENC_ID, b)
+	require.NoError(t, err)
+	assert.Equal(t, o, *got)
+
+	o.TransferLen = 100000
+	bs, err := o.BlockingStructure()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), bs.NumEsPerGroup)
+
+	// m = 12 is a valid OTI but not a field of the codec
+	o.M = 12
+	_, err = o.MarshalBinary()
+	require.NoError(t, err)
+	_, err = o.BlockingStructure()
+	assert.ErrorContains(t, err, "m 12 unsupported")
+}
+
+func TestOTIFECParam(t *testing.T) {
+	p := api.FECParamType{Encoding: api.RS_GF8_FEC_ENC_ID, SymbolLen: 1000, MaxSrcBlockLen: 200, NumEsPerGroup: 1, Redundancy: 0.25}
+	o, err := fec.NewOTI(p, 1000000)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(250), o.MaxNumEs)
+	assert.Equal(t, p, o.FECParam())
+
+	bs, err := o.BlockingStructure()
+	require.NoError(t, err)
+	assert.Equal(t, api.RS_GF8_FEC_ENC_ID, bs.Encoding)
+	assert.Equal(t, uint32(5), bs.N.Load())
+	assert.Equal(t, uint32(250), bs.MaxNumEs)
+
+	p.Redundancy = 0.5
+	_, err = fec.NewOTI(p, 1000000)
+	assert.Error(t, err, "max_n 300 does not fit in 8 bits")
+
+	p = api.FECParamType{Encoding: api.RAPTORQ_FEC_ENC_ID, SymbolLen: 1430, MaxSrcBlockLen: 600, NumEsPerGroup: 1}
+	o, err = fec.NewOTI(p, 10485760)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), o.Al)
+	assert.Equal(t, uint8(13), o.Z)
+	assert.Equal(t, p, o.FECParam())
+}
@@ -0,0 +1,203 @@
+package fec
+
+import (
+	"fmt"
+	"sort"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// PayloadID is the FEC Payload ID of an encoding symbol.
+type PayloadID struct {
+	SBN         uint32
+	ESI         uint32
+	SrcBlockLen uint32 // source block length in symbols, only carried by scheme 129
+}
+
+// PayloadIDCodec packs and unpacks the FEC Payload ID of a FEC scheme:
+//
+//	0   (RFC 5445)  SBN(16) ESI(16)
+//	2   (RFC 5510)  SBN(24) ESI(8), m = 8
+//	5   (RFC 5510)  SBN(24) ESI(8)
+//	6   (RFC 6330)  SBN(8)  ESI(24)
+//	128 (RFC 3452)  SBN(32) ESI(32)
+//	129 (RFC 3452)  SBN(32) Source Block Length(16) ESI(16)
+//	130 (RFC 3695)  SBN(16) ESI(16)
+//
+// With a blocking structure, SBNs and ESIs are also checked against the object:
+// the SBN must address one of its source blocks and the ESI one of the encoding
+// symbols the scheme can generate for that block.
+type PayloadIDCodec struct {
+	Encoding api.FECEncoding
+	Instance api.FECInstance
+	bs       *BlockingStructure
+	sbnBits  uint8
+	sblBits  uint8
+	esiBits  uint8
+}
+
+// NewPayloadIDCodec returns the payload ID codec of the scheme, bs may be nil to
+// only check the field widths. Fully-specified schemes have no FEC Instance ID.
+func NewPayloadIDCodec(encoding api.FECEncoding, instance api.FECInstance, bs *BlockingStructure) (*PayloadIDCodec, error) {
+	c := &PayloadIDCodec{Encoding: encoding, Instance: instance, bs: bs}
+	switch encoding {
+	case api.COM_NO_C_FEC_ENC_ID, api.COM_FEC_ENC_ID:
+		c.sbnBits, c.esiBits = 16, 16
+	case api.RS_GEN_FEC_ENC_ID, api.RS_GF8_FEC_ENC_ID:
+		c.sbnBits, c.esiBits = 24, 8
+	case api.RAPTORQ_FEC_ENC_ID:
+		c.sbnBits, c.esiBits = 8, 24
+	case api.SB_LB_E_FEC_ENC_ID:
+		c.sbnBits, c.esiBits = 32, 32
+	case api.SB_SYS_FEC_ENC_ID:
+		c.sbnBits, c.sblBits, c.esiBits = 32, 16, 16
+	default:
+		return nil, fmt.Errorf("payload id: unsupported FEC encoding %d", encoding)
+	}
+	if encoding < api.SB_LB_E_FEC_ENC_ID && instance != 0 {
+		return nil, fmt.Errorf("payload id: FEC encoding %d has no instance %d", encoding, instance)
+	}
+	return c, nil
+}
+
+// Len returns the length of the FEC Payload ID in octets.
+func (c *PayloadIDCodec) Len() int {
+	return int(c.sbnBits+c.sblBits+c.esiBits) / 8
+}
+
+// maxESI returns the largest ESI of source block sbn. Encoding 0 generates the
+// NumRprSym repair symbols of RFC 5052 blocking, none if MaxNumEs is MaxSbLen.
+func (c *PayloadIDCodec) maxESI(sbn uint32) uint64 {
+	field := uint64(1)<<c.esiBits - 1
+	if c.bs == nil {
+		return field
+	}
+	switch c.Encoding {
+	case api.COM_NO_C_FEC_ENC_ID, api.RS_GEN_FEC_ENC_ID, api.RS_GF8_FEC_ENC_ID, api.SB_SYS_FEC_ENC_ID:
+		return min(uint64(c.bs.NumEncSym(sbn)), field+1) - 1
+	}
+	return field
+}
+
+func (c *PayloadIDCodec) check(id PayloadID) error {
+	if uint64(id.SBN) > uint64(1)<<c.sbnBits-1 {
+		return fmt.Errorf("payload id: sbn %d exceeds %d bits", id.SBN, c.sbnBits)
+	}
+	if c.bs != nil && id.SBN >= c.bs.N.Load() {
+		return fmt.Errorf("payload id: sbn %d out of range, N=%d", id.SBN, c.bs.N.Load())
+	}
+	if max := c.maxESI(id.SBN); uint64(id.ESI) > max {
+		return fmt.Errorf("payload id: esi %d of sbn %d exceeds %d", id.ESI, id.SBN, max)
+	}
+	if c.sblBits == 0 {
+		return nil
+	}
+	if uint64(id.SrcBlockLen) > uint64(1)<<c.sblBits-1 {
+		return fmt.Errorf("payload id: source block length %d exceeds %d bits", id.SrcBlockLen, c.sblBits)
+	}
+	if c.bs != nil && id.SrcBlockLen != c.bs.NumSrcSym(id.SBN) {
+		return fmt.Errorf("payload id: source block length %d of sbn %d, expected %d", id.SrcBlockLen, id.SBN, c.bs.NumSrcSym(id.SBN))
+	}
+	return nil
+}
+
+// PayloadID returns the payload ID of (sbn, esi), filling in the source block
+// length from the blocking structure when the scheme carries it.
+func (c *PayloadIDCodec) PayloadID(sbn, esi uint32) PayloadID {
+	id := PayloadID{SBN: sbn, ESI: esi}
+	if c.sblBits > 0 && c.bs != nil {
+		id.SrcBlockLen = c.bs.NumSrcSym(sbn)
+	}
+	return id
+}
+
+// Append appends the encoded payload ID to dst.
+func (c *PayloadIDCodec) Append(dst []byte, id PayloadID) ([]byte, error) {
+	if err := c.check(id); err != nil {
+		return dst, err
+	}
+	v := uint64(id.SBN)<<(c.sblBits+c.esiBits) | uint64(id.SrcBlockLen)<<c.esiBits | uint64(id.ESI)
+	for i := c.Len() - 1; i >= 0; i-- {
+		dst = append(dst, byte(v>>(8*i)))
+	}
+	return dst, nil
+}
+
+// Encode returns the encoded payload ID of (sbn, esi).
+func (c *PayloadIDCodec) Encode(sbn, esi uint32) ([]byte, error) {
+	return c.Append(make([]byte, 0, c.Len()), c.PayloadID(sbn, esi))
+}
+
+// Decode decodes the payload ID at the start of b.
+func (c *PayloadIDCodec) Decode(b []byte) (PayloadID, error) {
+	if len(b) < c.Len() {
+		return PayloadID{}, fmt.Errorf("payload id: %d octets, expected %d", len(b), c.Len())
+	}
+	var v uint64
+	for _, o := range b[:c.Len()] {
+		v = v<<8 | uint64(o)
+	}
+	id := PayloadID{
+		SBN:         uint32(v >> (c.sblBits + c.esiBits)),
+		SrcBlockLen: uint32(v>>c.esiBits) & (1<<c.sblBits - 1),
+		ESI:         uint32(v & (1<<c.esiBits - 1)),
+	}
+	return id, c.check(id)
+}
+
+// AppendESIRange appends the payload IDs of every encoding symbol of er to dst in
+// SBN then ESI order. An empty RangeList stands for all source symbols of the
+// block, which requires a blocking structure.
+func (c *PayloadIDCodec) AppendESIRange(dst []byte, er ESIRange) ([]byte, error) {
+	sbns := make([]uint32, 0, len(er))
+	for sbn := range er {
+		sbns = append(sbns, sbn)
+	}
+	sort.Slice(sbns, func(i, j int) bool { return sbns[i] < sbns[j] })
+	var err error
+	for _, sbn := range sbns {
+		rl := er[sbn]
+		if len(rl) == 0 {
+			if c.bs == nil {
+				return dst, fmt.Errorf("payload id: whole block %d without blocking structure", sbn)
+			}
+			rl = RangeList{{0, int64(c.bs.NumSrcSym(sbn)) - 1}}
+		}
+		for _, r := range rl {
+			if r.Start < 0 || r.End < r.Start {
+				return dst, fmt.Errorf("payload id: invalid esi range %s of sbn %d", r, sbn)
+			}
+			for esi := r.Start; esi <= r.End; esi++ {
+				if dst, err = c.Append(dst, c.PayloadID(sbn, uint32(esi))); err != nil {
+					return dst, err
+				}
+			}
+		}
+	}
+	return dst, nil
+}
+
+// ESIRange decodes the concatenated payload IDs of b into the ESIs received per SBN.
+func (c *PayloadIDCodec) ESIRange(b []byte) (ESIRange, error) {
+	if len(b)%c.Len() != 0 {
+		return nil, fmt.Errorf("payload id: %d octets is not a multiple of %d", len(b), c.Len())
+	}
+	er := ESIRange{}
+	for ; len(b) > 0; b = b[c.Len():] {
+		id, err := c.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		rl := er[id.SBN]
+		if n := len(rl); n > 0 && rl[n-1].End+1 == int64(id.ESI) {
+			rl[n-1].End++
+		} else {
+			rl = append(rl, Range{int64(id.ESI), int64(id.ESI)})
+		}
+		er[id.SBN] = rl
+	}
+	for sbn, rl := range er {
+		er[sbn] = flatten(rl)
+	}
+	return er, nil
+}
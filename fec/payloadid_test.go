@@ -0,0 +1,106 @@
+package fec_test
+
+import (
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadIDWire(t *testing.T) {
+	cases := []struct {
+		encoding api.FECEncoding
+		id       fec.PayloadID
+		wire     []byte
+	}{
+		{api.COM_NO_C_FEC_ENC_ID, fec.PayloadID{SBN: 0x0102, ESI: 0x0304}, []byte{1, 2, 3, 4}},
+		{api.RS_GF8_FEC_ENC_ID, fec.PayloadID{SBN: 0x010203, ESI: 0x04}, []byte{1, 2, 3, 4}},
+		{api.RAPTORQ_FEC_ENC_ID, fec.PayloadID{SBN: 0x01, ESI: 0x020304}, []byte{1, 2, 3, 4}},
+		{api.SB_LB_E_FEC_ENC_ID, fec.PayloadID{SBN: 0x01020304, ESI: 0x05060708}, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{api.SB_SYS_FEC_ENC_ID, fec.PayloadID{SBN: 0x01020304, SrcBlockLen: 0x0506, ESI: 0x0708}, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{api.COM_FEC_ENC_ID, fec.PayloadID{SBN: 0x0102, ESI: 0x0304}, []byte{1, 2, 3, 4}},
+	}
+	for _, c := range cases {
+		codec, err := fec.NewPayloadIDCodec(c.encoding, 0, nil)
+		require.NoError(t, err)
+		b, err := codec.Append(nil, c.id)
+		require.NoError(t, err)
+		assert.Equal(t, c.wire, b, "encoding %d", c.encoding)
+		id, err := codec.Decode(b)
+		require.NoError(t, err)
+		assert.Equal(t, c.id, id)
+		_, err = codec.Decode(b[1:])
+		assert.Error(t, err)
+	}
+
+	codec, err := fec.NewPayloadIDCodec(api.RAPTORQ_FEC_ENC_ID, 0, nil)
+	require.NoError(t, err)
+	_, err = codec.Encode(256, 0)
+	assert.Error(t, err)
+	_, err = fec.NewPayloadIDCodec(api.RAPTORQ_FEC_ENC_ID, 1, nil)
+	assert.Error(t, err)
+}
+
+func TestPayloadIDBounds(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(10000, 64, 100, 1, false)
+	require.NoError(t, err)
+	bs.Encoding = api.RS_GF8_FEC_ENC_ID
+	bs.MaxNumEs = 80
+	codec, err := fec.NewPayloadIDCodec(bs.Encoding, 0, bs)
+	require.NoError(t, err)
+	_, err = codec.Encode(bs.N.Load()-1, 0)
+	assert.NoError(t, err)
+	_, err = codec.Encode(bs.N.Load(), 0)
+	assert.Error(t, err)
+	k := bs.NumSrcSym(0)
+	n := k * bs.MaxNumEs / bs.MaxSbLen
+	_, err = codec.Encode(0, n-1)
+	assert.NoError(t, err)
+	_, err = codec.Encode(0, n)
+	assert.Error(t, err)
+
+	codec, err = fec.NewPayloadIDCodec(api.SB_SYS_FEC_ENC_ID, api.ReedSolomonFECInst, bs)
+	require.NoError(t, err)
+	b, err := codec.Encode(1, 60)
+	require.NoError(t, err)
+	id, err := codec.Decode(b)
+	require.NoError(t, err)
+	assert.Equal(t, bs.NumSrcSym(1), id.SrcBlockLen)
+	b[5]++
+	_, err = codec.Decode(b)
+	assert.Error(t, err)
+
+	// RFC 5052 blocking has MaxNumEs - MaxSbLen repair symbols per block
+	bs.Encoding = api.COM_NO_C_FEC_ENC_ID
+	codec, err = fec.NewPayloadIDCodec(bs.Encoding, 0, bs)
+	require.NoError(t, err)
+	_, err = codec.Encode(0, k+15)
+	assert.NoError(t, err)
+	_, err = codec.Encode(0, k+16)
+	assert.Error(t, err)
+	bs.MaxNumEs = bs.MaxSbLen
+	_, err = codec.Encode(0, k)
+	assert.Error(t, err)
+}
+
+func TestPayloadIDESIRange(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(100000, 0, 1004, 4, 0, 1)
+	require.NoError(t, err)
+	codec, err := fec.NewPayloadIDCodec(api.RAPTORQ_FEC_ENC_ID, 0, bs)
+	require.NoError(t, err)
+	er := fec.ESIRange{0: {{2, 4}, {100, 101}}}
+	b, err := codec.AppendESIRange(nil, er)
+	require.NoError(t, err)
+	assert.Len(t, b, 5*codec.Len())
+	got, err := codec.ESIRange(b)
+	require.NoError(t, err)
+	assert.Equal(t, er, got)
+
+	b, err = codec.AppendESIRange(nil, fec.ESIRange{0: nil})
+	require.NoError(t, err)
+	got, err = codec.ESIRange(b)
+	require.NoError(t, err)
+	assert.Equal(t, fec.ESIRange{0: {{0, int64(bs.NumSrcSym(0)) - 1}}}, got)
+}
@@ -0,0 +1,188 @@
+package fec
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"math/rand"
+)
+
+// Order is the transmission order of the packets of an object.
+type Order uint8
+
+const (
+	OrderSequential       Order = iota // block after block, source then repair packets
+	OrderBlockInterleaved              // one packet of every block in turn
+	OrderRandom                        // shuffled, deterministic for a seed
+	OrderSourceFirst                   // source packets block after block, then repair packets interleaved
+)
+
+func (o Order) String() string {
+	switch o {
+	case OrderSequential:
+		return "sequential"
+	case OrderBlockInterleaved:
+		return "block-interleaved"
+	case OrderRandom:
+		return "random"
+	case OrderSourceFirst:
+		return "source-first"
+	}
+	return fmt.Sprintf("Order(%d)", uint8(o))
+}
+
+// Packet is the unit of transmission: NumSymbols encoding symbols of block SBN
+// with consecutive ESIs, from ESI on.
+type Packet struct {
+	PayloadID
+	NumSymbols uint32
+}
+
+// Planner orders the packets of an object so that a loss burst is spread over
+// its source blocks instead of wiping out one of them. Every block is sent as
+// its k source symbols and ceil(k * redundancy) repair symbols, in packets of
+// NumEsPerGroup symbols that never mix source and repair symbols.
+type Planner struct {
+	Order  Order
+	Seed   int64 // seed of OrderRandom
+	bs     *BlockingStructure
+	codec  *PayloadIDCodec
+	repair []uint32 // repair symbols per SBN
+}
+
+// NewPlanner returns the planner of the object laid out by bs, redundancy being
+// FECParamType.Redundancy. Blocks with a repair count set on bs keep it, the
+// repair symbols of the others are capped to those the scheme can generate,
+// MaxNumEs - MaxSbLen for encoding 0.
+func NewPlanner(bs *BlockingStructure, redundancy float64, order Order) (*Planner, error) {
+	if bs == nil || bs.N.Load() == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	if redundancy < 0 || math.IsNaN(redundancy) || math.IsInf(redundancy, 0) {
+		return nil, fmt.Errorf("invalid redundancy %v", redundancy)
+	}
+	if order > OrderSourceFirst {
+		return nil, fmt.Errorf("invalid order %s", order)
+	}
+	codec, err := NewPayloadIDCodec(bs.Encoding, bs.Instance, bs)
+	if err != nil {
+		return nil, err
+	}
+	p := &Planner{Order: order, bs: bs, codec: codec, repair: make([]uint32, bs.N.Load())}
+	for sbn := range p.repair {
+		if bs.hasRepair(uint32(sbn)) {
+			p.repair[sbn] = bs.NumRprSym(uint32(sbn))
+			continue
+		}
+		k := uint64(bs.NumSrcSym(uint32(sbn)))
+		if n := codec.maxESI(uint32(sbn)) + 1; n > k {
+			p.repair[sbn] = uint32(min(uint64(math.Ceil(float64(k)*redundancy)), n-k))
+		}
+	}
+	return p, nil
+}
+
+// Repair returns the number of repair symbols sent for block sbn.
+func (p *Planner) Repair(sbn uint32) uint32 {
+	if sbn >= uint32(len(p.repair)) {
+		return 0
+	}
+	return p.repair[sbn]
+}
+
+func (p *Planner) group() uint32 {
+	return max(p.bs.NumEsPerGroup, 1)
+}
+
+// packets returns the number of source and repair packets of block sbn.
+func (p *Planner) packets(sbn uint32) (src, rpr uint32) {
+	g := p.group()
+	return (p.bs.NumSrcSym(sbn) + g - 1) / g, (p.repair[sbn] + g - 1) / g
+}
+
+// packet returns packet j of block sbn, source packets first.
+func (p *Planner) packet(sbn, j uint32) Packet {
+	g := p.group()
+	k := p.bs.NumSrcSym(sbn)
+	src, _ := p.packets(sbn)
+	esi, end := j*g, k
+	if j >= src {
+		esi, end = k+(j-src)*g, k+p.repair[sbn]
+	}
+	return Packet{PayloadID: p.codec.PayloadID(sbn, esi), NumSymbols: min(g, end-esi)}
+}
+
+// Len returns the number of packets of the object.
+func (p *Planner) Len() int {
+	n := 0
+	for sbn := range p.repair {
+		src, rpr := p.packets(uint32(sbn))
+		n += int(src + rpr)
+	}
+	return n
+}
+
+// interleave yields packets first+j of every block in turn, for j below the
+// count returned by num.
+func (p *Planner) interleave(yield func(Packet) bool, num func(sbn uint32) (first, n uint32)) {
+	for j, more := uint32(0), true; more; j++ {
+		more = false
+		for sbn := range p.repair {
+			first, n := num(uint32(sbn))
+			if j >= n {
+				continue
+			}
+			more = true
+			if !yield(p.packet(uint32(sbn), first+j)) {
+				return
+			}
+		}
+	}
+}
+
+// Packets returns the packets of the object in transmission order.
+func (p *Planner) Packets() iter.Seq[Packet] {
+	return func(yield func(Packet) bool) {
+		switch p.Order {
+		case OrderSequential:
+			for sbn := range p.repair {
+				src, rpr := p.packets(uint32(sbn))
+				for j := uint32(0); j < src+rpr; j++ {
+					if !yield(p.packet(uint32(sbn), j)) {
+						return
+					}
+				}
+			}
+		case OrderBlockInterleaved:
+			p.interleave(yield, func(sbn uint32) (uint32, uint32) {
+				src, rpr := p.packets(sbn)
+				return 0, src + rpr
+			})
+		case OrderRandom:
+			all := make([]Packet, 0, p.Len())
+			for sbn := range p.repair {
+				src, rpr := p.packets(uint32(sbn))
+				for j := uint32(0); j < src+rpr; j++ {
+					all = append(all, p.packet(uint32(sbn), j))
+				}
+			}
+			rnd := rand.New(rand.NewSource(p.Seed))
+			rnd.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+			for _, pkt := range all {
+				if !yield(pkt) {
+					return
+				}
+			}
+		case OrderSourceFirst:
+			for sbn := range p.repair {
+				src, _ := p.packets(uint32(sbn))
+				for j := uint32(0); j < src; j++ {
+					if !yield(p.packet(uint32(sbn), j)) {
+						return
+					}
+				}
+			}
+			p.interleave(yield, p.packets)
+		}
+	}
+}
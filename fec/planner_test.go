@@ -0,0 +1,93 @@
+package fec_test
+
+import (
+	"slices"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(p *fec.Planner) []fec.Packet {
+	return slices.Collect(p.Packets())
+}
+
+func TestPlannerOrders(t *testing.T) {
+	// 3 blocks of 4, 3 and 3 symbols, ceil(0.5k) = 2 repair symbols each
+	bs, err := fec.NewBlockingStructure5052(10*100, 4, 100, 1, false)
+	require.NoError(t, err)
+	bs.Encoding = api.COM_FEC_ENC_ID
+	bs.MaxNumEs = 8
+
+	p, err := fec.NewPlanner(bs, 0.5, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2, 2, 2}, []uint32{p.Repair(0), p.Repair(1), p.Repair(2)})
+	assert.Equal(t, 16, p.Len())
+	seq := collect(p)
+	require.Len(t, seq, p.Len())
+	id := func(sbn, esi uint32) fec.Packet {
+		return fec.Packet{PayloadID: fec.PayloadID{SBN: sbn, ESI: esi}, NumSymbols: 1}
+	}
+	assert.Equal(t, []fec.Packet{id(0, 0), id(0, 1), id(0, 2), id(0, 3), id(0, 4), id(0, 5), id(1, 0)}, seq[:7])
+
+	p.Order = fec.OrderBlockInterleaved
+	assert.Equal(t, []fec.Packet{id(0, 0), id(1, 0), id(2, 0), id(0, 1), id(1, 1), id(2, 1)}, collect(p)[:6])
+	assert.ElementsMatch(t, seq, collect(p))
+
+	p.Order = fec.OrderSourceFirst
+	sf := collect(p)
+	assert.ElementsMatch(t, seq, sf)
+	assert.Equal(t, []fec.Packet{id(2, 2), id(0, 4), id(1, 3), id(2, 3), id(0, 5), id(1, 4), id(2, 4)}, sf[9:])
+
+	p.Order = fec.OrderRandom
+	p.Seed = 7
+	random := collect(p)
+	assert.ElementsMatch(t, seq, random)
+	assert.NotEqual(t, seq, random)
+	assert.Equal(t, random, collect(p), "deterministic for a seed")
+	p.Seed = 8
+	assert.NotEqual(t, random, collect(p))
+
+	n := 0
+	for range p.Packets() {
+		if n++; n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestPlannerGroups(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(5*1000, 0, 1000, 4, 0, 2)
+	require.NoError(t, err)
+	p, err := fec.NewPlanner(bs, 0.6, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), p.Repair(0))
+	assert.Equal(t, []fec.Packet{
+		{PayloadID: fec.PayloadID{ESI: 0}, NumSymbols: 2},
+		{PayloadID: fec.PayloadID{ESI: 2}, NumSymbols: 2},
+		{PayloadID: fec.PayloadID{ESI: 4}, NumSymbols: 1},
+		{PayloadID: fec.PayloadID{ESI: 5}, NumSymbols: 2},
+		{PayloadID: fec.PayloadID{ESI: 7}, NumSymbols: 1},
+	}, collect(p))
+
+	// Compact No-Code has no repair symbols and scheme 129 carries the block length
+	bs, err = fec.NewBlockingStructure5052(1000, 10, 100, 1, false)
+	require.NoError(t, err)
+	p, err = fec.NewPlanner(bs, 1, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, 10, p.Len())
+	bs, err = fec.NewBlockingStructure3452(api.SB_SYS_FEC_ENC_ID, api.ReedSolomonFECInst, 1000, 10, 100, 15, 1)
+	require.NoError(t, err)
+	p, err = fec.NewPlanner(bs, 1, fec.OrderSequential)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), p.Repair(0))
+	assert.Equal(t, uint32(10), collect(p)[0].SrcBlockLen)
+
+	_, err = fec.NewPlanner(bs, -1, fec.OrderSequential)
+	assert.Error(t, err)
+	_, err = fec.NewPlanner(bs, 0, fec.Order(9))
+	assert.Error(t, err)
+}
@@ -0,0 +1,333 @@
+package fec
+
+// Ranges is the set API shared by RangeList and RangeSet, so that the
+// representation can be picked per object size.
+type Ranges interface {
+	Count() int64
+	String() string
+	StringHTTP() string
+	Contains(ol RangeList) bool
+	InplaceUnion(ll RangeList) int
+	Union(ll RangeList) RangeList
+	Subtract(ll RangeList) RangeList
+	Intersection(ll RangeList) RangeList
+	Ranges() RangeList
+}
+
+var (
+	_ Ranges = (*RangeList)(nil)
+	_ Ranges = (*RangeSet)(nil)
+)
+
+// Ranges returns rl itself, for the Ranges interface.
+func (rl RangeList) Ranges() RangeList {
+	return rl
+}
+
+// RangeSet is a set of disjoint, non adjacent ranges kept in a treap ordered by
+// Start. Unions and subtractions of a range cost O(log n) plus the number of
+// ranges they absorb, where RangeList shifts its slice; it suits objects with
+// many holes, e.g. multi-gigabyte files under burst loss.
+//
+// Only closed ranges are held, open ended ones (End == -1) are ignored. The zero
+// value is an empty set.
+type RangeSet struct {
+	root *rangeNode
+	seed uint64
+}
+
+type rangeNode struct {
+	r           Range
+	prio        uint64
+	left, right *rangeNode
+	size        int   // number of ranges in the subtree
+	count       int64 // number of elements in the subtree
+}
+
+// NewRangeSet returns the set of the ranges of rl, in any order.
+func NewRangeSet(rl RangeList) *RangeSet {
+	s := &RangeSet{}
+	s.InplaceUnion(rl)
+	return s
+}
+
+func (n *rangeNode) update() *rangeNode {
+	n.size, n.count = 1, n.r.Count()
+	if n.left != nil {
+		n.size += n.left.size
+		n.count += n.left.count
+	}
+	if n.right != nil {
+		n.size += n.right.size
+		n.count += n.right.count
+	}
+	return n
+}
+
+// newNode draws the heap priority from a xorshift generator, deterministic per set.
+func (s *RangeSet) newNode(r Range) *rangeNode {
+	if s.seed == 0 {
+		s.seed = 0x9e3779b97f4a7c15
+	}
+	s.seed ^= s.seed << 13
+	s.seed ^= s.seed >> 7
+	s.seed ^= s.seed << 17
+	return (&rangeNode{r: r, prio: s.seed}).update()
+}
+
+// treapSplit splits n into the ranges starting before start and the others.
+func treapSplit(n *rangeNode, start int64) (*rangeNode, *rangeNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.r.Start < start {
+		l, r := treapSplit(n.right, start)
+		n.right = l
+		return n.update(), r
+	}
+	l, r := treapSplit(n.left, start)
+	n.left = r
+	return l, n.update()
+}
+
+// treapMerge joins a and b where every range of a is before every range of b.
+func treapMerge(a, b *rangeNode) *rangeNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		a.right = treapMerge(a.right, b)
+		return a.update()
+	}
+	b.left = treapMerge(a, b.left)
+	return b.update()
+}
+
+func treapLast(n *rangeNode) *rangeNode {
+	for n != nil && n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+func treapFirst(n *rangeNode) *rangeNode {
+	for n != nil && n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func treapWalk(n *rangeNode, fn func(r Range)) {
+	if n == nil {
+		return
+	}
+	treapWalk(n.left, fn)
+	fn(n.r)
+	treapWalk(n.right, fn)
+}
+
+// treapVisit calls fn on the ranges overlapping [start, end] in order.
+func treapVisit(n *rangeNode, start, end int64, fn func(r Range)) {
+	if n == nil {
+		return
+	}
+	if n.r.Start > start {
+		treapVisit(n.left, start, end, fn)
+	}
+	if n.r.End >= start && n.r.Start <= end {
+		fn(n.r)
+	}
+	if n.r.End < end {
+		treapVisit(n.right, start, end, fn)
+	}
+}
+
+// union adds r and reports whether it touched no existing range.
+func (s *RangeSet) union(r Range) bool {
+	l, rest := treapSplit(s.root, r.Start)
+	touched := false
+	if last := treapLast(l); last != nil && last.r.End+1 >= r.Start {
+		touched = true
+		r.Start = last.r.Start
+		r.End = max(r.End, last.r.End)
+		l, _ = treapSplit(l, last.r.Start)
+	}
+	// ranges starting within or right after r are absorbed
+	absorbed, rest := treapSplit(rest, r.End+2)
+	if last := treapLast(absorbed); last != nil {
+		touched = true
+		r.End = max(r.End, last.r.End)
+	}
+	s.root = treapMerge(treapMerge(l, s.newNode(r)), rest)
+	return !touched
+}
+
+// subtract removes r from the set.
+func (s *RangeSet) subtract(r Range) {
+	l, rest := treapSplit(s.root, r.Start)
+	var keep []Range
+	if last := treapLast(l); last != nil && last.r.End >= r.Start {
+		l, _ = treapSplit(l, last.r.Start)
+		keep = append(keep, Range{last.r.Start, r.Start - 1})
+		if last.r.End > r.End {
+			keep = append(keep, Range{r.End + 1, last.r.End})
+		}
+	}
+	cut, rest := treapSplit(rest, r.End+1)
+	if last := treapLast(cut); last != nil && last.r.End > r.End {
+		keep = append(keep, Range{r.End + 1, last.r.End})
+	}
+	for _, k := range keep {
+		if k.Start < r.Start {
+			l = treapMerge(l, s.newNode(k))
+		} else {
+			rest = treapMerge(s.newNode(k), rest)
+		}
+	}
+	s.root = treapMerge(l, rest)
+}
+
+// Len returns the number of disjoint ranges.
+func (s *RangeSet) Len() int {
+	if s == nil || s.root == nil {
+		return 0
+	}
+	return s.root.size
+}
+
+func (s *RangeSet) Count() int64 {
+	if s == nil || s.root == nil {
+		return 0
+	}
+	return s.root.count
+}
+
+// Ranges returns the ranges of the set in order.
+func (s *RangeSet) Ranges() RangeList {
+	if s == nil || s.root == nil {
+		return nil
+	}
+	rl := make(RangeList, 0, s.root.size)
+	treapWalk(s.root, func(r Range) { rl = append(rl, r) })
+	return rl
+}
+
+// First and Last return the lowest and the highest range of a non empty set.
+func (s *RangeSet) First() (Range, bool) {
+	if n := treapFirst(s.root); n != nil {
+		return n.r, true
+	}
+	return Range{}, false
+}
+
+func (s *RangeSet) Last() (Range, bool) {
+	if n := treapLast(s.root); n != nil {
+		return n.r, true
+	}
+	return Range{}, false
+}
+
+func (s *RangeSet) String() string {
+	return s.Ranges().String()
+}
+
+func (s *RangeSet) StringHTTP() string {
+	return s.Ranges().StringHTTP()
+}
+
+func (s *RangeSet) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *RangeSet) UnmarshalText(text []byte) error {
+	var rl RangeList
+	if err := rl.UnmarshalText(text); err != nil {
+		return err
+	}
+	*s = RangeSet{}
+	s.InplaceUnion(rl)
+	return nil
+}
+
+// Clone returns a copy of the set.
+func (s *RangeSet) Clone() *RangeSet {
+	var clone func(n *rangeNode) *rangeNode
+	clone = func(n *rangeNode) *rangeNode {
+		if n == nil {
+			return nil
+		}
+		c := *n
+		c.left, c.right = clone(n.left), clone(n.right)
+		return &c
+	}
+	return &RangeSet{root: clone(s.root), seed: s.seed}
+}
+
+// Contains reports whether every range of ol is in the set.
+func (s *RangeSet) Contains(ol RangeList) bool {
+	for _, o := range ol {
+		if o.Start > o.End {
+			continue
+		}
+		var found *rangeNode
+		for n := s.root; n != nil; {
+			if n.r.Start <= o.Start {
+				found = n
+				n = n.right
+			} else {
+				n = n.left
+			}
+		}
+		if found == nil || found.r.End < o.End {
+			return false
+		}
+	}
+	return true
+}
+
+// InplaceUnion adds the ranges of ll, in any order, and returns how many of them
+// touched no range of the set.
+func (s *RangeSet) InplaceUnion(ll RangeList) int {
+	n := 0
+	for _, r := range ll {
+		if r.Start <= r.End && s.union(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// InplaceSubtract removes the ranges of ll, in any order.
+func (s *RangeSet) InplaceSubtract(ll RangeList) {
+	for _, r := range ll {
+		if r.Start <= r.End {
+			s.subtract(r)
+		}
+	}
+}
+
+func (s *RangeSet) Union(ll RangeList) RangeList {
+	c := s.Clone()
+	c.InplaceUnion(ll)
+	return c.Ranges()
+}
+
+func (s *RangeSet) Subtract(ll RangeList) RangeList {
+	c := s.Clone()
+	c.InplaceSubtract(ll)
+	return c.Ranges()
+}
+
+// Intersection returns the parts of the ranges of the set within ll.
+func (s *RangeSet) Intersection(ll RangeList) RangeList {
+	var out RangeList
+	for _, l := range NewRangeSet(ll).Ranges() {
+		treapVisit(s.root, l.Start, l.End, func(r Range) {
+			out = append(out, Range{max(r.Start, l.Start), min(r.End, l.End)})
+		})
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package fec_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bitmapRanges converts a reference bitmap to its ranges.
+func bitmapRanges(bits []bool) fec.RangeList {
+	var rl fec.RangeList
+	for i, b := range bits {
+		if !b {
+			continue
+		}
+		if n := len(rl); n > 0 && rl[n-1].End == int64(i)-1 {
+			rl[n-1].End++
+		} else {
+			rl = append(rl, fec.Range{Start: int64(i), End: int64(i)})
+		}
+	}
+	return rl
+}
+
+func TestRangeSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	bits := make([]bool, 2000)
+	s := &fec.RangeSet{}
+	for i := 0; i < 5000; i++ {
+		start := rnd.Int63n(int64(len(bits)))
+		end := min(start+rnd.Int63n(40), int64(len(bits))-1)
+		r := fec.RangeList{{Start: start, End: end}}
+		add := rnd.Intn(3) != 0
+		if add {
+			s.InplaceUnion(r)
+		} else {
+			s.InplaceSubtract(r)
+		}
+		for j := start; j <= end; j++ {
+			bits[j] = add
+		}
+		want := bitmapRanges(bits)
+		require.Equal(t, want, s.Ranges(), "step %d", i)
+		require.Equal(t, want.Count(), s.Count())
+		require.Equal(t, len(want), s.Len())
+	}
+
+	rl := s.Ranges()
+	assert.Equal(t, rl.String(), s.String())
+	assert.Equal(t, rl.StringHTTP(), s.StringHTTP())
+	probe := fec.RangeList{{Start: 100, End: 900}, {Start: 1500, End: 1600}}
+	assert.Equal(t, rl.Intersection(probe), s.Intersection(probe))
+	assert.Equal(t, rl.Subtract(probe), s.Subtract(probe))
+	assert.Equal(t, rl.Union(probe), s.Union(probe))
+	assert.Equal(t, rl, s.Ranges(), "Union and Subtract leave the set unchanged")
+	assert.Equal(t, rl.Contains(rl[2:4]), s.Contains(rl[2:4]))
+	assert.False(t, s.Contains(fec.RangeList{{Start: rl[0].Start, End: rl[0].End + 1}}))
+
+	text, err := s.MarshalText()
+	require.NoError(t, err)
+	var u fec.RangeSet
+	require.NoError(t, u.UnmarshalText(text))
+	assert.Equal(t, rl, u.Ranges())
+}
+
+func TestRangeSetUnion(t *testing.T) {
+	s := fec.NewRangeSet(fec.RangeList{{Start: 20, End: 29}, {Start: 0, End: 9}})
+	assert.Equal(t, "0-9,20-29", s.String())
+	assert.Equal(t, 0, s.InplaceUnion(fec.RangeList{{Start: 10, End: 12}}))
+	assert.Equal(t, 1, s.InplaceUnion(fec.RangeList{{Start: 40, End: 41}}))
+	assert.Equal(t, 0, s.InplaceUnion(fec.RangeList{{Start: 13, End: 19}}))
+	assert.Equal(t, fec.RangeList{{Start: 0, End: 29}, {Start: 40, End: 41}}, s.Ranges())
+	first, _ := s.First()
+	last, _ := s.Last()
+	assert.Equal(t, fec.Range{Start: 40, End: 41}, last)
+	assert.Equal(t, fec.Range{Start: 0, End: 29}, first)
+	s.InplaceSubtract(fec.RangeList{{Start: 5, End: 40}})
+	assert.Equal(t, "0-4,41", s.String())
+}
+
+// burstRanges returns the symbol ranges received of an object of n symbols of
+// 1024 octets under burst loss, in arrival order with some reordering.
+func burstRanges(n int) fec.RangeList {
+	rnd := rand.New(rand.NewSource(int64(n)))
+	var rl fec.RangeList
+	for esi := 0; esi < n; esi++ {
+		if rnd.Intn(20) == 0 {
+			esi += rnd.Intn(8) // burst
+			continue
+		}
+		rl = append(rl, fec.Range{Start: int64(esi) * 1024, End: int64(esi)*1024 + 1023})
+	}
+	for i := range rl {
+		if j := i + rnd.Intn(4); j < len(rl) {
+			rl[i], rl[j] = rl[j], rl[i]
+		}
+	}
+	return rl
+}
+
+func BenchmarkRangesInplaceUnion(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		received := burstRanges(n)
+		b.Run(fmt.Sprintf("RangeList/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var rl fec.RangeList
+				for _, r := range received {
+					rl.InplaceUnion(fec.RangeList{r})
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("RangeSet/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var s fec.RangeSet
+				for _, r := range received {
+					s.InplaceUnion(fec.RangeList{r})
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRangesSubtract(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		received := burstRanges(n)
+		all := fec.RangeList{{Start: 0, End: int64(n)*1024 - 1}}
+		b.Run(fmt.Sprintf("RangeList/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				missing := append(fec.RangeList(nil), all...)
+				for _, r := range received[:len(received)/10] {
+					missing = missing.Subtract(fec.RangeList{r})
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("RangeSet/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				missing := fec.NewRangeSet(all)
+				for _, r := range received[:len(received)/10] {
+					missing.InplaceSubtract(fec.RangeList{r})
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package fec
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RaptorQ FEC scheme, IETF RFC 6330.
+//
+// A source block of K source symbols is extended to K' symbols with zero padding,
+// the L intermediate symbols are computed by solving the constraint matrix of
+// section 5.3.3.4 and every encoding symbol is then generated with the LT
+// encoding of section 5.3.5.3. Source symbols keep ESIs 0..K-1 and repair
+// symbols start at ESI K, which is the numbering used by ESIRange.
+
+const (
+	RaptorQMaxESI         = 1<<24 - 1    // ESIs are 24 bits in the RaptorQ FEC Payload ID
+	raptorQMaxSrcBlocks   = 255          // Z is 8 bits in the Scheme-Specific FEC OTI
+	raptorQMaxTransferLen = 946270874880 // F is 40 bits, limited by section 4.3
+)
+
+// RaptorQMaxSrcSymbols is the largest number of source symbols per source block
+// supported by the systematic index table. Senders partition objects so that no
+// block exceeds it and signal the resulting number of blocks in the OTI.
+var RaptorQMaxSrcSymbols = raptorQSystematicIndices[len(raptorQSystematicIndices)-1][0]
+
+// RaptorQDefaultSrcSymbols is the number of source symbols per source block when
+// none is given. The encoder and decoder solve a dense L x L matrix, L a little
+// above K', which takes about a second at this size and grows with the cube of
+// it; larger blocks, up to RaptorQMaxSrcSymbols, must be asked for.
+const RaptorQDefaultSrcSymbols = 4096
+
+// raptorQParams holds the parameters of RFC 6330 section 5.3.3.3 for one source block.
+type raptorQParams struct {
+	K  uint32 // number of source symbols in the source block
+	Kp uint32 // K', the padded number of source symbols
+	J  uint32 // systematic index J(K')
+	S  uint32 // number of LDPC symbols
+	H  uint32 // number of HDPC symbols
+	W  uint32 // number of LT symbols
+	L  uint32 // number of intermediate symbols, K'+S+H
+	P  uint32 // number of PI symbols, L-W
+	P1 uint32 // smallest prime greater than or equal to P
+	B  uint32 // number of non-LDPC LT symbols, W-S
+}
+
+func newRaptorQParams(k uint32) (raptorQParams, error) {
+	if k == 0 || k > RaptorQMaxSrcSymbols {
+		return raptorQParams{}, fmt.Errorf("raptorq: %d source symbols out of range 1-%d", k, RaptorQMaxSrcSymbols)
+	}
+	i := sort.Search(len(raptorQSystematicIndices), func(i int) bool { return raptorQSystematicIndices[i][0] >= k })
+	row := raptorQSystematicIndices[i]
+	p := raptorQParams{K: k, Kp: row[0], J: row[1], S: row[2], H: row[3], W: row[4]}
+	p.L = p.Kp + p.S + p.H
+	p.P = p.L - p.W
+	p.P1 = p.P
+	for !isPrime(p.P1) {
+		p.P1++
+	}
+	p.B = p.W - p.S
+	return p, nil
+}
+
+// raptorQLargestKp returns the largest K' of the systematic index table not above x, 0 if none.
+func raptorQLargestKp(x uint64) uint64 {
+	i := sort.Search(len(raptorQSystematicIndices), func(i int) bool { return uint64(raptorQSystematicIndices[i][0]) > x })
+	if i == 0 {
+		return 0
+	}
+	return uint64(raptorQSystematicIndices[i-1][0])
+}
+
+func isPrime(n uint32) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint32(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// raptorQRand is the pseudo-random number generator Rand[y, i, m] of section 5.3.5.1.
+func raptorQRand(y, i, m uint32) uint32 {
+	x0 := (y + i) & 0xff
+	x1 := (y>>8 + i) & 0xff
+	x2 := (y>>16 + i) & 0xff
+	x3 := (y>>24 + i) & 0xff
+	return (raptorQV0[x0] ^ raptorQV1[x1] ^ raptorQV2[x2] ^ raptorQV3[x3]) % m
+}
+
+// raptorQDegree is the degree generator Deg[v] of section 5.3.5.2.
+func raptorQDegree(v, w uint32) uint32 {
+	d := uint32(1)
+	for v >= raptorQDegreeDist[d] {
+		d++
+	}
+	return min(d, w-2)
+}
+
+type raptorQTuple struct {
+	d, a, b, d1, a1, b1 uint32
+}
+
+// tuple is the tuple generator Tuple[K', X] of section 5.3.5.4 for the ISI x.
+func (p raptorQParams) tuple(x uint32) raptorQTuple {
+	A := 53591 + p.J*997
+	if A%2 == 0 {
+		A++
+	}
+	B := 10267 * (p.J + 1)
+	y := B + x*A // modulo 2^32 by overflow
+	v := raptorQRand(y, 0, 1<<20)
+	t := raptorQTuple{
+		d: raptorQDegree(v, p.W),
+		a: 1 + raptorQRand(y, 1, p.W-1),
+		b: raptorQRand(y, 2, p.W),
+	}
+	t.d1 = 2
+	if t.d < 4 {
+		t.d1 = 2 + raptorQRand(x, 3, 2)
+	}
+	t.a1 = 1 + raptorQRand(x, 4, p.P1-1)
+	t.b1 = raptorQRand(x, 5, p.P1)
+	return t
+}
+
+// indices returns the intermediate symbol indices combined by Enc[K', C, Tuple[K', x]]
+// of section 5.3.5.3.
+func (p raptorQParams) indices(x uint32) []uint32 {
+	t := p.tuple(x)
+	out := make([]uint32, 0, t.d+t.d1)
+	b := t.b
+	out = append(out, b)
+	for j := uint32(1); j < t.d; j++ {
+		b = (b + t.a) % p.W
+		out = append(out, b)
+	}
+	b1 := t.b1
+	for b1 >= p.P {
+		b1 = (b1 + t.a1) % p.P1
+	}
+	out = append(out, p.W+b1)
+	for j := uint32(1); j < t.d1; j++ {
+		b1 = (b1 + t.a1) % p.P1
+		for b1 >= p.P {
+			b1 = (b1 + t.a1) % p.P1
+		}
+		out = append(out, p.W+b1)
+	}
+	return out
+}
+
+// constraintRows returns the S LDPC rows followed by the H HDPC rows of the
+// constraint matrix A of section 5.3.3.4.2, each row holding L octets.
+func (p raptorQParams) constraintRows() [][]byte {
+	rows := make([][]byte, p.S+p.H)
+	for i := range rows {
+		rows[i] = make([]byte, p.L)
+	}
+	// G_LDPC,1 and I_S
+	for i := uint32(0); i < p.B; i++ {
+		a := 1 + i/p.S
+		b := i % p.S
+		rows[b][i] ^= 1
+		b = (b + a) % p.S
+		rows[b][i] ^= 1
+		b = (b + a) % p.S
+		rows[b][i] ^= 1
+	}
+	for i := uint32(0); i < p.S; i++ {
+		rows[i][p.B+i] = 1
+	}
+	// G_LDPC,2
+	for i := uint32(0); i < p.S; i++ {
+		rows[i][p.W+i%p.P] = 1
+		rows[i][p.W+(i+1)%p.P] = 1
+	}
+	// G_HDPC = MT * GAMMA, evaluated right to left with Horner's rule, then I_H
+	ks := p.Kp + p.S
+	for h := uint32(0); h < p.H; h++ {
+		row := rows[p.S+h]
+		row[ks-1] = octAlphaPow(int(h))
+	}
+	for j := int(ks) - 2; j >= 0; j-- {
+		r1 := raptorQRand(uint32(j)+1, 6, p.H)
+		r2 := (r1 + raptorQRand(uint32(j)+1, 7, p.H-1) + 1) % p.H
+		for h := uint32(0); h < p.H; h++ {
+			row := rows[p.S+h]
+			row[j] = octMulByte(row[j+1], 2)
+		}
+		rows[p.S+r1][j] ^= 1
+		rows[p.S+r2][j] ^= 1
+	}
+	for h := uint32(0); h < p.H; h++ {
+		rows[p.S+h][ks+h] = 1
+	}
+	return rows
+}
+
+// encodingRow returns the row of G_ENC for the ISI x.
+func (p raptorQParams) encodingRow(x uint32) []byte {
+	row := make([]byte, p.L)
+	for _, idx := range p.indices(x) {
+		row[idx] ^= 1
+	}
+	return row
+}
+
+// solve computes the L intermediate symbols from the rows of the constraint
+// matrix and their right hand side symbols by Gaussian elimination over GF(2^8).
+// Rows and symbols are modified in place. It returns ErrNotDecodable when the
+// matrix is not of full rank.
+func (p raptorQParams) solve(rows, symbols [][]byte) ([][]byte, error) {
+	L := int(p.L)
+	if len(rows) < L {
+		return nil, ErrNotDecodable
+	}
+	for col := 0; col < L; col++ {
+		// prefer the sparsest candidate pivot row to limit fill-in
+		pivot, weight := -1, L+1
+		for r := col; r < len(rows); r++ {
+			if rows[r][col] == 0 {
+				continue
+			}
+			w := 0
+			for _, c := range rows[r][col:] {
+				if c != 0 {
+					w++
+				}
+			}
+			if w < weight {
+				pivot, weight = r, w
+				if w == 1 {
+					break
+				}
+			}
+		}
+		if pivot < 0 {
+			return nil, ErrNotDecodable
+		}
+		rows[col], rows[pivot] = rows[pivot], rows[col]
+		symbols[col], symbols[pivot] = symbols[pivot], symbols[col]
+		if c := rows[col][col]; c != 1 {
+			inv := octDiv(1, c)
+			octScale(rows[col][col:], inv)
+			octScale(symbols[col], inv)
+		}
+		for r := col + 1; r < len(rows); r++ {
+			if c := rows[r][col]; c != 0 {
+				octAddMul(rows[r][col:], rows[col][col:], c)
+				octAddMul(symbols[r], symbols[col], c)
+			}
+		}
+	}
+	// back substitution on the upper triangular L x L part
+	for col := L - 1; col > 0; col-- {
+		for r := 0; r < col; r++ {
+			if c := rows[r][col]; c != 0 {
+				rows[r][col] = 0
+				octAddMul(symbols[r], symbols[col], c)
+			}
+		}
+	}
+	return symbols[:L], nil
+}
+
+// RaptorQEncoder generates the encoding symbols of one source block.
+type RaptorQEncoder struct {
+	params       raptorQParams
+	symLen       int
+	source       [][]byte
+	intermediate [][]byte
+}
+
+// NewRaptorQEncoder prepares the encoding of source block sbn of bs. The block
+// holds the SrcBlockSize(sbn) octets of that block; the last symbol is zero
+// padded to ESLen.
+func NewRaptorQEncoder(bs *BlockingStructure, sbn uint32, block []byte) (*RaptorQEncoder, error) {
+	if bs == nil || bs.ESLen == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	k := bs.NumSrcSym(sbn)
+	if size := bs.SrcBlockSize(sbn); uint64(len(block)) != size {
+		return nil, fmt.Errorf("raptorq: block %d is %d octets, expected %d", sbn, len(block), size)
+	}
+	params, err := newRaptorQParams(k)
+	if err != nil {
+		return nil, err
+	}
+	e := &RaptorQEncoder{params: params, symLen: int(bs.ESLen)}
+	e.source = bs.sourceSymbols(sbn, block)
+
+	rows := params.constraintRows()
+	symbols := make([][]byte, 0, params.L)
+	for range rows {
+		symbols = append(symbols, make([]byte, e.symLen))
+	}
+	for isi := uint32(0); isi < params.Kp; isi++ {
+		rows = append(rows, params.encodingRow(isi))
+		sym := make([]byte, e.symLen)
+		if isi < k {
+			copy(sym, e.source[isi])
+		}
+		symbols = append(symbols, sym)
+	}
+	if e.intermediate, err = params.solve(rows, symbols); err != nil {
+		return nil, fmt.Errorf("raptorq: K'=%d: %w", params.Kp, err)
+	}
+	return e, nil
+}
+
+// NumSrcSym returns the number of source symbols K of the block.
+func (e *RaptorQEncoder) NumSrcSym() uint32 {
+	return e.params.K
+}
+
+// Symbol returns the encoding symbol with the given ESI: the source symbol for
+// esi < K and a repair symbol otherwise.
+func (e *RaptorQEncoder) Symbol(esi uint32) ([]byte, error) {
+	if esi > RaptorQMaxESI {
+		return nil, fmt.Errorf("raptorq: esi %d out of range", esi)
+	}
+	if esi < e.params.K {
+		return append([]byte(nil), e.source[esi]...), nil
+	}
+	return e.params.lt(e.intermediate, esi+e.params.Kp-e.params.K, e.symLen), nil
+}
+
+// lt computes the encoding symbol for the ISI x from the intermediate symbols.
+func (p raptorQParams) lt(intermediate [][]byte, x uint32, symLen int) []byte {
+	out := make([]byte, symLen)
+	for _, idx := range p.indices(x) {
+		octAddMul(out, intermediate[idx], 1)
+	}
+	return out
+}
+
+// RaptorQDecoder recovers one source block from received encoding symbols.
+type RaptorQDecoder struct {
+	params  raptorQParams
+	symLen  int
+	bs      *BlockingStructure
+	sbn     uint32
+	symbols map[uint32][]byte
+}
+
+// NewRaptorQDecoder prepares the decoding of source block sbn of bs.
+func NewRaptorQDecoder(bs *BlockingStructure, sbn uint32) (*RaptorQDecoder, error) {
+	if bs == nil || bs.ESLen == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	params, err := newRaptorQParams(bs.NumSrcSym(sbn))
+	if err != nil {
+		return nil, err
+	}
+	return &RaptorQDecoder{
+		params:  params,
+		symLen:  int(bs.ESLen),
+		bs:      bs,
+		sbn:     sbn,
+		symbols: make(map[uint32][]byte),
+	}, nil
+}
+
+// AddSymbol records the encoding symbol with the given ESI. Duplicates are ignored.
+func (d *RaptorQDecoder) AddSymbol(esi uint32, symbol []byte) error {
+	if len(symbol) != d.symLen {
+		return fmt.Errorf("raptorq: symbol %d is %d octets, expected %d", esi, len(symbol), d.symLen)
+	}
+	if esi > RaptorQMaxESI {
+		return fmt.Errorf("raptorq: esi %d out of range", esi)
+	}
+	if _, ok := d.symbols[esi]; !ok {
+		d.symbols[esi] = append([]byte(nil), symbol...)
+	}
+	return nil
+}
+
+// Received returns the number of distinct encoding symbols recorded.
+func (d *RaptorQDecoder) Received() uint32 {
+	return uint32(len(d.symbols))
+}
+
+// Decodable reports whether at least K encoding symbols have been received. With
+// RaptorQ decoding then succeeds with high probability, Decode reports
+// ErrNotDecodable otherwise.
+func (d *RaptorQDecoder) Decodable() bool {
+	return uint32(len(d.symbols)) >= d.params.K
+}
+
+// Decode returns the SrcBlockSize octets of the source block.
+func (d *RaptorQDecoder) Decode() ([]byte, error) {
+	p := d.params
+	if !d.Decodable() {
+		return nil, ErrNotDecodable
+	}
+	missing := false
+	for esi := uint32(0); esi < p.K; esi++ {
+		if _, ok := d.symbols[esi]; !ok {
+			missing = true
+			break
+		}
+	}
+	if missing {
+		rows := p.constraintRows()
+		symbols := make([][]byte, 0, len(rows)+int(p.Kp-p.K)+len(d.symbols))
+		for range rows {
+			symbols = append(symbols, make([]byte, d.symLen))
+		}
+		for isi := p.K; isi < p.Kp; isi++ {
+			rows = append(rows, p.encodingRow(isi))
+			symbols = append(symbols, make([]byte, d.symLen))
+		}
+		esis := make([]uint32, 0, len(d.symbols))
+		for esi := range d.symbols {
+			esis = append(esis, esi)
+		}
+		sort.Slice(esis, func(i, j int) bool { return esis[i] < esis[j] })
+		for _, esi := range esis {
+			isi := esi
+			if esi >= p.K {
+				isi += p.Kp - p.K
+			}
+			rows = append(rows, p.encodingRow(isi))
+			symbols = append(symbols, append([]byte(nil), d.symbols[esi]...))
+		}
+		intermediate, err := p.solve(rows, symbols)
+		if err != nil {
+			return nil, err
+		}
+		for esi := uint32(0); esi < p.K; esi++ {
+			if _, ok := d.symbols[esi]; !ok {
+				d.symbols[esi] = p.lt(intermediate, esi, d.symLen)
+			}
+		}
+	}
+	source := make([][]byte, p.K)
+	for esi := range source {
+		source[esi] = d.symbols[uint32(esi)]
+	}
+	return d.bs.sourceBlock(d.sbn, source), nil
+}
+
+// splitSymbols cuts a block into k symbols of symLen octets, zero padding the last one.
+func splitSymbols(block []byte, k, symLen int) [][]byte {
+	out := make([][]byte, k)
+	for i := range out {
+		sym := make([]byte, symLen)
+		if start := i * symLen; start < len(block) {
+			copy(sym, block[start:min(start+symLen, len(block))])
+		}
+		out[i] = sym
+	}
+	return out
+}
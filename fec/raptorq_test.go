@@ -0,0 +1,144 @@
+package fec_test
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaptorQRoundTrip(t *testing.T) {
+	cases := []struct {
+		length   uint64
+		maxSbLen uint32
+		esLen    uint16
+	}{
+		{10, 10, 1},
+		{1000, 10, 100},
+		{12345, 64, 64},
+		{100000, 600, 170},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for _, c := range cases {
+		bs, err := fec.NewBlockingStructure5052(c.length, c.maxSbLen, c.esLen, 1, false)
+		require.NoError(t, err)
+		obj := make([]byte, c.length)
+		rnd.Read(obj)
+		for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+			offset := bs.SrcOffset(sbn)
+			block := obj[offset : offset+bs.SrcBlockSize(sbn)]
+			enc, err := fec.NewRaptorQEncoder(bs, sbn, block)
+			require.NoError(t, err, "bs %s sbn %d", bs, sbn)
+			k := enc.NumSrcSym()
+			assert.Equal(t, bs.NumSrcSym(sbn), k)
+
+			// lose a third of the source symbols and replace them with repair symbols
+			dec, err := fec.NewRaptorQDecoder(bs, sbn)
+			require.NoError(t, err)
+			lost := 0
+			for esi := uint32(0); esi < k; esi++ {
+				if rnd.Intn(3) == 0 {
+					lost++
+					continue
+				}
+				sym, err := enc.Symbol(esi)
+				require.NoError(t, err)
+				assert.NoError(t, dec.AddSymbol(esi, sym))
+			}
+			for esi := k; esi < k+uint32(lost)+2; esi++ {
+				sym, err := enc.Symbol(esi)
+				require.NoError(t, err)
+				assert.NoError(t, dec.AddSymbol(esi, sym))
+			}
+			assert.True(t, dec.Decodable())
+			out, err := dec.Decode()
+			require.NoError(t, err, "bs %s sbn %d lost %d", bs, sbn, lost)
+			assert.Equal(t, block, out)
+		}
+	}
+}
+
+func TestRaptorQRepairOnly(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(4000, 40, 100, 1, false)
+	require.NoError(t, err)
+	block := make([]byte, bs.SrcBlockSize(0))
+	rand.New(rand.NewSource(2)).Read(block)
+	enc, err := fec.NewRaptorQEncoder(bs, 0, block)
+	require.NoError(t, err)
+	src, err := enc.Symbol(3)
+	require.NoError(t, err)
+	assert.Equal(t, block[300:400], src)
+
+	dec, err := fec.NewRaptorQDecoder(bs, 0)
+	require.NoError(t, err)
+	for esi := uint32(1000); esi < 1039; esi++ {
+		sym, err := enc.Symbol(esi)
+		require.NoError(t, err)
+		require.NoError(t, dec.AddSymbol(esi, sym))
+	}
+	assert.False(t, dec.Decodable())
+	_, err = dec.Decode()
+	assert.ErrorIs(t, err, fec.ErrNotDecodable)
+
+	for esi := uint32(2000); esi < 2003; esi++ {
+		sym, err := enc.Symbol(esi)
+		require.NoError(t, err)
+		require.NoError(t, dec.AddSymbol(esi, sym))
+	}
+	out, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, block, out)
+}
+
+// TestRaptorQReference checks repair symbols against those of an independent
+// implementation, github.com/xssnick/raptorq v1.0.0, for source blocks of
+// 8-octet symbols holding octets i*31+7. ESIs up to 2^24-1 exercise the whole
+// of V0..V3; K' is chosen with P not prime, where that implementation takes P1
+// greater than P.
+func TestRaptorQReference(t *testing.T) {
+	cases := []struct {
+		k      uint32
+		esi    uint32
+		symbol string
+	}{
+		{10, 10, "b1fdcff70945774f"},
+		{10, 11, "68996bfdfb0af86e"},
+		{10, 110, "2138eb541f06d56a"},
+		{10, 65536, "e6365556d6066566"},
+		{10, 5000000, "290decc91b3fdefb"},
+		{10, 16777215, "cf1b16ca9e4a479b"},
+		{101, 101, "c7fb49a5a4982ac6"},
+		{101, 102, "4c89de6f30f5a213"},
+		{101, 201, "7856daaca48a0670"},
+		{101, 65536, "251b1afcb48a8b6d"},
+		{101, 5000000, "cf109a1212cd47cf"},
+		{101, 16777215, "280038e8755d65b5"},
+		{1000, 1000, "0d85a4cb69e1c0af"},
+		{1000, 1001, "c1740d96fa4f36ad"},
+		{1000, 1100, "b675cc7b29ea53e4"},
+		{1000, 65536, "a8ab3762f5f66a3f"},
+		{1000, 5000000, "ab5b5eaffb0b0eff"},
+		{1000, 16777215, "8284583e7c7aa6c0"},
+	}
+	encoders := map[uint32]*fec.RaptorQEncoder{}
+	for _, c := range cases {
+		enc, ok := encoders[c.k]
+		if !ok {
+			block := make([]byte, c.k*8)
+			for i := range block {
+				block[i] = byte(i*31 + 7)
+			}
+			bs, err := fec.NewBlockingStructure5052(uint64(len(block)), c.k, 8, 1, false)
+			require.NoError(t, err)
+			enc, err = fec.NewRaptorQEncoder(bs, 0, block)
+			require.NoError(t, err)
+			encoders[c.k] = enc
+		}
+		sym, err := enc.Symbol(c.esi)
+		require.NoError(t, err)
+		assert.Equal(t, c.symbol, hex.EncodeToString(sym), "K %d ESI %d", c.k, c.esi)
+	}
+}
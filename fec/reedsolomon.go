@@ -0,0 +1,224 @@
+package fec
+
+import "fmt"
+
+// Reed-Solomon FEC scheme over GF(2^8), IETF RFC 5510 (FEC Encoding ID 5, and
+// FEC Encoding ID 2 with m = 8).
+//
+// The systematic generator matrix is GM = V_{k,k}^-1 * V_{k,n} where V is the
+// Vandermonde matrix with V[i][j] = (alpha^j)^i, section 8.3. Source symbols
+// keep ESIs 0..k-1 and repair symbol j is the column j of GM applied to them.
+
+const ReedSolomonMaxN = 255 // 2^^m - 1 encoding symbols per source block for m = 8
+
+// reedSolomonN returns the number of encoding symbols n of a source block of k
+// symbols, n = floor(k * max_n / B), section 5.
+func reedSolomonN(bs *BlockingStructure, k uint32) uint32 {
+	if bs.MaxSbLen == 0 || bs.MaxNumEs <= bs.MaxSbLen {
+		return k
+	}
+	return uint32(uint64(k) * uint64(bs.MaxNumEs) / uint64(bs.MaxSbLen))
+}
+
+// reedSolomonBlockN returns n of source block sbn, k plus its repair count when
+// set by SetRepair.
+func reedSolomonBlockN(bs *BlockingStructure, sbn uint32) uint32 {
+	k := bs.NumSrcSym(sbn)
+	if r, ok := bs.repairCount(sbn); ok {
+		return k + r
+	}
+	return reedSolomonN(bs, k)
+}
+
+// reedSolomonGenerator returns the k x n systematic generator matrix GM.
+func reedSolomonGenerator(k, n uint32) [][]byte {
+	v := make([][]byte, k)
+	for i := range v {
+		v[i] = make([]byte, n)
+		for j := range v[i] {
+			v[i][j] = octAlphaPow(i * j)
+		}
+	}
+	// GM = V_{k,k}^-1 * V_{k,n}: reduce the left k x k block of V to the identity
+	for col := 0; col < int(k); col++ {
+		pivot := col
+		for v[pivot][col] == 0 {
+			pivot++
+		}
+		v[col], v[pivot] = v[pivot], v[col]
+		octScale(v[col], octDiv(1, v[col][col]))
+		for r := range v {
+			if r != col {
+				octAddMul(v[r], v[col], v[r][col])
+			}
+		}
+	}
+	return v
+}
+
+// ReedSolomonEncoder generates the encoding symbols of one source block.
+type ReedSolomonEncoder struct {
+	k, n   uint32
+	gm     [][]byte
+	source [][]byte
+	symLen int
+}
+
+// NewReedSolomonEncoder prepares the encoding of source block sbn of bs. The
+// block holds the SrcBlockSize(sbn) octets of that block; the last symbol is
+// zero padded to ESLen. The number of repair symbols follows from MaxNumEs,
+// unless set per block with SetRepair.
+func NewReedSolomonEncoder(bs *BlockingStructure, sbn uint32, block []byte) (*ReedSolomonEncoder, error) {
+	if bs == nil || bs.ESLen == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	if size := bs.SrcBlockSize(sbn); uint64(len(block)) != size {
+		return nil, fmt.Errorf("reed-solomon: block %d is %d octets, expected %d", sbn, len(block), size)
+	}
+	k := bs.NumSrcSym(sbn)
+	n := reedSolomonBlockN(bs, sbn)
+	if k == 0 || n > ReedSolomonMaxN {
+		return nil, fmt.Errorf("reed-solomon: (n=%d, k=%d) out of range for GF(2^8)", n, k)
+	}
+	return &ReedSolomonEncoder{
+		k:      k,
+		n:      n,
+		gm:     reedSolomonGenerator(k, n),
+		source: splitSymbols(block, int(k), int(bs.ESLen)),
+		symLen: int(bs.ESLen),
+	}, nil
+}
+
+// NumSrcSym returns the number of source symbols k of the block.
+func (e *ReedSolomonEncoder) NumSrcSym() uint32 {
+	return e.k
+}
+
+// NumEncSym returns the number of encoding symbols n of the block.
+func (e *ReedSolomonEncoder) NumEncSym() uint32 {
+	return e.n
+}
+
+// Symbol returns the encoding symbol with the given ESI: the source symbol for
+// esi < k and a repair symbol for k <= esi < n.
+func (e *ReedSolomonEncoder) Symbol(esi uint32) ([]byte, error) {
+	if esi >= e.n {
+		return nil, fmt.Errorf("reed-solomon: esi %d out of range, n=%d", esi, e.n)
+	}
+	if esi < e.k {
+		return append([]byte(nil), e.source[esi]...), nil
+	}
+	out := make([]byte, e.symLen)
+	for i := range e.source {
+		octAddMul(out, e.source[i], e.gm[i][esi])
+	}
+	return out, nil
+}
+
+// ReedSolomonDecoder recovers one source block from any k received encoding symbols.
+type ReedSolomonDecoder struct {
+	k, n    uint32
+	symLen  int
+	size    uint64
+	symbols map[uint32][]byte
+}
+
+// NewReedSolomonDecoder prepares the decoding of source block sbn of bs.
+func NewReedSolomonDecoder(bs *BlockingStructure, sbn uint32) (*ReedSolomonDecoder, error) {
+	if bs == nil || bs.ESLen == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	k := bs.NumSrcSym(sbn)
+	n := reedSolomonBlockN(bs, sbn)
+	if k == 0 || n > ReedSolomonMaxN {
+		return nil, fmt.Errorf("reed-solomon: (n=%d, k=%d) out of range for GF(2^8)", n, k)
+	}
+	return &ReedSolomonDecoder{
+		k:       k,
+		n:       n,
+		symLen:  int(bs.ESLen),
+		size:    bs.SrcBlockSize(sbn),
+		symbols: make(map[uint32][]byte),
+	}, nil
+}
+
+// AddSymbol records the encoding symbol with the given ESI. Duplicates are ignored.
+func (d *ReedSolomonDecoder) AddSymbol(esi uint32, symbol []byte) error {
+	if len(symbol) != d.symLen {
+		return fmt.Errorf("reed-solomon: symbol %d is %d octets, expected %d", esi, len(symbol), d.symLen)
+	}
+	if esi >= d.n {
+		return fmt.Errorf("reed-solomon: esi %d out of range, n=%d", esi, d.n)
+	}
+	if _, ok := d.symbols[esi]; !ok {
+		d.symbols[esi] = append([]byte(nil), symbol...)
+	}
+	return nil
+}
+
+// Received returns the number of distinct encoding symbols recorded.
+func (d *ReedSolomonDecoder) Received() uint32 {
+	return uint32(len(d.symbols))
+}
+
+// Decodable reports whether k encoding symbols have been received, which is
+// always sufficient for this MDS code.
+func (d *ReedSolomonDecoder) Decodable() bool {
+	return uint32(len(d.symbols)) >= d.k
+}
+
+// Decode returns the SrcBlockSize octets of the source block.
+func (d *ReedSolomonDecoder) Decode() ([]byte, error) {
+	if !d.Decodable() {
+		return nil, ErrNotDecodable
+	}
+	// Use every received source symbol and fill up with the lowest repair ESIs.
+	esis := make([]uint32, 0, d.k)
+	for esi := uint32(0); esi < d.n && uint32(len(esis)) < d.k; esi++ {
+		if _, ok := d.symbols[esi]; ok {
+			esis = append(esis, esi)
+		}
+	}
+	if esis[len(esis)-1] >= d.k {
+		gm := reedSolomonGenerator(d.k, d.n)
+		// y = x * G' where G' holds the columns of the received ESIs: solve for x
+		// by reducing the transposed system G'^T * x^T = y^T.
+		rows := make([][]byte, d.k)
+		syms := make([][]byte, d.k)
+		for r, esi := range esis {
+			rows[r] = make([]byte, d.k)
+			for i := uint32(0); i < d.k; i++ {
+				rows[r][i] = gm[i][esi]
+			}
+			syms[r] = append([]byte(nil), d.symbols[esi]...)
+		}
+		for col := 0; col < int(d.k); col++ {
+			pivot := col
+			for pivot < len(rows) && rows[pivot][col] == 0 {
+				pivot++
+			}
+			if pivot == len(rows) {
+				return nil, ErrNotDecodable
+			}
+			rows[col], rows[pivot] = rows[pivot], rows[col]
+			syms[col], syms[pivot] = syms[pivot], syms[col]
+			inv := octDiv(1, rows[col][col])
+			octScale(rows[col], inv)
+			octScale(syms[col], inv)
+			for r := range rows {
+				if c := rows[r][col]; r != col && c != 0 {
+					octAddMul(rows[r], rows[col], c)
+					octAddMul(syms[r], syms[col], c)
+				}
+			}
+		}
+		for esi := uint32(0); esi < d.k; esi++ {
+			d.symbols[esi] = syms[esi]
+		}
+	}
+	out := make([]byte, 0, uint64(d.k)*uint64(d.symLen))
+	for esi := uint32(0); esi < d.k; esi++ {
+		out = append(out, d.symbols[esi]...)
+	}
+	return out[:d.size], nil
+}
@@ -0,0 +1,64 @@
+package fec_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomonRoundTrip(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(10000, 40, 64, 1, false)
+	require.NoError(t, err)
+	bs.MaxNumEs = 60
+	obj := make([]byte, 10000)
+	rnd := rand.New(rand.NewSource(3))
+	rnd.Read(obj)
+	for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+		offset := bs.SrcOffset(sbn)
+		block := obj[offset : offset+bs.SrcBlockSize(sbn)]
+		enc, err := fec.NewReedSolomonEncoder(bs, sbn, block)
+		require.NoError(t, err)
+		k, n := enc.NumSrcSym(), enc.NumEncSym()
+		assert.Equal(t, bs.NumSrcSym(sbn), k)
+		assert.Equal(t, k*60/40, n)
+
+		// any k of the n encoding symbols recover the block
+		dec, err := fec.NewReedSolomonDecoder(bs, sbn)
+		require.NoError(t, err)
+		for _, esi := range rnd.Perm(int(n))[:k] {
+			sym, err := enc.Symbol(uint32(esi))
+			require.NoError(t, err)
+			require.NoError(t, dec.AddSymbol(uint32(esi), sym))
+		}
+		assert.True(t, dec.Decodable())
+		out, err := dec.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, block, out)
+
+		_, err = enc.Symbol(n)
+		assert.Error(t, err)
+	}
+}
+
+func TestReedSolomonNotDecodable(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(100, 10, 10, 1, false)
+	require.NoError(t, err)
+	bs.MaxNumEs = 300
+	_, err = fec.NewReedSolomonEncoder(bs, 0, make([]byte, 100))
+	assert.Error(t, err)
+
+	bs.MaxNumEs = 20
+	enc, err := fec.NewReedSolomonEncoder(bs, 0, make([]byte, 100))
+	require.NoError(t, err)
+	dec, err := fec.NewReedSolomonDecoder(bs, 0)
+	require.NoError(t, err)
+	for esi := uint32(11); esi < 20; esi++ {
+		sym, _ := enc.Symbol(esi)
+		require.NoError(t, dec.AddSymbol(esi, sym))
+	}
+	_, err = dec.Decode()
+	assert.ErrorIs(t, err, fec.ErrNotDecodable)
+}
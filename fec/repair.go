@@ -0,0 +1,132 @@
+package fec
+
+import (
+	"math"
+	"sort"
+)
+
+// CostModel prices the unicast repair of an object, in octets: every request
+// costs PerRequest, every range PerRange on top of the octets it carries, e.g.
+// the part headers of a multipart/byteranges response.
+type CostModel struct {
+	PerRequest float64
+	PerRange   float64
+	MaxRanges  int // ranges per request, 0 for no limit
+}
+
+// DefaultRepairCost roughly prices an HTTP/1.1 range request: its request and
+// response headers plus a round trip, and the part headers of every range.
+var DefaultRepairCost = CostModel{PerRequest: 2000, PerRange: 100, MaxRanges: 64}
+
+// Requests returns the number of requests fetching n ranges.
+func (c CostModel) Requests(n int) int {
+	if n == 0 {
+		return 0
+	}
+	if c.MaxRanges <= 0 {
+		return 1
+	}
+	return (n + c.MaxRanges - 1) / c.MaxRanges
+}
+
+// Cost returns the cost of fetching the octet ranges rl.
+func (c CostModel) Cost(rl RangeList) float64 {
+	return c.PerRequest*float64(c.Requests(len(rl))) + c.PerRange*float64(len(rl)) + float64(rl.Count())
+}
+
+// Split returns the ranges of rl grouped by request.
+func (c CostModel) Split(rl RangeList) []RangeList {
+	var out []RangeList
+	for n := c.Requests(len(rl)); n > 0; n-- {
+		i := len(rl)
+		if c.MaxRanges > 0 {
+			i = min(i, c.MaxRanges)
+		}
+		out = append(out, rl[:i])
+		rl = rl[i:]
+	}
+	return out
+}
+
+// Coalesce merges the ranges of rl across the gaps that cost less to fetch than
+// the ranges and requests they save, smallest gaps first.
+func (c CostModel) Coalesce(rl RangeList) RangeList {
+	rl = flatten(append(RangeList{}, rl...))
+	if len(rl) < 2 {
+		return rl
+	}
+	gaps := make([]int, len(rl)-1)
+	for i := range gaps {
+		gaps[i] = i
+	}
+	gap := func(i int) int64 { return rl[i+1].Start - rl[i].End - 1 }
+	sort.SliceStable(gaps, func(i, j int) bool { return gap(gaps[i]) < gap(gaps[j]) })
+	// cost of keeping n ranges once the len(rl)-n smallest gaps are merged
+	best, bestCost := len(rl), math.Inf(1)
+	var filled int64
+	for n := len(rl); n >= 1; n-- {
+		if n < len(rl) {
+			filled += gap(gaps[len(rl)-n-1])
+		}
+		cost := c.PerRequest*float64(c.Requests(n)) + c.PerRange*float64(n) + float64(filled)
+		if cost < bestCost {
+			best, bestCost = n, cost
+		}
+	}
+	merge := make([]bool, len(rl)-1)
+	for _, i := range gaps[:len(rl)-best] {
+		merge[i] = true
+	}
+	out := RangeList{rl[0]}
+	for i, r := range rl[1:] {
+		if merge[i] {
+			out[len(out)-1].End = r.End
+		} else {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// PlanRepair returns the source ESIs to fetch so that every block becomes
+// decodable and the octet ranges of the object to request for them. A block
+// needs threshold(sbn) distinct encoding symbols, e.g. Tracker.Threshold, and
+// already holds its source symbols not in srcMissing and its repair symbols in
+// rprHas. Only the symbols it lacks are picked among the missing ones, longest
+// runs first so that they span the fewest ranges, then the ranges are coalesced
+// under the cost model: they may cover octets already received when it is
+// cheaper. An empty RangeList in srcMissing stands for the whole block.
+func PlanRepair(bs *BlockingStructure, threshold func(sbn uint32) uint32, srcMissing, rprHas ESIRange, cost CostModel) (ESIRange, RangeList) {
+	er := make(ESIRange)
+	for sbn, missing := range srcMissing {
+		missing = wholeBlock(bs, sbn, missing)
+		k := int64(bs.NumSrcSym(sbn))
+		held := k - missing.Count() + rprHas[sbn].Count()
+		need := min(int64(threshold(sbn))-held, missing.Count())
+		if need <= 0 {
+			continue
+		}
+		runs := append(RangeList{}, missing...)
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].Count() > runs[j].Count() })
+		var pick RangeList
+		for _, r := range runs {
+			if need <= 0 {
+				break
+			}
+			r.End = min(r.End, r.Start+need-1)
+			need -= r.Count()
+			pick = append(pick, r)
+		}
+		er[sbn] = flatten(pick)
+	}
+	if len(er) == 0 {
+		return er, nil
+	}
+	object := RangeList{{0, int64(bs.TransferLen.Load()) - 1}}
+	return er, cost.Coalesce(er.ToRangeList(bs, true).Intersection(object))
+}
+
+// PlanRepair returns the repair of the object under the cost model, see PlanRepair.
+func (t *Tracker) PlanRepair(cost CostModel) (ESIRange, RangeList) {
+	return PlanRepair(t.bs, t.Threshold, t.MissingSource(), t.ReceivedRepair(), cost)
+}
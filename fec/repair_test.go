@@ -0,0 +1,69 @@
+package fec_test
+
+import (
+	"testing"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCostModelCoalesce(t *testing.T) {
+	rl := fec.RangeList{{1000, 1099}, {0, 99}, {150, 249}}
+	c := fec.CostModel{PerRange: 100}
+	assert.Equal(t, fec.RangeList{{0, 249}, {1000, 1099}}, c.Coalesce(rl))
+	assert.Equal(t, float64(2*100+350), c.Cost(c.Coalesce(rl)))
+
+	// merging the large gap saves a request
+	c = fec.CostModel{PerRequest: 1000, PerRange: 100, MaxRanges: 1}
+	assert.Equal(t, fec.RangeList{{0, 1099}}, c.Coalesce(rl))
+	c.PerRequest = 500
+	assert.Equal(t, fec.RangeList{{0, 249}, {1000, 1099}}, c.Coalesce(rl))
+
+	c.MaxRanges = 2
+	assert.Equal(t, []fec.RangeList{{{0, 99}, {150, 249}}, {{1000, 1099}}}, c.Split(fec.RangeList{{0, 99}, {150, 249}, {1000, 1099}}))
+	assert.Nil(t, c.Split(nil))
+	assert.Equal(t, fec.RangeList{{1000, 1099}, {0, 99}, {150, 249}}, rl, "rl is left untouched")
+}
+
+func TestPlanRepair(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(4000, 0, 100, 4, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(40), bs.NumSrcSym(0))
+	tr, err := fec.NewTracker(1, bs)
+	require.NoError(t, err)
+	tr.Overhead = 2
+	require.NoError(t, tr.AddESIRange(fec.ESIRange{0: {{0, 1}, {4, 9}, {20, 29}, {31, 39}, {40, 44}}}))
+	assert.Equal(t, fec.ESIRange{0: {{2, 3}, {10, 19}, {30, 30}}}, tr.MissingSource())
+
+	// k + 2 symbols are needed, the longest run of missing ones is enough
+	cost := fec.CostModel{PerRange: 100}
+	er, rl := tr.PlanRepair(cost)
+	assert.Equal(t, fec.ESIRange{0: {{10, 19}}}, er)
+	assert.Equal(t, fec.RangeList{{1000, 1999}}, rl)
+	tr.Overhead = 0
+	er, rl = tr.PlanRepair(cost)
+	assert.Equal(t, fec.ESIRange{0: {{10, 17}}}, er)
+	assert.Equal(t, fec.RangeList{{1000, 1799}}, rl)
+	tr.Overhead = 5
+	er, rl = tr.PlanRepair(cost)
+	assert.Equal(t, fec.ESIRange{0: {{2, 3}, {10, 19}, {30, 30}}}, er)
+	assert.Equal(t, fec.RangeList{{200, 399}, {1000, 1999}, {3000, 3099}}, rl)
+	cost.PerRange = 700
+	_, rl = tr.PlanRepair(cost)
+	assert.Equal(t, fec.RangeList{{200, 1999}, {3000, 3099}}, rl, "the gap of 600 octets is cheaper than a range")
+
+	// all the source symbols decode the block whatever the overhead
+	require.NoError(t, tr.AddESIRange(fec.ESIRange{0: {}}))
+	assert.True(t, tr.Complete())
+	er, rl = tr.PlanRepair(cost)
+	assert.Empty(t, er)
+	assert.Nil(t, rl)
+
+	// nothing received, the ranges of all the blocks coalesce
+	bs, err = fec.NewBlockingStructure5052(950, 4, 100, 1, false)
+	require.NoError(t, err)
+	er, rl = fec.PlanRepair(bs, bs.NumSrcSym, fec.ESIRange{0: {}, 1: {}, 2: {{1, 2}}}, nil, cost)
+	assert.Equal(t, fec.ESIRange{0: {{0, 3}}, 1: {{0, 2}}, 2: {{1, 2}}}, er)
+	assert.Equal(t, fec.RangeList{{0, 949}}, rl)
+}
@@ -0,0 +1,91 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Model is a packet loss channel model.
+type Model interface {
+	// Losses returns the loss pattern of n consecutive packets, true if lost.
+	Losses(rnd *rand.Rand, n int) []bool
+	// LossRate returns the long run fraction of packets lost.
+	LossRate() float64
+}
+
+// Bernoulli loses every packet independently with probability P.
+type Bernoulli struct {
+	P float64
+}
+
+func (b Bernoulli) Losses(rnd *rand.Rand, n int) []bool {
+	lost := make([]bool, n)
+	for i := range lost {
+		lost[i] = rnd.Float64() < b.P
+	}
+	return lost
+}
+
+func (b Bernoulli) LossRate() float64 {
+	return b.P
+}
+
+func (b Bernoulli) String() string {
+	return fmt.Sprintf("bernoulli(p=%g)", b.P)
+}
+
+// GilbertElliott is the two state Markov channel of Gilbert and Elliott: the
+// channel moves from the good to the bad state with probability P and back with
+// probability R after every packet, and loses packets with probability LossGood
+// in the good state and LossBad in the bad one. The simple Gilbert model has
+// LossGood = 0 and LossBad = 1, its mean burst length is 1/R.
+type GilbertElliott struct {
+	P        float64
+	R        float64
+	LossGood float64
+	LossBad  float64
+}
+
+// NewGilbert returns the simple Gilbert model with the given loss rate and mean
+// loss burst length in packets.
+func NewGilbert(lossRate, meanBurst float64) (GilbertElliott, error) {
+	if lossRate <= 0 || lossRate >= 1 || meanBurst < 1 {
+		return GilbertElliott{}, fmt.Errorf("invalid gilbert loss rate %g, mean burst %g", lossRate, meanBurst)
+	}
+	r := 1 / meanBurst
+	return GilbertElliott{P: r * lossRate / (1 - lossRate), R: r, LossBad: 1}, nil
+}
+
+// bad returns the stationary probability of the bad state.
+func (g GilbertElliott) bad() float64 {
+	if g.P+g.R == 0 {
+		return 0
+	}
+	return g.P / (g.P + g.R)
+}
+
+// Losses starts from the stationary state distribution, so that every packet of
+// an object has the same loss probability.
+func (g GilbertElliott) Losses(rnd *rand.Rand, n int) []bool {
+	lost := make([]bool, n)
+	bad := rnd.Float64() < g.bad()
+	for i := range lost {
+		if bad {
+			lost[i] = rnd.Float64() < g.LossBad
+			bad = rnd.Float64() >= g.R
+		} else {
+			lost[i] = rnd.Float64() < g.LossGood
+			bad = rnd.Float64() < g.P
+		}
+	}
+	return lost
+}
+
+func (g GilbertElliott) LossRate() float64 {
+	b := g.bad()
+	return (1-b)*g.LossGood + b*g.LossBad
+}
+
+func (g GilbertElliott) String() string {
+	return fmt.Sprintf("gilbert-elliott(p=%g,r=%g,k=%g,h=%g)", g.P, g.R, 1-g.LossGood, 1-g.LossBad)
+}
@@ -0,0 +1,135 @@
+// Package sim runs transport objects through a FEC blocking structure, a
+// transmission order and a loss channel, to tune the FEC parameters of a
+// delivery offline. Runs are deterministic for a seed.
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+)
+
+// Config is a simulation of Objects transport objects of TransferLen octets.
+type Config struct {
+	FEC         api.FECParamType // Encoding, SymbolLen, MaxSrcBlockLen, NumEsPerGroup and Redundancy
+	TransferLen uint64
+	Order       fec.Order
+	Model       Model
+	Objects     int
+	Seed        int64
+	Overhead    uint32 // RaptorQ symbols over k required to decode a block, see fec.Tracker
+}
+
+// Result sums the outcome of the objects of a simulation.
+type Result struct {
+	Objects     int
+	Received    int    // objects decodable from the multicast packets alone
+	Packets     int    // packets sent
+	Lost        int    // packets lost
+	RepairBytes uint64 // unicast repair bytes requested by the objects not received
+	MaxRepair   uint64 // largest repair bytes of an object
+}
+
+// SuccessProbability returns the fraction of objects received without repair.
+func (r Result) SuccessProbability() float64 {
+	if r.Objects == 0 {
+		return 0
+	}
+	return float64(r.Received) / float64(r.Objects)
+}
+
+// ExpectedRepairBytes returns the mean unicast repair bytes per object.
+func (r Result) ExpectedRepairBytes() float64 {
+	if r.Objects == 0 {
+		return 0
+	}
+	return float64(r.RepairBytes) / float64(r.Objects)
+}
+
+// LossRate returns the fraction of packets lost.
+func (r Result) LossRate() float64 {
+	if r.Packets == 0 {
+		return 0
+	}
+	return float64(r.Lost) / float64(r.Packets)
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("objects=%d,success=%.4f,loss=%.4f,repairBytes=%.0f", r.Objects, r.SuccessProbability(), r.LossRate(), r.ExpectedRepairBytes())
+}
+
+// Run simulates the objects of c one after the other.
+func Run(c Config) (Result, error) {
+	if c.Model == nil {
+		return Result{}, fmt.Errorf("sim: nil loss model")
+	}
+	if c.Objects <= 0 {
+		return Result{}, fmt.Errorf("sim: invalid number of objects %d", c.Objects)
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+	var res Result
+	for toi := uint64(1); toi <= uint64(c.Objects); toi++ {
+		o, err := c.object(toi, rnd)
+		if err != nil {
+			return res, err
+		}
+		res.Objects++
+		res.Packets += o.packets
+		res.Lost += o.lost
+		if o.received {
+			res.Received++
+		}
+		res.RepairBytes += o.repair
+		res.MaxRepair = max(res.MaxRepair, o.repair)
+	}
+	return res, nil
+}
+
+// outcome is the reception of one object.
+type outcome struct {
+	received bool
+	repair   uint64
+	packets  int
+	lost     int
+}
+
+// object sends object toi through the channel and computes the unicast repair
+// bytes needed to complete it.
+func (c Config) object(toi uint64, rnd *rand.Rand) (o outcome, err error) {
+	bs, err := fec.NewBlockingStructureFromFECParam(c.FEC, c.TransferLen)
+	if err != nil {
+		return o, err
+	}
+	planner, err := fec.NewPlanner(bs, c.FEC.Redundancy, c.Order)
+	if err != nil {
+		return o, err
+	}
+	planner.Seed = rnd.Int63()
+	tracker, err := fec.NewTracker(toi, bs)
+	if err != nil {
+		return o, err
+	}
+	tracker.Overhead = c.Overhead
+	losses := c.Model.Losses(rnd, planner.Len())
+	for pkt := range planner.Packets() {
+		if losses[o.packets] {
+			o.lost++
+		} else {
+			r := fec.Range{Start: int64(pkt.ESI), End: int64(pkt.ESI + pkt.NumSymbols - 1)}
+			if err = tracker.AddESIRange(fec.ESIRange{pkt.SBN: {r}}); err != nil {
+				return o, err
+			}
+		}
+		o.packets++
+	}
+	if o.received = tracker.EndReception() == api.FINISHED; o.received {
+		return o, nil
+	}
+	// the last symbol of the object ends with it
+	object := fec.RangeList{{Start: 0, End: int64(bs.TransferLen.Load()) - 1}}
+	missing := tracker.Missing().ToRangeList(bs, true)
+	o.repair = uint64(missing.Intersection(object).Count())
+	return o, nil
+}
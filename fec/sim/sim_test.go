@@ -0,0 +1,86 @@
+package sim_test
+
+import (
+	"math/rand"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/Blockcast/multicast-api/fec/sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModels(t *testing.T) {
+	g, err := sim.NewGilbert(0.05, 8)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.05, g.LossRate(), 1e-9)
+	rnd := rand.New(rand.NewSource(1))
+	for _, m := range []sim.Model{sim.Bernoulli{P: 0.05}, g} {
+		lost := m.Losses(rnd, 200000)
+		n, bursts := 0, 0
+		for i, l := range lost {
+			if l {
+				n++
+				if i == 0 || !lost[i-1] {
+					bursts++
+				}
+			}
+		}
+		assert.InDelta(t, m.LossRate(), float64(n)/float64(len(lost)), 0.005, "%v", m)
+		if m == sim.Model(g) {
+			assert.InDelta(t, 8, float64(n)/float64(bursts), 0.5, "mean burst length")
+		}
+	}
+	_, err = sim.NewGilbert(0, 8)
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	c := sim.Config{
+		FEC:         api.FECParamType{Encoding: api.RS_GF8_FEC_ENC_ID, SymbolLen: 1000, MaxSrcBlockLen: 50, NumEsPerGroup: 1},
+		TransferLen: 500*1000 - 300,
+		Model:       sim.Bernoulli{},
+		Objects:     20,
+		Seed:        1,
+	}
+	res, err := sim.Run(c)
+	require.NoError(t, err)
+	assert.Equal(t, sim.Result{Objects: 20, Received: 20, Packets: 20 * 500}, res)
+
+	// without FEC every lost packet is repaired over unicast
+	c.Model = sim.Bernoulli{P: 0.02}
+	res, err = sim.Run(c)
+	require.NoError(t, err)
+	assert.Less(t, res.SuccessProbability(), 0.1)
+	assert.Greater(t, res.Lost, 0)
+	assert.LessOrEqual(t, res.RepairBytes, uint64(res.Lost)*1000)
+	assert.Greater(t, res.RepairBytes, uint64(res.Lost-20)*1000)
+	again, err := sim.Run(c)
+	require.NoError(t, err)
+	assert.Equal(t, res, again, "deterministic for a seed")
+
+	// interleaving spreads the bursts of a Gilbert channel over the blocks
+	c.FEC.Redundancy = 0.2
+	c.Model, err = sim.NewGilbert(0.05, 10)
+	require.NoError(t, err)
+	c.Objects = 100
+	var success [2]float64
+	for i, order := range []fec.Order{fec.OrderSequential, fec.OrderBlockInterleaved} {
+		c.Order = order
+		res, err = sim.Run(c)
+		require.NoError(t, err)
+		success[i] = res.SuccessProbability()
+	}
+	assert.Greater(t, success[1], success[0])
+
+	c.FEC.Encoding = api.RAPTORQ_FEC_ENC_ID
+	c.FEC.MaxSrcBlockLen = 100
+	c.Overhead = 2
+	res, err = sim.Run(c)
+	require.NoError(t, err)
+	assert.Greater(t, res.SuccessProbability(), 0.5)
+
+	_, err = sim.Run(sim.Config{Model: sim.Bernoulli{}})
+	assert.Error(t, err)
+}
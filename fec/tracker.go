@@ -0,0 +1,312 @@
+package fec
+
+import (
+	"fmt"
+
+	api "github.com/Blockcast/multicast-api"
+	sync "github.com/linkdata/deadlock"
+)
+
+// StateChange is a transition of the reception state of a transport object.
+type StateChange struct {
+	TOI  uint64
+	From api.FState
+	To   api.FState
+}
+
+// Tracker records the encoding symbols received for one transport object and
+// drives its reception state:
+//
+//	WANTED -> RECEIVING       on the first symbol
+//	RECEIVING -> NEEDSREPAIR  on EndReception with blocks not decodable
+//	NEEDSREPAIR -> REPAIRING  on StartRepair
+//	any -> FINISHED           once every source block is decodable
+//	any -> ERROR              on Fail
+//
+// Transitions are published to the subscribers after the tracker is unlocked.
+type Tracker struct {
+	TOI      uint64
+	Overhead uint32 // symbols over k required before a RaptorQ block counts as decodable
+	bs       *BlockingStructure
+	mux      sync.RWMutex
+	src      ESIRange // received source ESIs per SBN
+	rpr      ESIRange // received repair ESIs per SBN
+	done     map[uint32]bool
+	state    api.FState
+	feed     api.FeedOf[StateChange]
+}
+
+// NewTracker returns the tracker of object toi laid out by bs, in state WANTED.
+func NewTracker(toi uint64, bs *BlockingStructure) (*Tracker, error) {
+	if bs == nil || bs.ESLen == 0 || bs.N.Load() == 0 {
+		return nil, fmt.Errorf("invalid bs: %v", bs)
+	}
+	return &Tracker{
+		TOI:   toi,
+		bs:    bs,
+		src:   make(ESIRange),
+		rpr:   make(ESIRange),
+		done:  make(map[uint32]bool),
+		state: api.WANTED,
+	}, nil
+}
+
+// Subscribe delivers the state changes of the object to ch.
+func (t *Tracker) Subscribe(ch chan<- StateChange) api.Subscription {
+	return t.feed.Subscribe(ch)
+}
+
+func (t *Tracker) BlockingStructure() *BlockingStructure {
+	return t.bs
+}
+
+func (t *Tracker) State() api.FState {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.state
+}
+
+// Threshold returns the number of distinct encoding symbols of source block sbn
+// from which it is decodable: k, plus Overhead for RaptorQ which decodes from k
+// symbols with a failure probability below 1%, RFC 6330 section 1.
+func (t *Tracker) Threshold(sbn uint32) uint32 {
+	k := t.bs.NumSrcSym(sbn)
+	if t.bs.Encoding == api.RAPTORQ_FEC_ENC_ID {
+		return k + t.Overhead
+	}
+	return k
+}
+
+// maxESI returns the largest ESI the scheme generates for source block sbn.
+func (t *Tracker) maxESI(sbn uint32) int64 {
+	switch t.bs.Encoding {
+	case api.RAPTORQ_FEC_ENC_ID:
+		return RaptorQMaxESI
+	case api.SB_LB_E_FEC_ENC_ID:
+		return 1<<32 - 1
+	}
+	return int64(t.bs.NumEncSym(sbn)) - 1
+}
+
+func (t *Tracker) setState(s api.FState, changes []StateChange) []StateChange {
+	if t.state == s {
+		return changes
+	}
+	changes = append(changes, StateChange{TOI: t.TOI, From: t.state, To: s})
+	t.state = s
+	return changes
+}
+
+func (t *Tracker) publish(changes []StateChange) {
+	for _, c := range changes {
+		t.feed.Send(c)
+	}
+}
+
+// add records the ESIs of r in source block sbn and returns how many were new.
+func (t *Tracker) add(sbn uint32, r Range) (int64, error) {
+	if sbn >= t.bs.N.Load() {
+		return 0, fmt.Errorf("sbn %d out of range, N=%d", sbn, t.bs.N.Load())
+	}
+	if r.Start < 0 || r.End < r.Start || r.End > t.maxESI(sbn) {
+		return 0, fmt.Errorf("esi range %s of sbn %d out of range", r, sbn)
+	}
+	var n int64
+	k := int64(t.bs.NumSrcSym(sbn))
+	if r.Start < k {
+		rl := t.src[sbn]
+		before := rl.Count()
+		rl.InplaceUnion(RangeList{{r.Start, min(r.End, k-1)}})
+		t.src[sbn] = rl
+		n += rl.Count() - before
+	}
+	if r.End >= k {
+		rl := t.rpr[sbn]
+		before := rl.Count()
+		rl.InplaceUnion(RangeList{{max(r.Start, k), r.End}})
+		t.rpr[sbn] = rl
+		n += rl.Count() - before
+	}
+	// a block with all of its source symbols needs no decoding
+	if !t.done[sbn] && (t.received(sbn) >= int64(t.Threshold(sbn)) || t.src[sbn].Count() == k) {
+		t.done[sbn] = true
+	}
+	return n, nil
+}
+
+// update moves to RECEIVING on the first symbols and to FINISHED once complete.
+func (t *Tracker) update(changes []StateChange) []StateChange {
+	switch t.state {
+	case api.WANTED:
+		changes = t.setState(api.RECEIVING, changes)
+	case api.FINISHED, api.ERROR:
+		return changes
+	}
+	if uint32(len(t.done)) == t.bs.N.Load() {
+		changes = t.setState(api.FINISHED, changes)
+	}
+	return changes
+}
+
+// AddSymbol records the encoding symbol (sbn, esi), source if esi < k and repair
+// otherwise. It reports whether the symbol is new.
+func (t *Tracker) AddSymbol(sbn, esi uint32) (bool, error) {
+	var changes []StateChange
+	t.mux.Lock()
+	n, err := t.add(sbn, Range{int64(esi), int64(esi)})
+	if err == nil {
+		changes = t.update(changes)
+	}
+	t.mux.Unlock()
+	t.publish(changes)
+	return n > 0, err
+}
+
+// AddESIRange records every encoding symbol of er. An empty RangeList stands for
+// all source symbols of the block.
+func (t *Tracker) AddESIRange(er ESIRange) error {
+	var changes []StateChange
+	var err error
+	t.mux.Lock()
+loop:
+	for sbn, rl := range er {
+		if len(rl) == 0 {
+			rl = RangeList{{0, int64(t.bs.NumSrcSym(sbn)) - 1}}
+		}
+		for _, r := range rl {
+			if _, err = t.add(sbn, r); err != nil {
+				break loop
+			}
+		}
+	}
+	changes = t.update(changes)
+	t.mux.Unlock()
+	t.publish(changes)
+	return err
+}
+
+// AddSourceRange records the source symbols fully covered by the octet ranges rl
+// of the object, e.g. from a file repair response.
+func (t *Tracker) AddSourceRange(rl RangeList) error {
+	aligned := make(RangeList, 0, len(rl))
+	if t.bs.SubBlocks > 1 {
+		aligned = append(aligned, rl...)
+	} else {
+		// without sub-blocking symbol boundaries are multiples of ESLen from the
+		// start of the object, and the last symbol ends with the object
+		E := int64(t.bs.ESLen)
+		last := int64(t.bs.TransferLen.Load()) - 1
+		for _, r := range rl {
+			start := (r.Start + E - 1) / E * E
+			end := r.End
+			if end < last {
+				end = (end+1)/E*E - 1
+			}
+			if start <= end {
+				aligned = append(aligned, Range{start, end})
+			}
+		}
+	}
+	if len(aligned) == 0 {
+		return nil
+	}
+	er, err := ESIRangeFromRangeList(t.bs, flatten(aligned), true, false)
+	if err != nil {
+		return err
+	}
+	return t.AddESIRange(er)
+}
+
+func (t *Tracker) received(sbn uint32) int64 {
+	return t.src[sbn].Count() + t.rpr[sbn].Count()
+}
+
+// Received returns the number of distinct source and repair symbols of block sbn.
+func (t *Tracker) Received(sbn uint32) (src, rpr uint32) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return uint32(t.src[sbn].Count()), uint32(t.rpr[sbn].Count())
+}
+
+// Decodable reports whether source block sbn reached its threshold.
+func (t *Tracker) Decodable(sbn uint32) bool {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.done[sbn]
+}
+
+// Complete reports whether every source block is decodable.
+func (t *Tracker) Complete() bool {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return uint32(len(t.done)) == t.bs.N.Load()
+}
+
+// MissingSource returns the source ESIs not received of the blocks that are not decodable.
+func (t *Tracker) MissingSource() ESIRange {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	er := make(ESIRange)
+	for sbn := uint32(0); sbn < t.bs.N.Load(); sbn++ {
+		if t.done[sbn] {
+			continue
+		}
+		all := RangeList{{0, int64(t.bs.NumSrcSym(sbn)) - 1}}
+		er[sbn] = all.Subtract(t.src[sbn])
+	}
+	return er
+}
+
+// ReceivedRepair returns a copy of the repair ESIs received per SBN.
+func (t *Tracker) ReceivedRepair() ESIRange {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.rpr.Clone()
+}
+
+// Missing returns the source ESIs to request so that every block becomes
+// decodable, given the repair symbols received, see GetMissingESIs.
+func (t *Tracker) Missing() ESIRange {
+	return GetMissingESIs(t.MissingSource(), t.ReceivedRepair(), t.bs)
+}
+
+// EndReception marks the end of the delivery of the object: FINISHED if every
+// block is decodable and NEEDSREPAIR otherwise.
+func (t *Tracker) EndReception() api.FState {
+	var changes []StateChange
+	t.mux.Lock()
+	switch {
+	case t.state == api.FINISHED || t.state == api.ERROR:
+	case uint32(len(t.done)) == t.bs.N.Load():
+		changes = t.setState(api.FINISHED, changes)
+	default:
+		changes = t.setState(api.NEEDSREPAIR, changes)
+	}
+	s := t.state
+	t.mux.Unlock()
+	t.publish(changes)
+	return s
+}
+
+// StartRepair moves an object that needs repair to REPAIRING.
+func (t *Tracker) StartRepair() error {
+	var changes []StateChange
+	t.mux.Lock()
+	if t.state != api.NEEDSREPAIR {
+		s := t.state
+		t.mux.Unlock()
+		return fmt.Errorf("toi %d: cannot repair in state %s", t.TOI, s)
+	}
+	changes = t.setState(api.REPAIRING, changes)
+	t.mux.Unlock()
+	t.publish(changes)
+	return nil
+}
+
+// Fail moves the object to ERROR.
+func (t *Tracker) Fail() {
+	t.mux.Lock()
+	changes := t.setState(api.ERROR, nil)
+	t.mux.Unlock()
+	t.publish(changes)
+}
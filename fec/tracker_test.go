@@ -0,0 +1,93 @@
+package fec_test
+
+import (
+	"sync"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerStates(t *testing.T) {
+	bs, err := fec.NewBlockingStructure5052(1000, 4, 100, 1, false)
+	require.NoError(t, err)
+	bs.MaxNumEs = 6
+	tr, err := fec.NewTracker(7, bs)
+	require.NoError(t, err)
+	ch := make(chan fec.StateChange, 10)
+	sub := tr.Subscribe(ch)
+	defer sub.Unsubscribe()
+	assert.Equal(t, api.WANTED, tr.State())
+
+	// blocks of 4, 3 and 3 symbols: block 0 complete, block 1 has ESI 0 and repair ESI 4
+	for _, esi := range []uint32{0, 1, 2, 3} {
+		_, err = tr.AddSymbol(0, esi)
+		require.NoError(t, err)
+	}
+	for _, esi := range []uint32{0, 4} {
+		_, err = tr.AddSymbol(1, esi)
+		require.NoError(t, err)
+	}
+	isNew, err := tr.AddSymbol(1, 4)
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	_, err = tr.AddSymbol(1, 5)
+	assert.Error(t, err)
+	_, err = tr.AddSymbol(3, 0)
+	assert.Error(t, err)
+
+	assert.True(t, tr.Decodable(0))
+	assert.False(t, tr.Decodable(1))
+	src, rpr := tr.Received(1)
+	assert.Equal(t, uint32(1), src)
+	assert.Equal(t, uint32(1), rpr)
+	assert.Equal(t, fec.ESIRange{1: {{1, 2}}, 2: {{0, 2}}}, tr.MissingSource())
+	assert.Equal(t, fec.ESIRange{1: {{1, 1}}, 2: {{0, 2}}}, tr.Missing())
+
+	assert.Equal(t, api.NEEDSREPAIR, tr.EndReception())
+	require.NoError(t, tr.StartRepair())
+	assert.Error(t, tr.StartRepair())
+
+	// repair with octet ranges, partially covered symbols do not count
+	require.NoError(t, tr.AddSourceRange(fec.RangeList{{450, 599}, {700, 950}}))
+	assert.True(t, tr.Decodable(1))
+	assert.False(t, tr.Complete())
+	require.NoError(t, tr.AddSourceRange(fec.RangeList{{900, 999}}))
+	assert.True(t, tr.Complete())
+	assert.Equal(t, api.FINISHED, tr.State())
+
+	var got []api.FState
+	for len(ch) > 0 {
+		c := <-ch
+		assert.Equal(t, uint64(7), c.TOI)
+		got = append(got, c.To)
+	}
+	assert.Equal(t, []api.FState{api.RECEIVING, api.NEEDSREPAIR, api.REPAIRING, api.FINISHED}, got)
+}
+
+func TestTrackerConcurrent(t *testing.T) {
+	bs, err := fec.NewBlockingStructure6330(1<<20, 200, 1024, 4, 0, 1)
+	require.NoError(t, err)
+	tr, err := fec.NewTracker(1, bs)
+	require.NoError(t, err)
+	tr.Overhead = 2
+	var wg sync.WaitGroup
+	for sbn := uint32(0); sbn < bs.N.Load(); sbn++ {
+		wg.Add(1)
+		go func(sbn uint32) {
+			defer wg.Done()
+			k := bs.NumSrcSym(sbn)
+			for esi := uint32(1); esi < k+2; esi++ {
+				_, err := tr.AddSymbol(sbn, esi)
+				assert.NoError(t, err)
+			}
+			assert.False(t, tr.Decodable(sbn))
+			_, err := tr.AddSymbol(sbn, 1000)
+			assert.NoError(t, err)
+		}(sbn)
+	}
+	wg.Wait()
+	assert.Equal(t, api.FINISHED, tr.State())
+}
@@ -58,9 +58,9 @@ func (b BlockRangeReadError) Error() string {
 	return fmt.Sprintf("err=%v, missing %s", b.Err, b.ESIRange.String())
 }
 
-// IETF RFC 7233 section 2.1 Byte Ranges
-// TODO nil start ->  end is length of suffix
-// nil end -> start is length of offset to length
+// IETF RFC 9110 section 14.1.1 Byte Ranges
+// Start == -1 -> End is the length of a suffix range
+// End == -1 -> open range from Start to the end of the representation
 type Range struct {
 	Start int64 `json:"start"`
 	End   int64 `json:"end"`
@@ -103,6 +103,7 @@ func (r Range) Intersection(l Range) *Range {
 }
 
 var rQuery = regexp.MustCompile(`(\d+)(-?)(\d*)`)
+var rSuffixQuery = regexp.MustCompile(`^\s*-(\d+)\s*$`)
 
 func parseLimits(limits []string) (int64, int64, error) {
 	rangeStart, err := strconv.ParseInt(limits[1], 10, 64)
@@ -120,6 +121,11 @@ func parseLimits(limits []string) (int64, int64, error) {
 }
 
 func (r *Range) UnmarshalText(text []byte) (err error) {
+	if limits := rSuffixQuery.FindStringSubmatch(string(text)); limits != nil {
+		r.Start = -1
+		r.End, err = strconv.ParseInt(limits[1], 10, 64)
+		return
+	}
 	if limits := rQuery.FindStringSubmatch(string(text)); limits != nil {
 		r.Start, r.End, err = parseLimits(limits)
 	}
@@ -127,6 +133,9 @@ func (r *Range) UnmarshalText(text []byte) (err error) {
 }
 
 func (r Range) MarshalText() ([]byte, error) {
+	if r.Start == -1 {
+		return []byte(fmt.Sprintf("-%d", r.End)), nil
+	}
 	s := fmt.Sprintf("%d", r.Start)
 	if r.Start == r.End {
 		return []byte(s), nil
@@ -139,6 +148,9 @@ func (r Range) MarshalText() ([]byte, error) {
 }
 
 func (r Range) String() string {
+	if r.Start == -1 {
+		return fmt.Sprintf("-%d", r.End)
+	}
 	s := fmt.Sprintf("%d", r.Start)
 	if r.Start == r.End {
 		return s
@@ -150,6 +162,9 @@ func (r Range) String() string {
 }
 
 func (r Range) StringHTTP() string {
+	if r.Start == -1 {
+		return fmt.Sprintf("-%d", r.End)
+	}
 	s := fmt.Sprintf("%d", r.Start)
 	if r.End == -1 {
 		return s + "-"
@@ -158,6 +173,9 @@ func (r Range) StringHTTP() string {
 }
 
 func (r Range) Count() int64 {
+	if r.Start == -1 {
+		return r.End
+	}
 	return r.End - r.Start + 1
 }
 
@@ -192,7 +210,7 @@ func ParseContentRange(contentRange string) (RangeList, int64, error) {
 				return nil, 0, err
 			}
 		}
-		if (start > 0 || end > 0) &&
+		if limits[1] != "*" &&
 			(len(ret) == 0 ||
 				(ret[len(ret)-1].End < start) && (ret[len(ret)-1].End != -1)) {
 			ret = append(ret, Range{start, end})
@@ -541,7 +559,20 @@ func MakeRandRangeList(start, end, count int) RangeList {
 	}
 	return flatten(rl)
 }
+
+// flatten sorts and coalesces rl in place. Suffix ranges are not positioned
+// until resolved against a length, so only the longest one is kept, last.
 func flatten(rl RangeList) RangeList {
+	suffix := Range{Start: -1, End: -1}
+	closed := rl[:0]
+	for _, r := range rl {
+		if r.Start == -1 {
+			suffix.End = max(suffix.End, r.End)
+		} else {
+			closed = append(closed, r)
+		}
+	}
+	rl = closed
 	sort.Slice(rl, func(i, j int) bool { return rl[i].Start < rl[j].Start })
 	for i := len(rl) - 1; i > 0; i-- {
 		if rl[i].Start <= rl[i-1].End+1 {
@@ -552,6 +583,9 @@ func flatten(rl RangeList) RangeList {
 			rl = rl[:len(rl)-1]
 		}
 	}
+	if suffix.End != -1 {
+		rl = append(rl, suffix)
+	}
 	return rl
 }
 
@@ -564,6 +598,9 @@ func ESRangeListForSBNFromRangeList(bs *BlockingStructure, curSBN uint32, rl Ran
 	if bs == nil {
 		return nil, fmt.Errorf("nil blocking structure")
 	}
+	if bs.SubBlocks > 1 {
+		return subBlockESIs(bs, curSBN, rl, false), nil
+	}
 	var curNumSym = bs.NumSrcSym(curSBN)
 	var sbnOffset = int64(bs.SrcOffset(curSBN))
 	var bSize = int64(bs.SrcBlockSize(curSBN))
@@ -635,6 +672,9 @@ func ESIRangeFromRangeList(bs *BlockingStructure, rl RangeList, source, inclusiv
 	if bs == nil || bs.ESLen == 0 {
 		return nil, fmt.Errorf("invalid bs: %v, bs", bs)
 	}
+	if source && bs.SubBlocks > 1 {
+		return esiRangeFromSubBlocks(bs, rl, inclusive)
+	}
 	er := make(ESIRange)
 	var sbnStart, sbnEnd uint32
 	for _, r := range rl {
@@ -674,7 +714,9 @@ func ESIRangeFromRangeList(bs *BlockingStructure, rl RangeList, source, inclusiv
 				esiStart += numSrcSym
 				esiEnd += numSrcSym
 			}
-
+			if esiEnd < esiStart {
+				continue // no whole symbol, or a block without repair symbols
+			}
 			er[sbn] = append(er[sbn], Range{esiStart, esiEnd})
 		}
 	}
@@ -685,6 +727,87 @@ func ESIRangeFromRangeList(bs *BlockingStructure, rl RangeList, source, inclusiv
 	return er, nil
 }
 
+// esiRangeFromSubBlocks converts source byte ranges of an RFC 6330 sub-blocked
+// object. A source symbol spans one sub-symbol in each sub-block, so it is held
+// when all of its sub-symbols are, or any of them if inclusive.
+func esiRangeFromSubBlocks(bs *BlockingStructure, rl RangeList, inclusive bool) (ESIRange, error) {
+	er := make(ESIRange)
+	for _, r := range rl {
+		if r.Start > r.End {
+			return er, fmt.Errorf("range list not sorted: %s", rl)
+		}
+	}
+	if len(rl) == 0 {
+		return er, nil
+	}
+	sbnEnd := bs.SourceSBN(uint64(rl[len(rl)-1].End))
+	for sbn := bs.SourceSBN(uint64(rl[0].Start)); sbn <= sbnEnd; sbn++ {
+		if esis := subBlockESIs(bs, sbn, rl, inclusive); len(esis) > 0 {
+			er[sbn] = esis
+		}
+	}
+	return er, nil
+}
+
+// subBlockESIs returns the source ESIs of block sbn covered by the byte ranges rl.
+func subBlockESIs(bs *BlockingStructure, sbn uint32, rl RangeList, inclusive bool) RangeList {
+	k := int64(bs.NumSrcSym(sbn))
+	offset := int64(bs.SrcOffset(sbn))
+	// the padding of the last symbol is implicitly present
+	if padEnd, end := offset+k*int64(bs.ESLen)-1, int64(bs.TransferLen.Load()); padEnd >= end && !inclusive {
+		rl = rl.Union(RangeList{{end, padEnd}})
+	}
+	var esis RangeList
+	for j := uint16(0); j < bs.SubBlocks; j++ {
+		ss := int64(bs.SubSymbolLen(j))
+		sub := RangeList{}
+		for _, r := range rl.Intersection(RangeList{{offset, offset + k*ss - 1}}) {
+			start, end := r.Start-offset, r.End-offset
+			esiStart, esiEnd := (start+ss-1)/ss, (end+1)/ss-1
+			if inclusive {
+				esiStart, esiEnd = start/ss, end/ss
+			}
+			if esiEnd >= esiStart {
+				sub = append(sub, Range{esiStart, esiEnd})
+			}
+		}
+		sub = flatten(sub)
+		if j == 0 {
+			esis = sub
+		} else if inclusive {
+			esis = esis.Union(sub)
+		} else {
+			esis = esis.Intersection(sub)
+		}
+		offset += k * ss
+	}
+	return esis
+}
+
+// subBlockRanges returns the byte ranges of the source ESIs esis of block sbn in
+// an RFC 6330 sub-blocked object, clipped to the transfer length.
+func subBlockRanges(bs *BlockingStructure, sbn uint32, esis RangeList) RangeList {
+	k := int64(bs.NumSrcSym(sbn))
+	offset := int64(bs.SrcOffset(sbn))
+	last := int64(bs.TransferLen.Load()) - 1
+	if len(esis) == 0 {
+		esis = RangeList{{0, k - 1}}
+	}
+	var out RangeList
+	for j := uint16(0); j < bs.SubBlocks; j++ {
+		ss := int64(bs.SubSymbolLen(j))
+		for _, r := range esis {
+			start := offset + r.Start*ss
+			end := min(offset+(r.End+1)*ss-1, last)
+			if start <= end {
+				out = append(out, Range{start, end})
+			}
+		}
+		offset += k * ss
+	}
+	return out
+}
+
 var plusRange = regexp.MustCompile(`([\d]+)[+]([\d]+)`)
 var esiRange = regexp.MustCompile(`(%3[Bb]|;)(ESI|esi)=`)
 
@@ -813,10 +936,13 @@ func (er ESIRange) ToRangeList(bs *BlockingStructure, source bool) RangeList {
 	rl := make(RangeList, 0, len(er))
 	var offset, blockSize int64
 	for sbn, r := range er {
+		if source && bs.SubBlocks > 1 {
+			rl = append(rl, subBlockRanges(bs, sbn, r)...)
+			continue
+		}
 		if !source {
-			N := int64(bs.MaxNumEs - bs.MaxSbLen)
 			offset = int64(bs.RprOffset(sbn))
-			blockSize = N * int64(bs.ESLen)
+			blockSize = int64(bs.RprSize(sbn))
 		} else {
 			offset = int64(bs.SrcOffset(sbn))
 			blockSize = int64(bs.SrcBlockSize(sbn))
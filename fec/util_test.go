@@ -27,6 +27,8 @@ func TestContentRange(t *testing.T) {
 		{"bytes */5242997", fec.RangeList{}, 5242997},
 		{"bytes=1-2,3-4/5", fec.RangeList{{1, 2}, {3, 4}}, 5},
 		{"bytes=3046761-3599361", fec.RangeList{{3046761, 3599361}}, -1},
+		{"bytes=0-", fec.RangeList{{0, -1}}, -1},
+		{"bytes 0-0/10", fec.RangeList{{0, 0}}, 10},
 	}
 	for _, test := range testsNominal {
 		cr, l, err := fec.ParseContentRange(test.string)
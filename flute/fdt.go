@@ -0,0 +1,269 @@
+// Package flute models the File Delivery Table of FLUTE, RFC 6726: the FDT
+// Instances describing the files of a session, built from the files of this
+// API on the sending side and parsed on the receiving side.
+package flute
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/Blockcast/multicast-api/sdp"
+)
+
+// Namespace is the namespace of the FDT-Instance schema of RFC 6726.
+const Namespace = "urn:ietf:params:xml:ns:fdt"
+
+// MaxInstanceID is the largest FDT Instance ID, a 20-bit field of EXT_FDT.
+const MaxInstanceID = 1<<20 - 1
+
+// FDT attributes, RFC 6726 section 3.4.2
+const (
+	AttrExpires         = "Expires"
+	AttrComplete        = "Complete"
+	AttrContentLocation = "Content-Location"
+	AttrTOI             = "TOI"
+	AttrContentLength   = "Content-Length"
+	AttrContentType     = "Content-Type"
+	AttrContentEncoding = "Content-Encoding"
+	AttrContentMD5      = "Content-MD5"
+)
+
+// FDTInstance is an FDT Instance, the files it describes and the defaults of
+// their Content-Type and Content-Encoding. Complete declares that no later
+// instance describes other files.
+type FDTInstance struct {
+	ID              uint32    // FDT Instance ID, carried by EXT_FDT and not in the XML
+	Expires         time.Time // encoded in NTP seconds
+	Complete        bool
+	ContentType     string
+	ContentEncoding string
+	Files           []File
+}
+
+// File describes a transport object. OTI holds its FEC OTI attributes, the
+// transfer length of which is TransferLength.
+type File struct {
+	ContentLocation string
+	TOI             uint64
+	ContentLength   uint64 // omitted if 0
+	TransferLength  uint64 // omitted if 0
+	ContentType     string
+	ContentEncoding string
+	ContentMD5      fec.MD5
+	OTI             *fec.OTI
+}
+
+// File returns the file of transport object toi.
+func (f *FDTInstance) File(toi uint64) (File, bool) {
+	for _, file := range f.Files {
+		if file.TOI == toi {
+			return file, true
+		}
+	}
+	return File{}, false
+}
+
+// Expired reports whether f has expired at now.
+func (f *FDTInstance) Expired(now time.Time) bool {
+	return !now.Before(f.Expires)
+}
+
+func attr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+func (f *FDTInstance) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: Namespace, Local: "FDT-Instance"}
+	start.Attr = []xml.Attr{attr(AttrExpires, strconv.FormatUint(sdp.NTP(f.Expires), 10))}
+	if f.Complete {
+		start.Attr = append(start.Attr, attr(AttrComplete, "true"))
+	}
+	if f.ContentType != "" {
+		start.Attr = append(start.Attr, attr(AttrContentType, f.ContentType))
+	}
+	if f.ContentEncoding != "" {
+		start.Attr = append(start.Attr, attr(AttrContentEncoding, f.ContentEncoding))
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := range f.Files {
+		if err := e.EncodeElement(&f.Files[i], xml.StartElement{Name: xml.Name{Local: "File"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (f *File) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if f.ContentLocation == "" || f.TOI == 0 {
+		return fmt.Errorf("fdt: file %q of TOI %d: no Content-Location or TOI", f.ContentLocation, f.TOI)
+	}
+	start.Attr = []xml.Attr{
+		attr(AttrContentLocation, f.ContentLocation),
+		attr(AttrTOI, strconv.FormatUint(f.TOI, 10)),
+	}
+	if f.ContentLength > 0 {
+		start.Attr = append(start.Attr, attr(AttrContentLength, strconv.FormatUint(f.ContentLength, 10)))
+	}
+	if f.TransferLength > 0 {
+		start.Attr = append(start.Attr, attr(fec.FDTAttrTransferLen, strconv.FormatUint(f.TransferLength, 10)))
+	}
+	if f.ContentType != "" {
+		start.Attr = append(start.Attr, attr(AttrContentType, f.ContentType))
+	}
+	if f.ContentEncoding != "" {
+		start.Attr = append(start.Attr, attr(AttrContentEncoding, f.ContentEncoding))
+	}
+	if len(f.ContentMD5) > 0 {
+		start.Attr = append(start.Attr, attr(AttrContentMD5, f.ContentMD5.String()))
+	}
+	if f.OTI != nil {
+		oti := *f.OTI
+		oti.TransferLen = f.TransferLength
+		for _, a := range oti.FDTAttrs() {
+			if a.Name.Local != fec.FDTAttrTransferLen {
+				start.Attr = append(start.Attr, a)
+			}
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// isOTIAttr reports whether a is one of the FEC OTI attributes.
+func isOTIAttr(a xml.Attr) bool {
+	return strings.HasPrefix(a.Name.Local, "FEC-OTI-")
+}
+
+// UnmarshalXML parses an FDT Instance. The FEC OTI attributes of the instance
+// are the defaults of those of its files, other attributes and elements are
+// ignored.
+func (f *FDTInstance) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*f = FDTInstance{}
+	var defaults []xml.Attr
+	expires := false
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Local == AttrExpires:
+			ntp, err := strconv.ParseUint(strings.TrimSpace(a.Value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("fdt: invalid %s %q", AttrExpires, a.Value)
+			}
+			f.Expires, expires = sdp.NTPTime(ntp), true
+		case a.Name.Local == AttrComplete:
+			complete, err := strconv.ParseBool(a.Value)
+			if err != nil {
+				return fmt.Errorf("fdt: invalid %s %q", AttrComplete, a.Value)
+			}
+			f.Complete = complete
+		case a.Name.Local == AttrContentType:
+			f.ContentType = a.Value
+		case a.Name.Local == AttrContentEncoding:
+			f.ContentEncoding = a.Value
+		case isOTIAttr(a):
+			defaults = append(defaults, a)
+		}
+	}
+	if !expires {
+		return fmt.Errorf("fdt: no %s", AttrExpires)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "File" {
+				if err = d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			file, err := parseFile(t, defaults)
+			if err != nil {
+				return err
+			}
+			f.Files = append(f.Files, file)
+			if err = d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// parseFile parses the attributes of File element start, with the FEC OTI
+// attributes defaults of the instance.
+func parseFile(start xml.StartElement, defaults []xml.Attr) (File, error) {
+	var f File
+	otiAttrs := slices.Clone(defaults)
+	for _, a := range start.Attr {
+		var err error
+		switch {
+		case a.Name.Local == AttrContentLocation:
+			f.ContentLocation = a.Value
+		case a.Name.Local == AttrTOI:
+			f.TOI, err = strconv.ParseUint(a.Value, 10, 64)
+		case a.Name.Local == AttrContentLength:
+			f.ContentLength, err = strconv.ParseUint(a.Value, 10, 64)
+		case a.Name.Local == fec.FDTAttrTransferLen:
+			f.TransferLength, err = strconv.ParseUint(a.Value, 10, 64)
+		case a.Name.Local == AttrContentType:
+			f.ContentType = a.Value
+		case a.Name.Local == AttrContentEncoding:
+			f.ContentEncoding = a.Value
+		case a.Name.Local == AttrContentMD5:
+			f.ContentMD5, err = base64.StdEncoding.DecodeString(a.Value)
+		case isOTIAttr(a):
+			otiAttrs = append(otiAttrs, a)
+		}
+		if err != nil {
+			return f, fmt.Errorf("fdt: invalid %s %q", a.Name.Local, a.Value)
+		}
+	}
+	if f.ContentLocation == "" || f.TOI == 0 {
+		return f, fmt.Errorf("fdt: file %q of TOI %d: no %s or %s", f.ContentLocation, f.TOI, AttrContentLocation, AttrTOI)
+	}
+	if len(otiAttrs) > 0 {
+		oti, err := fec.OTIFromFDTAttrs(append(otiAttrs, attr(fec.FDTAttrTransferLen, strconv.FormatUint(f.TransferLength, 10))))
+		if err != nil {
+			return f, fmt.Errorf("fdt: file %s: %w", f.ContentLocation, err)
+		}
+		f.OTI = oti
+	}
+	return f, nil
+}
+
+// MarshalFDTInstance returns the XML document of f.
+func MarshalFDTInstance(f *FDTInstance) ([]byte, error) {
+	out, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// ParseFDTInstance parses the FDT Instance XML document data received with
+// FDT Instance ID id.
+func ParseFDTInstance(id uint32, data []byte) (*FDTInstance, error) {
+	if id > MaxInstanceID {
+		return nil, fmt.Errorf("fdt: instance ID %d exceeds 20 bits", id)
+	}
+	f := &FDTInstance{}
+	if err := xml.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	f.ID = id
+	return f, nil
+}
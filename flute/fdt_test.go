@@ -0,0 +1,120 @@
+package flute_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/Blockcast/multicast-api/flute"
+	"github.com/Blockcast/multicast-api/sdp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the FDT Instance example of RFC 6726
+const example = `<?xml version="1.0" encoding="UTF-8"?>
+<FDT-Instance xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+    xmlns:fl="http://www.example.com/flute"
+    xsi:schemaLocation="http://www.example.com/flute-fdt.xsd"
+    Expires="2890842807">
+  <File
+      Content-Location="http://www.example.com/menu/tracklist.html"
+      TOI="1"
+      Content-Type="text/html"/>
+  <File
+      Content-Location="http://www.example.com/tracks/track1.mp3"
+      TOI="2"
+      Content-Length="6100"
+      Content-Type="audio/mp3"
+      Content-Encoding="gzip"
+      Content-MD5="+VP5IrWploFkZWc11iLDdA=="
+      Some-Private-Extension-Tag="abc123"/>
+</FDT-Instance>`
+
+func TestParseExample(t *testing.T) {
+	f, err := flute.ParseFDTInstance(3, []byte(example))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), f.ID)
+	assert.Equal(t, sdp.NTPTime(2890842807), f.Expires)
+	assert.True(t, f.Expired(time.Now()))
+	assert.False(t, f.Complete)
+	require.Len(t, f.Files, 2)
+	assert.Equal(t, flute.File{ContentLocation: "http://www.example.com/menu/tracklist.html", TOI: 1, ContentType: "text/html"}, f.Files[0])
+
+	file, ok := f.File(2)
+	require.True(t, ok)
+	assert.Equal(t, "http://www.example.com/tracks/track1.mp3", file.ContentLocation)
+	assert.Equal(t, uint64(6100), file.ContentLength)
+	assert.Equal(t, "gzip", file.ContentEncoding)
+	assert.Equal(t, "+VP5IrWploFkZWc11iLDdA==", file.ContentMD5.String())
+	assert.Nil(t, file.OTI)
+	_, ok = f.File(3)
+	assert.False(t, ok)
+}
+
+func TestMarshal(t *testing.T) {
+	oti, err := fec.NewOTI(api.FECParamType{Encoding: api.RAPTORQ_FEC_ENC_ID, SymbolLen: 1280, MaxSrcBlockLen: 64, NumEsPerGroup: 1}, 100000)
+	require.NoError(t, err)
+	f := &flute.FDTInstance{
+		ID:       7,
+		Expires:  time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+		Complete: true,
+		Files: []flute.File{
+			{ContentLocation: "http://example.com/a.bin", TOI: 1, ContentLength: 100000, TransferLength: 100000, ContentMD5: fec.MD5{1, 2, 3}, OTI: oti},
+			{ContentLocation: "http://example.com/b.txt", TOI: 2, ContentLength: 10, TransferLength: 10, ContentType: "text/plain"},
+		},
+	}
+	out, err := flute.MarshalFDTInstance(f)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<FDT-Instance xmlns="urn:ietf:params:xml:ns:fdt" Expires="3913059600" Complete="true">`,
+		`  <File Content-Location="http://example.com/a.bin" TOI="1" Content-Length="100000" Transfer-Length="100000" Content-MD5="AQID" FEC-OTI-FEC-Encoding-ID="6" FEC-OTI-Encoding-Symbol-Length="1280" FEC-OTI-Scheme-Specific-Info="AgABBA=="></File>`,
+		`  <File Content-Location="http://example.com/b.txt" TOI="2" Content-Length="10" Transfer-Length="10" Content-Type="text/plain"></File>`,
+		`</FDT-Instance>`,
+	}, "\n"), string(out))
+
+	parsed, err := flute.ParseFDTInstance(7, out)
+	require.NoError(t, err)
+	// the RaptorQ FEC OTI carries no maximum source block length
+	rq := *oti
+	rq.MaxSbLen, rq.MaxNumEs = 0, 0
+	f.Files[0].OTI = &rq
+	assert.Equal(t, f, parsed)
+}
+
+func TestInstanceOTI(t *testing.T) {
+	// the FEC OTI of the instance is the default of its files
+	const text = `<FDT-Instance Expires="3913261200" FEC-OTI-FEC-Encoding-ID="0" FEC-OTI-Maximum-Source-Block-Length="64" FEC-OTI-Encoding-Symbol-Length="1400">
+  <File Content-Location="a" TOI="1" Transfer-Length="1000"/>
+  <File Content-Location="b" TOI="2" Transfer-Length="2000" FEC-OTI-Encoding-Symbol-Length="1000"><Cache-Control/></File>
+  <Extension/>
+</FDT-Instance>`
+	f, err := flute.ParseFDTInstance(0, []byte(text))
+	require.NoError(t, err)
+	require.Len(t, f.Files, 2)
+	assert.Equal(t, &fec.OTI{Encoding: api.COM_NO_C_FEC_ENC_ID, TransferLen: 1000, ESLen: 1400, MaxSbLen: 64}, f.Files[0].OTI)
+	assert.Equal(t, &fec.OTI{Encoding: api.COM_NO_C_FEC_ENC_ID, TransferLen: 2000, ESLen: 1000, MaxSbLen: 64}, f.Files[1].OTI)
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, text := range map[string]string{
+		"no expires":  `<FDT-Instance><File Content-Location="a" TOI="1"/></FDT-Instance>`,
+		"bad expires": `<FDT-Instance Expires="soon"/>`,
+		"no toi":      `<FDT-Instance Expires="1"><File Content-Location="a"/></FDT-Instance>`,
+		"toi 0":       `<FDT-Instance Expires="1"><File Content-Location="a" TOI="0"/></FDT-Instance>`,
+		"bad md5":     `<FDT-Instance Expires="1"><File Content-Location="a" TOI="1" Content-MD5="!"/></FDT-Instance>`,
+		"bad oti":     `<FDT-Instance Expires="1"><File Content-Location="a" TOI="1" FEC-OTI-FEC-Encoding-ID="x"/></FDT-Instance>`,
+		"truncated":   `<FDT-Instance Expires="1"><File Content-Location="a" TOI="1"/>`,
+	} {
+		_, err := flute.ParseFDTInstance(0, []byte(text))
+		assert.Error(t, err, name)
+	}
+	_, err := flute.ParseFDTInstance(flute.MaxInstanceID+1, []byte(example))
+	assert.ErrorContains(t, err, "exceeds 20 bits")
+
+	_, err = flute.MarshalFDTInstance(&flute.FDTInstance{Files: []flute.File{{ContentLocation: "a"}}})
+	assert.ErrorContains(t, err, "no Content-Location or TOI")
+}
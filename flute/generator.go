@@ -0,0 +1,121 @@
+package flute
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	sync "github.com/linkdata/deadlock"
+)
+
+// DefaultExpiry is the validity of the FDT Instances of a Generator.
+const DefaultExpiry = time.Hour
+
+// Generator builds the FDT Instances of a files session over time. Each version
+// of a file, by Content-Location, ETag and size, keeps its TOI while described,
+// and a new FDT Instance ID is taken whenever the description changes or the
+// previous instance is past half its validity. The zero value is ready to use.
+type Generator struct {
+	Expiry   time.Duration     // validity of each instance, DefaultExpiry if 0
+	FEC      *api.FECParamType // FEC parameters of the files, no FEC OTI if nil
+	Complete bool              // the files are final, declared by the instances
+
+	mux     sync.Mutex
+	tois    map[string]uint64
+	nextTOI uint64
+	last    *FDTInstance
+}
+
+// Instance returns the FDT Instance describing files at now. Files of unknown
+// size are left out until known, Content-MD5 is left to the caller.
+func (g *Generator) Instance(files api.FilesType, now time.Time) (*FDTInstance, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	expiry := g.Expiry
+	if expiry == 0 {
+		expiry = DefaultExpiry
+	}
+	tois := make(map[string]uint64, len(files.File))
+	var described []File
+	for _, fp := range files.File {
+		if fp.Size == nil {
+			continue
+		}
+		if *fp.Size < 0 {
+			return nil, fmt.Errorf("fdt: file %s: negative size %d", fp.Url, *fp.Size)
+		}
+		loc, err := contentLocation(files, fp)
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(*fp.Size)
+		f := File{
+			ContentLocation: loc,
+			ContentLength:   size,
+			TransferLength:  size,
+			ContentType:     mime.TypeByExtension(path.Ext(loc)),
+		}
+		if g.FEC != nil {
+			if f.OTI, err = fec.NewOTI(*g.FEC, size); err != nil {
+				return nil, fmt.Errorf("fdt: file %s: %w", loc, err)
+			}
+		}
+		version := loc + "\x00" + strconv.FormatUint(size, 10)
+		if fp.ETag != nil {
+			version += "\x00" + *fp.ETag
+		}
+		toi, ok := g.tois[version]
+		if !ok {
+			g.nextTOI++
+			toi = g.nextTOI
+		}
+		tois[version], f.TOI = toi, toi
+		described = append(described, f)
+	}
+	g.tois = tois
+
+	if g.last != nil && g.last.Complete == g.Complete && reflect.DeepEqual(g.last.Files, described) &&
+		now.Before(g.last.Expires.Add(-expiry/2)) {
+		return g.last.clone(), nil
+	}
+	id := uint32(0)
+	if g.last != nil {
+		id = (g.last.ID + 1) & MaxInstanceID
+	}
+	g.last = &FDTInstance{ID: id, Expires: now.Add(expiry).Truncate(time.Second), Complete: g.Complete, Files: described}
+	return g.last.clone(), nil
+}
+
+// clone returns a copy of f the caller may modify.
+func (f *FDTInstance) clone() *FDTInstance {
+	c := *f
+	c.Files = slices.Clone(f.Files)
+	return &c
+}
+
+// contentLocation returns the Content-Location of fp, its display URL, else
+// its URL resolved against the display base URL of files if any.
+func contentLocation(files api.FilesType, fp api.FilePull) (string, error) {
+	if fp.DisplayUrl != "" {
+		return fp.DisplayUrl, nil
+	}
+	if files.DisplayBaseUrl == nil {
+		return fp.Url, nil
+	}
+	u, err := url.Parse(fp.Url)
+	if err != nil {
+		return "", fmt.Errorf("fdt: invalid url %q: %w", fp.Url, err)
+	}
+	base, err := url.Parse(*files.DisplayBaseUrl)
+	if err != nil {
+		return "", fmt.Errorf("fdt: invalid display base url %q: %w", *files.DisplayBaseUrl, err)
+	}
+	return base.JoinPath(u.Path).String(), nil
+}
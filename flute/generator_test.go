@@ -0,0 +1,85 @@
+package flute_test
+
+import (
+	"mime"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/flute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestGenerator(t *testing.T) {
+	files := api.FilesType{
+		DisplayBaseUrl: ptr("https://display.example.com/files"),
+		File: []api.FilePull{
+			{Url: "https://origin.example.com/a/data.json", Size: ptr(1000), ETag: ptr(`"v1"`)},
+			{Url: "https://origin.example.com/b.bin", DisplayUrl: "https://display.example.com/b", Size: ptr(2000)},
+			{Url: "https://origin.example.com/pending.bin"},
+		},
+	}
+	g := &flute.Generator{Expiry: time.Minute}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := g.Instance(files, now)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), f.ID)
+	assert.Equal(t, now.Add(time.Minute), f.Expires)
+	assert.False(t, f.Complete)
+	assert.Equal(t, []flute.File{
+		{ContentLocation: "https://display.example.com/files/a/data.json", TOI: 1, ContentLength: 1000, TransferLength: 1000, ContentType: mime.TypeByExtension(".json")},
+		{ContentLocation: "https://display.example.com/b", TOI: 2, ContentLength: 2000, TransferLength: 2000},
+	}, f.Files, "the pending file is left out")
+
+	// unchanged within half the expiry
+	f.Files[0].ContentMD5 = []byte{1}
+	again, err := g.Instance(files, now.Add(29*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), again.ID)
+	assert.Equal(t, f.Expires, again.Expires)
+	assert.Nil(t, again.Files[0].ContentMD5, "a copy is returned")
+
+	// refreshed
+	again, err = g.Instance(files, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), again.ID)
+	assert.Equal(t, now.Add(90*time.Second), again.Expires)
+
+	// a new version of a file and a new file
+	files.File[0].ETag = ptr(`"v2"`)
+	files.File[2].Size = ptr(10)
+	again, err = g.Instance(files, now.Add(31*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), again.ID)
+	require.Len(t, again.Files, 3)
+	assert.Equal(t, uint64(3), again.Files[0].TOI)
+	assert.Equal(t, uint64(2), again.Files[1].TOI)
+	assert.Equal(t, uint64(4), again.Files[2].TOI)
+
+	g.Complete = true
+	again, err = g.Instance(files, now.Add(32*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), again.ID)
+	assert.True(t, again.Complete)
+}
+
+func TestGeneratorFEC(t *testing.T) {
+	g := &flute.Generator{FEC: &api.FECParamType{Encoding: api.RAPTORQ_FEC_ENC_ID, SymbolLen: 1280, MaxSrcBlockLen: 64, NumEsPerGroup: 1}}
+	files := api.FilesType{File: []api.FilePull{{Url: "https://origin.example.com/a.bin", Size: ptr(100000)}}}
+	f, err := g.Instance(files, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, f.Files[0].OTI)
+	assert.Equal(t, uint64(100000), f.Files[0].OTI.TransferLen)
+	out, err := flute.MarshalFDTInstance(f)
+	require.NoError(t, err)
+	parsed, err := flute.ParseFDTInstance(f.ID, out)
+	require.NoError(t, err)
+	assert.Equal(t, f.Files[0].OTI.SchemeSpecificInfo(), parsed.Files[0].OTI.SchemeSpecificInfo())
+
+	files.File[0].Size = ptr(-1)
+	_, err = g.Instance(files, time.Now())
+	assert.ErrorContains(t, err, "negative size")
+}
@@ -0,0 +1,35 @@
+// Command schemagen writes the JSON Schema and the OpenAPI components of the
+// API types, e.g. to publish them with a release.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/Blockcast/multicast-api/schema"
+)
+
+func main() {
+	jsonSchema := flag.String("schema", "multicast-api.schema.json", "JSON Schema output file")
+	openAPI := flag.String("openapi", "multicast-api.openapi.json", "OpenAPI output file")
+	id := flag.String("id", "", "$id of the JSON Schema")
+	version := flag.String("version", "0.0.0", "version of the OpenAPI document")
+	flag.Parse()
+
+	if err := write(*jsonSchema, schema.JSONSchema(*id)); err != nil {
+		log.Fatal(err)
+	}
+	if err := write(*openAPI, schema.NewOpenAPI("Multicast API", *version)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func write(name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0o644)
+}
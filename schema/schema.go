@@ -0,0 +1,363 @@
+// Package schema publishes the JSON contract of the API types as JSON Schema
+// 2020-12 and OpenAPI 3.1 components. It reflects over the types like
+// encoding/json does and reads the tags and methods the types carry for it:
+// required, minimum, minItems and description tags, Enum and NamedEnum methods,
+// and custom marshalers such as those of Duration, TimeZ and netip.Addr.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	gsma "github.com/Blockcast/multicast-api/3gpp/models"
+)
+
+const (
+	Draft202012 = "https://json-schema.org/draft/2020-12/schema"
+	OpenAPI31   = "3.1.0"
+)
+
+// Types are the API types published by default.
+var Types = []any{
+	api.Service{},
+	api.Session{},
+	api.DeliveryMethod{},
+	api.AMTRelayConfig{},
+	api.BlockcastReceptionReport{},
+}
+
+// Schema is a JSON Schema 2020-12 schema, also an OpenAPI 3.1 Schema Object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	EnumNames            []string           `json:"x-enumNames,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	PrefixItems          []*Schema          `json:"prefixItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// Document is a JSON Schema document defining the types in $defs, any of the
+// root types being valid.
+type Document struct {
+	Schema string             `json:"$schema"`
+	ID     string             `json:"$id,omitempty"`
+	AnyOf  []*Schema          `json:"anyOf,omitempty"`
+	Defs   map[string]*Schema `json:"$defs"`
+}
+
+// OpenAPI is an OpenAPI 3.1 document holding the schemas of the types as
+// components.
+type OpenAPI struct {
+	OpenAPI           string     `json:"openapi"`
+	Info              Info       `json:"info"`
+	JSONSchemaDialect string     `json:"jsonSchemaDialect"`
+	Components        Components `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// JSONSchema returns the JSON Schema document of types, Types if none.
+func JSONSchema(id string, types ...any) *Document {
+	g := NewGenerator("#/$defs/")
+	d := &Document{Schema: Draft202012, ID: id}
+	for _, v := range orTypes(types) {
+		d.AnyOf = append(d.AnyOf, g.Add(v))
+	}
+	d.Defs = g.Defs()
+	return d
+}
+
+// NewOpenAPI returns the OpenAPI document of types, Types if none.
+func NewOpenAPI(title, version string, types ...any) *OpenAPI {
+	g := NewGenerator("#/components/schemas/")
+	for _, v := range orTypes(types) {
+		g.Add(v)
+	}
+	return &OpenAPI{
+		OpenAPI:           OpenAPI31,
+		Info:              Info{Title: title, Version: version},
+		JSONSchemaDialect: Draft202012,
+		Components:        Components{Schemas: g.Defs()},
+	}
+}
+
+func orTypes(types []any) []any {
+	if len(types) == 0 {
+		return Types
+	}
+	return types
+}
+
+// Generator builds the schemas of Go types, named struct types being defined
+// once and referenced under a prefix.
+type Generator struct {
+	prefix    string
+	defs      map[string]*Schema
+	names     map[reflect.Type]string
+	overrides map[reflect.Type]*Schema
+}
+
+// NewGenerator returns a generator referencing definitions under prefix, e.g.
+// "#/$defs/".
+func NewGenerator(prefix string) *Generator {
+	g := &Generator{
+		prefix:    prefix,
+		defs:      make(map[string]*Schema),
+		names:     make(map[reflect.Type]string),
+		overrides: make(map[reflect.Type]*Schema),
+	}
+	for t, s := range builtin {
+		g.overrides[t] = s
+	}
+	return g
+}
+
+// builtin are the schemas of the types whose marshalers encode them as
+// something else than their Go kind suggests.
+var builtin = map[reflect.Type]*Schema{
+	reflect.TypeOf(time.Time{}): {Type: "string", Format: "date-time"},
+	reflect.TypeOf(api.TimeZ{}): {Type: "string", Format: "date-time"},
+	reflect.TypeOf(api.Duration(0)): {
+		Type:        "string",
+		Pattern:     `^(-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+|0|[0-9]+:[0-9]+(:[0-9]+(\.[0-9]*)?)?)$`,
+		Description: "duration, e.g. 1h30m or 01:30:00",
+	},
+	reflect.TypeOf(netip.Addr{}): {AnyOf: []*Schema{
+		{Type: "string", Format: "ipv4"},
+		{Type: "string", Format: "ipv6"},
+	}},
+	reflect.TypeOf(netip.Prefix{}):    {Type: "string", Description: "IP prefix in CIDR notation"},
+	reflect.TypeOf(netip.AddrPort{}):  {Type: "string", Description: "IP address and port"},
+	reflect.TypeOf(json.RawMessage{}): {},
+	reflect.TypeOf(api.JSONStruct{}):  {},
+	reflect.TypeOf(gsma.Name{}): {
+		Type:        "array",
+		Description: "name and language",
+		PrefixItems: []*Schema{{Type: "string"}, {Type: "string"}},
+		MinItems:    ptr(2),
+		MaxItems:    ptr(2),
+	},
+}
+
+// Override sets the schema of the type of v, e.g. for a custom marshaler.
+func (g *Generator) Override(v any, s *Schema) {
+	g.overrides[reflect.TypeOf(v)] = s
+}
+
+// Add defines the type of v and returns its schema, a reference for named
+// struct types.
+func (g *Generator) Add(v any) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+// Defs returns the definitions of the types added.
+func (g *Generator) Defs() map[string]*Schema {
+	return g.defs
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	enumType      = reflect.TypeOf((*interface{ Enum() []any })(nil)).Elem()
+	namedEnumType = reflect.TypeOf((*interface{ NamedEnum() ([]any, []string) })(nil)).Elem()
+	modulePath    = reflect.TypeOf(api.Service{}).PkgPath()
+)
+
+func implements(t, i reflect.Type) bool {
+	return t.Implements(i) || t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(i)
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	if s, ok := g.overrides[t]; ok {
+		return clone(s)
+	}
+	if t.Kind() == reflect.Pointer {
+		return g.schema(t.Elem())
+	}
+	if s := enum(t); s != nil {
+		return s
+	}
+	switch {
+	case implements(t, jsonMarshaler):
+		return &Schema{}
+	case implements(t, textMarshaler):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s := &Schema{Type: "integer", Minimum: ptr(0.0)}
+		if bits := t.Bits(); bits < 64 {
+			s.Maximum = ptr(float64(uint64(1)<<bits - 1))
+		}
+		return s
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		s := &Schema{Type: "array", Items: g.schema(t.Elem())}
+		if t.Kind() == reflect.Array {
+			s.MinItems, s.MaxItems = ptr(t.Len()), ptr(t.Len())
+		}
+		return s
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := g.name(t)
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = &Schema{} // placeholder for recursive types
+			g.defs[name] = g.object(t)
+		}
+		return &Schema{Ref: g.prefix + name}
+	}
+	return &Schema{}
+}
+
+// name returns the definition name of t: its name for the types of the root
+// package, qualified by the package path within the module otherwise.
+func (g *Generator) name(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != modulePath {
+		pkg = strings.TrimPrefix(strings.TrimPrefix(pkg, modulePath), "/")
+		name = strings.NewReplacer("/", ".", "[", "_", "]", "_", "*", "").Replace(pkg + "." + name)
+	}
+	g.names[t] = name
+	return name
+}
+
+// enum returns the schema of the types with an Enum or a NamedEnum method, nil
+// for the others.
+func enum(t reflect.Type) *Schema {
+	var values []any
+	var names []string
+	z := reflect.New(t).Elem()
+	switch {
+	case t.Implements(namedEnumType):
+		values, names = z.Interface().(interface{ NamedEnum() ([]any, []string) }).NamedEnum()
+	case t.Implements(enumType):
+		values = z.Interface().(interface{ Enum() []any }).Enum()
+	default:
+		return nil
+	}
+	s := &Schema{EnumNames: names}
+	switch t.Kind() {
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Type = "integer"
+	}
+	for _, v := range values {
+		// encode the values as encoding/json would, not by Go type
+		b, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		var x any
+		if json.Unmarshal(b, &x) == nil {
+			s.Enum = append(s.Enum, x)
+		}
+	}
+	return s
+}
+
+// object returns the schema of struct t, embedded structs inlined like
+// encoding/json does.
+func (g *Generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.fields(s, t)
+	return s
+}
+
+func (g *Generator) fields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.fields(s, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		p := g.schema(f.Type)
+		if opts == "string" || strings.Contains(opts, ",string") {
+			p = &Schema{Type: "string"}
+		}
+		// keywords next to $ref apply on top of the definition in 2020-12
+		if d := f.Tag.Get("description"); d != "" {
+			p.Description = d
+		}
+		if v, err := strconv.ParseFloat(f.Tag.Get("minimum"), 64); err == nil {
+			p.Minimum = ptr(v)
+		}
+		if n, err := strconv.Atoi(f.Tag.Get("minItems")); err == nil {
+			p.MinItems = ptr(n)
+		}
+		if f.Type.Kind() == reflect.Pointer && !strings.Contains(opts, "omitempty") {
+			p = &Schema{AnyOf: []*Schema{p, {Type: "null"}}}
+		}
+		s.Properties[name] = p
+		if f.Tag.Get("required") == "true" {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func clone(s *Schema) *Schema {
+	c := *s
+	return &c
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
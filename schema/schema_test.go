@@ -0,0 +1,88 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	d := schema.JSONSchema("https://example.com/multicast-api.json")
+	assert.Equal(t, schema.Draft202012, d.Schema)
+	require.Len(t, d.AnyOf, len(schema.Types))
+	assert.Equal(t, "#/$defs/Service", d.AnyOf[0].Ref)
+
+	dm := d.Defs["DeliveryMethod"]
+	require.NotNil(t, dm)
+	assert.Equal(t, []string{"bitrate_kbps", "fec", "StoreType"}, dm.Required)
+	assert.Equal(t, []any{"memory", "mmap", "disk", "souin"}, dm.Properties["StoreType"].Enum)
+	assert.Equal(t, 1, *dm.Properties["fec"].MinItems)
+	assert.Equal(t, "#/$defs/FECParamType", dm.Properties["fec"].Items.Ref)
+	assert.Equal(t, "paths to route over unicast", dm.Properties["unicast_base_pattern"].Description)
+	assert.Equal(t, "string", dm.Properties["repair_window"].Type)
+	assert.NotEmpty(t, dm.Properties["repair_window"].Pattern)
+	// *Duration without omitempty encodes nil as null
+	require.Len(t, dm.Properties["duration"].AnyOf, 2)
+	assert.Equal(t, "null", dm.Properties["duration"].AnyOf[1].Type)
+
+	fec := d.Defs["FECParamType"]
+	assert.Equal(t, "integer", fec.Properties["encoding"].Type)
+	assert.Contains(t, fec.Properties["encoding"].Enum, float64(api.RAPTORQ_FEC_ENC_ID))
+	assert.Contains(t, fec.Properties["encoding"].EnumNames, "RaptorQ")
+	assert.Equal(t, 0.0, *fec.Properties["redundancy"].Minimum)
+	assert.Equal(t, 65535.0, *fec.Properties["symLength"].Maximum)
+
+	ep := d.Defs["MulticastEndpointAddressType"]
+	require.Len(t, ep.Properties["destGroupAddr"].AnyOf, 2)
+	assert.Equal(t, "ipv4", ep.Properties["destGroupAddr"].AnyOf[0].Format)
+
+	br := d.Defs["BitRateType"]
+	assert.Equal(t, []string{"avg", "max"}, br.Required)
+	assert.Equal(t, 1.0, *br.Properties["avg"].Minimum)
+
+	// the embedded FilesType is inlined as encoding/json does
+	s := d.Defs["Session"]
+	assert.Contains(t, s.Properties, "filePull")
+	assert.Equal(t, "#/$defs/FilePull", s.Properties["filePull"].Items.Ref)
+	assert.Equal(t, "date-time", d.Defs["RRuleSet"].Properties["dtstart"].Format)
+	assert.Equal(t, "array", d.Defs["Service"].Properties["name"].Items.Type)
+	assert.Contains(t, d.Defs, "dvb.models.PresentationManifestLocator")
+	// fields without a json tag keep their Go name
+	assert.Contains(t, d.Defs["BlockcastFileURI"].Properties, "ReceptionSuccess")
+	assert.NotContains(t, d.Defs["BlockcastReceptionReport"].Properties, "XMLName")
+
+	_, err := json.Marshal(d)
+	require.NoError(t, err)
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := schema.NewOpenAPI("Multicast API", "1.0.0", api.Session{})
+	assert.Equal(t, schema.OpenAPI31, doc.OpenAPI)
+	s := doc.Components.Schemas["Session"]
+	require.NotNil(t, s)
+	assert.Equal(t, "#/components/schemas/DeliveryMethod", s.Properties["streams"].Items.Ref)
+	assert.NotContains(t, doc.Components.Schemas, "Service")
+
+	b, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, "3.1.0", m["openapi"])
+}
+
+func TestOverride(t *testing.T) {
+	type Custom struct{ X int }
+	type Wrapper struct {
+		C Custom `json:"c" description:"overridden"`
+	}
+	g := schema.NewGenerator("#/$defs/")
+	g.Override(Custom{}, &schema.Schema{Type: "string"})
+	assert.Equal(t, "#/$defs/schema_test.Wrapper", g.Add(Wrapper{}).Ref)
+	c := g.Defs()["schema_test.Wrapper"].Properties["c"]
+	assert.Equal(t, "string", c.Type)
+	assert.Equal(t, "overridden", c.Description)
+}
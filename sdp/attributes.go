@@ -0,0 +1,234 @@
+package sdp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// Attributes of multicast delivery, of 3GPP TS 26.346 unless noted
+const (
+	AttrSourceFilter    = "source-filter" // RFC 4570
+	AttrFLUTETSI        = "flute-tsi"
+	AttrFLUTECh         = "flute-ch" // number of channels of the FLUTE session
+	AttrFECDeclaration  = "FEC-declaration"
+	AttrFECOTIExtension = "FEC-OTI-extension"
+	AttrFEC             = "FEC" // reference to a FEC-declaration of the session
+	AttrMBMSRepair      = "mbms-repair"
+	AttrMBMSFlowID      = "mbms-flowid"
+)
+
+// SourceFilter is a source-filter attribute, RFC 4570: the sources sending to
+// Dest, * for all the destinations of the description.
+type SourceFilter struct {
+	Mode     string // incl or excl
+	NetType  string
+	AddrType string // IP4, IP6 or *
+	Dest     string
+	Sources  []string
+}
+
+// NewSourceFilter returns the filter including source for group.
+func NewSourceFilter(group, source netip.Addr) SourceFilter {
+	return SourceFilter{Mode: "incl", NetType: "IN", AddrType: addrType(group), Dest: group.String(), Sources: []string{source.String()}}
+}
+
+// ParseSourceFilter parses a source-filter attribute value.
+func ParseSourceFilter(v string) (SourceFilter, error) {
+	f := strings.Fields(v)
+	if len(f) < 5 {
+		return SourceFilter{}, fmt.Errorf("invalid %s %q", AttrSourceFilter, v)
+	}
+	sf := SourceFilter{Mode: f[0], NetType: f[1], AddrType: f[2], Dest: f[3], Sources: f[4:]}
+	if sf.Mode != "incl" && sf.Mode != "excl" {
+		return sf, fmt.Errorf("invalid %s mode %q", AttrSourceFilter, sf.Mode)
+	}
+	return sf, nil
+}
+
+func (f SourceFilter) String() string {
+	return strings.Join(append([]string{f.Mode, f.NetType, f.AddrType, f.Dest}, f.Sources...), " ")
+}
+
+// Applies reports whether f applies to destination dest.
+func (f SourceFilter) Applies(dest string) bool {
+	return f.Dest == "*" || strings.EqualFold(f.Dest, dest)
+}
+
+// FECDeclaration is a FEC-declaration attribute, the FEC scheme referred to as
+// Ref by the FEC attributes. Instance is that of the under-specified schemes.
+type FECDeclaration struct {
+	Ref      int
+	Encoding api.FECEncoding
+	Instance *api.FECInstance
+}
+
+// ParseFECDeclaration parses a FEC-declaration attribute value, e.g.
+// "0 encoding-id=128; instance-id=0".
+func ParseFECDeclaration(v string) (FECDeclaration, error) {
+	var d FECDeclaration
+	f := strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ';' || r == '\t' })
+	if len(f) < 2 {
+		return d, fmt.Errorf("invalid %s %q", AttrFECDeclaration, v)
+	}
+	var err error
+	if d.Ref, err = strconv.Atoi(f[0]); err != nil {
+		return d, fmt.Errorf("invalid %s reference %q", AttrFECDeclaration, f[0])
+	}
+	encoding := false
+	for _, param := range f[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		n, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return d, fmt.Errorf("invalid %s %s", AttrFECDeclaration, param)
+		}
+		switch name {
+		case "encoding-id":
+			if n > 255 {
+				return d, fmt.Errorf("invalid %s %s", AttrFECDeclaration, param)
+			}
+			d.Encoding, encoding = api.FECEncoding(n), true
+		case "instance-id":
+			instance := api.FECInstance(n)
+			d.Instance = &instance
+		}
+	}
+	if !encoding {
+		return d, fmt.Errorf("%s %q has no encoding-id", AttrFECDeclaration, v)
+	}
+	return d, nil
+}
+
+func (d FECDeclaration) String() string {
+	s := fmt.Sprintf("%d encoding-id=%d", d.Ref, d.Encoding)
+	if d.Instance != nil {
+		s += fmt.Sprintf("; instance-id=%d", *d.Instance)
+	}
+	return s
+}
+
+// FECOTIExtension is a FEC-OTI-extension attribute, the scheme-specific FEC
+// OTI of the FEC-declaration Ref, see fec.OTI.SchemeSpecificInfo.
+type FECOTIExtension struct {
+	Ref  int
+	Info []byte
+}
+
+// ParseFECOTIExtension parses a FEC-OTI-extension attribute value.
+func ParseFECOTIExtension(v string) (FECOTIExtension, error) {
+	ref, info, _ := strings.Cut(strings.TrimSpace(v), " ")
+	var e FECOTIExtension
+	var err error
+	if e.Ref, err = strconv.Atoi(ref); err != nil {
+		return e, fmt.Errorf("invalid %s reference %q", AttrFECOTIExtension, ref)
+	}
+	if e.Info, err = base64.StdEncoding.DecodeString(strings.TrimSpace(info)); err != nil {
+		return e, fmt.Errorf("invalid %s: %w", AttrFECOTIExtension, err)
+	}
+	return e, nil
+}
+
+func (e FECOTIExtension) String() string {
+	return strconv.Itoa(e.Ref) + " " + base64.StdEncoding.EncodeToString(e.Info)
+}
+
+// MBMSRepair is a mbms-repair attribute, the minimum time a receiver buffers
+// the flows protected by the FEC-declaration Ref. Params holds the other
+// parameters, e.g. "name=value".
+type MBMSRepair struct {
+	Ref           int
+	MinBufferTime time.Duration
+	Params        []string
+}
+
+// ParseMBMSRepair parses a mbms-repair attribute value, e.g.
+// "0 min-buffer-time=2600", in milliseconds.
+func ParseMBMSRepair(v string) (MBMSRepair, error) {
+	var r MBMSRepair
+	f := strings.Fields(v)
+	if len(f) == 0 {
+		return r, fmt.Errorf("invalid %s %q", AttrMBMSRepair, v)
+	}
+	var err error
+	if r.Ref, err = strconv.Atoi(f[0]); err != nil {
+		return r, fmt.Errorf("invalid %s reference %q", AttrMBMSRepair, f[0])
+	}
+	for _, param := range f[1:] {
+		value, ok := strings.CutPrefix(param, "min-buffer-time=")
+		if !ok {
+			r.Params = append(r.Params, param)
+			continue
+		}
+		ms, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("invalid %s %s", AttrMBMSRepair, param)
+		}
+		r.MinBufferTime = time.Duration(ms) * time.Millisecond
+	}
+	return r, nil
+}
+
+func (r MBMSRepair) String() string {
+	f := []string{strconv.Itoa(r.Ref)}
+	if r.MinBufferTime > 0 {
+		f = append(f, "min-buffer-time="+strconv.FormatInt(r.MinBufferTime.Milliseconds(), 10))
+	}
+	return strings.Join(append(f, r.Params...), " ")
+}
+
+// FlowID identifies a flow protected by a repair flow, mbms-flowid.
+type FlowID struct {
+	ID   int
+	Addr netip.AddrPort
+}
+
+// ParseFlowIDs parses a mbms-flowid attribute value, e.g.
+// "1=FF1E:03AD::7F2E:172A:1E24/4002, 2=FF1E:03AD::7F2E:172A:1E24/4003".
+func ParseFlowIDs(v string) ([]FlowID, error) {
+	var ids []FlowID
+	for _, flow := range strings.Split(v, ",") {
+		id, dest, ok := strings.Cut(strings.TrimSpace(flow), "=")
+		i := strings.LastIndexByte(dest, '/')
+		if !ok || i < 0 {
+			return nil, fmt.Errorf("invalid %s %q", AttrMBMSFlowID, flow)
+		}
+		var f FlowID
+		var err error
+		if f.ID, err = strconv.Atoi(id); err != nil {
+			return nil, fmt.Errorf("invalid %s %q", AttrMBMSFlowID, flow)
+		}
+		addr, err := netip.ParseAddr(dest[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", AttrMBMSFlowID, err)
+		}
+		port, err := strconv.ParseUint(dest[i+1:], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s port %q", AttrMBMSFlowID, dest[i+1:])
+		}
+		f.Addr = netip.AddrPortFrom(addr, uint16(port))
+		ids = append(ids, f)
+	}
+	return ids, nil
+}
+
+// FormatFlowIDs returns the mbms-flowid attribute value of ids.
+func FormatFlowIDs(ids []FlowID) string {
+	flows := make([]string, len(ids))
+	for i, f := range ids {
+		flows[i] = fmt.Sprintf("%d=%s/%d", f.ID, f.Addr.Addr(), f.Addr.Port())
+	}
+	return strings.Join(flows, ", ")
+}
+
+// addrType returns the address type of a, IP4 or IP6.
+func addrType(a netip.Addr) string {
+	if a.Unmap().Is4() {
+		return "IP4"
+	}
+	return "IP6"
+}
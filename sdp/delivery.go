@@ -0,0 +1,370 @@
+package sdp
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+)
+
+// Protocols of the media descriptions of a delivery method
+const (
+	ProtoFLUTE  = "FLUTE/UDP"
+	ProtoROUTE  = "ROUTE/UDP"
+	ProtoRepair = "UDP/MBMS-REPAIR" // FEC repair flow
+)
+
+// DefaultTTL is the TTL of the IPv4 multicast connections of a delivery method
+// without one.
+const DefaultTTL = 127
+
+// gfM is the finite field size parameter m of Reed-Solomon over GF(2^^m),
+// RFC 5510, which FECParamType does not carry.
+const gfM = 8
+
+// NewFECDeclaration returns the FEC-declaration ref of p and, for Reed-Solomon
+// over GF(2^^m), the FEC-OTI-extension of its m and encoding symbols per
+// packet, the scheme-specific info that does not depend on the object.
+func NewFECDeclaration(ref int, p api.FECParamType) (FECDeclaration, *FECOTIExtension) {
+	d := FECDeclaration{Ref: ref, Encoding: p.Encoding}
+	if p.Encoding >= api.SB_LB_E_FEC_ENC_ID {
+		instance := p.Instance
+		d.Instance = &instance
+	}
+	if p.Encoding != api.RS_GEN_FEC_ENC_ID {
+		return d, nil
+	}
+	o := fec.OTI{Encoding: p.Encoding, M: gfM, G: uint8(max(p.NumEsPerGroup, 1))}
+	return d, &FECOTIExtension{Ref: ref, Info: o.SchemeSpecificInfo()}
+}
+
+// FECParam returns the FEC parameters of d, with the encoding symbols per
+// packet of its FEC-OTI-extension ext, if any. The symbol length, blocking and
+// redundancy are not part of a session description.
+func (d FECDeclaration) FECParam(ext *FECOTIExtension) (api.FECParamType, error) {
+	p := api.FECParamType{Encoding: d.Encoding, NumEsPerGroup: 1}
+	if d.Instance != nil {
+		p.Instance = *d.Instance
+	}
+	if ext == nil || d.Encoding != api.RS_GEN_FEC_ENC_ID {
+		return p, nil
+	}
+	o := fec.OTI{Encoding: d.Encoding}
+	if err := o.SetSchemeSpecificInfo(ext.Info); err != nil {
+		return p, fmt.Errorf("%s %d: %w", AttrFECOTIExtension, ext.Ref, err)
+	}
+	p.NumEsPerGroup = uint32(max(o.G, 1))
+	return p, nil
+}
+
+// mediaProto returns the protocol of the media descriptions of the source flows.
+func mediaProto(p api.TransportProtocolType) (string, error) {
+	switch p {
+	case api.FLUTE:
+		return ProtoFLUTE, nil
+	case api.ROUTE:
+		return ProtoROUTE, nil
+	}
+	return "", fmt.Errorf("unsupported transport protocol %q", p)
+}
+
+// NewSession returns the session description of delivery method i of session
+// s of service svc. The endpoints of its first FEC parameters are the source
+// flows, a media description each, and those of the others repair flows
+// identifying the source flows they protect in mbms-flowid. The FEC parameters
+// are declared in order, and flute-tsi is a session attribute if the source
+// flows share it. The session lasts from the start of the delivery method for
+// its duration and its bandwidth is the maximum bit rate.
+func NewSession(svc api.Service, s api.Session, i int) (*Session, error) {
+	if i < 0 || i >= len(s.Delivery) {
+		return nil, fmt.Errorf("no delivery method %d", i)
+	}
+	d := s.Delivery[i]
+	proto, err := mediaProto(svc.TransportProtocol)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.FEC) == 0 || len(d.FEC[0].Endpoint) == 0 {
+		return nil, fmt.Errorf("no endpoint")
+	}
+	first := d.FEC[0].Endpoint[0]
+	origin := first.Source
+	if !origin.IsValid() {
+		origin = netip.IPv4Unspecified()
+		if !first.Group.Unmap().Is4() {
+			origin = netip.IPv6Unspecified()
+		}
+	}
+	sd := &Session{
+		Origin: Origin{
+			Username:       "-",
+			SessionID:      uint64(s.ID)<<16 | uint64(i),
+			SessionVersion: 1,
+			NetType:        "IN",
+			AddrType:       addrType(origin),
+			Address:        origin.String(),
+		},
+		Name: svc.ServiceId,
+	}
+	if d.BitrateKbps.Maximum > 0 {
+		sd.Bandwidth = []Bandwidth{
+			{Type: BandwidthAS, Value: uint64(d.BitrateKbps.Maximum)},
+			{Type: BandwidthTIAS, Value: uint64(d.BitrateKbps.Maximum) * 1000},
+		}
+	}
+	var start, stop time.Time
+	if dtstart := time.Time(s.Reoccurrences.Dtstart); !dtstart.IsZero() {
+		start = dtstart.Add(time.Duration(d.StartOffset))
+		if d.Duration != nil && *d.Duration > 0 {
+			stop = start.Add(time.Duration(*d.Duration))
+		}
+	}
+	sd.Timing = []Timing{NewTiming(start, stop)}
+
+	for j, p := range d.FEC {
+		decl, ext := NewFECDeclaration(j, p)
+		sd.Attributes.Add(AttrFECDeclaration, decl.String())
+		if ext != nil {
+			sd.Attributes.Add(AttrFECOTIExtension, ext.String())
+		}
+	}
+	if svc.TransportProtocol == api.FLUTE {
+		sd.Attributes.Add(AttrFLUTECh, strconv.Itoa(len(d.FEC[0].Endpoint)))
+	}
+	tsi, shared := sharedTSI(d.FEC[0].Endpoint)
+	if shared && d.FEC[0].Endpoint[0].TSI != nil {
+		sd.Attributes.Add(AttrFLUTETSI, strconv.FormatUint(tsi, 10))
+	}
+
+	flows := make([]FlowID, len(d.FEC[0].Endpoint))
+	for k, ep := range d.FEC[0].Endpoint {
+		m, err := newMedia(proto, "0", ep, d.TTL, 0, !shared)
+		if err != nil {
+			return nil, err
+		}
+		sd.Media = append(sd.Media, m)
+		flows[k] = FlowID{ID: k + 1, Addr: netip.AddrPortFrom(ep.Group, ep.DestPort)}
+	}
+	for j, p := range d.FEC[1:] {
+		for _, ep := range p.Endpoint {
+			m, err := newMedia(ProtoRepair, "*", ep, d.TTL, j+1, true)
+			if err != nil {
+				return nil, err
+			}
+			m.Attributes.Add(AttrMBMSFlowID, FormatFlowIDs(flows))
+			sd.Media = append(sd.Media, m)
+		}
+	}
+	return sd, nil
+}
+
+// sharedTSI returns the TSI of eps, 0 if none, and whether they all share it.
+func sharedTSI(eps api.MulticastEndpointAddressesType) (uint64, bool) {
+	tsi := func(ep api.MulticastEndpointAddressType) uint64 {
+		if ep.TSI == nil {
+			return 0
+		}
+		return *ep.TSI
+	}
+	for _, ep := range eps[1:] {
+		if tsi(ep) != tsi(eps[0]) {
+			return 0, false
+		}
+	}
+	return tsi(eps[0]), true
+}
+
+// newMedia returns the media description of the flow to ep protected by the
+// FEC-declaration ref, with its TSI if withTSI.
+func newMedia(proto, format string, ep api.MulticastEndpointAddressType, ttl uint8, ref int, withTSI bool) (*Media, error) {
+	if !ep.Group.IsValid() || ep.DestPort == 0 {
+		return nil, fmt.Errorf("invalid endpoint %s port %d", ep.Group, ep.DestPort)
+	}
+	c := Connection{NetType: "IN", AddrType: addrType(ep.Group), Address: ep.Group.Unmap().String()}
+	if c.AddrType == "IP4" && ep.Group.IsMulticast() {
+		c.TTL = DefaultTTL
+		if ttl > 0 {
+			c.TTL = int(ttl)
+		}
+	}
+	m := &Media{Type: "application", Port: int(ep.DestPort), Proto: proto, Formats: []string{format}, Connections: []Connection{c}}
+	if ep.Source.IsValid() && !ep.Source.IsUnspecified() {
+		m.Attributes.Add(AttrSourceFilter, NewSourceFilter(ep.Group.Unmap(), ep.Source.Unmap()).String())
+	}
+	if withTSI && ep.TSI != nil {
+		m.Attributes.Add(AttrFLUTETSI, strconv.FormatUint(*ep.TSI, 10))
+	}
+	m.Attributes.Add(AttrFEC, strconv.Itoa(ref))
+	return m, nil
+}
+
+// TransportProtocol returns the transport protocol of the source flows of s.
+func (s *Session) TransportProtocol() (api.TransportProtocolType, error) {
+	for _, m := range s.Media {
+		switch m.Proto {
+		case ProtoFLUTE:
+			return api.FLUTE, nil
+		case ProtoROUTE:
+			return api.ROUTE, nil
+		}
+	}
+	return "", fmt.Errorf("no FLUTE or ROUTE media description")
+}
+
+// Delivery returns the delivery method of s, the reverse of NewSession: its
+// first FEC parameters are those of the source flows, FLUTE or ROUTE media
+// descriptions, and the others those of the repair flows, in the order of
+// their media descriptions. Source flows without FEC attribute are not
+// protected. Both bit rates are the TIAS bandwidth, else the AS one, of the
+// session, else of the first source flow.
+func (s *Session) Delivery() (api.DeliveryMethod, error) {
+	var d api.DeliveryMethod
+	if _, err := s.TransportProtocol(); err != nil {
+		return d, err
+	}
+	decls := map[int]FECDeclaration{}
+	for _, v := range s.Attributes.Values(AttrFECDeclaration) {
+		decl, err := ParseFECDeclaration(v)
+		if err != nil {
+			return d, err
+		}
+		decls[decl.Ref] = decl
+	}
+	exts := map[int]*FECOTIExtension{}
+	for _, v := range s.Attributes.Values(AttrFECOTIExtension) {
+		ext, err := ParseFECOTIExtension(v)
+		if err != nil {
+			return d, err
+		}
+		exts[ext.Ref] = &ext
+	}
+	param := func(m *Media) (api.FECParamType, int, error) {
+		v, ok := m.Attributes.Value(AttrFEC)
+		if !ok {
+			return api.FECParamType{Encoding: api.COM_NO_C_FEC_ENC_ID, NumEsPerGroup: 1}, -1, nil
+		}
+		ref, err := strconv.Atoi(v)
+		if err != nil {
+			return api.FECParamType{}, 0, fmt.Errorf("invalid %s %q", AttrFEC, v)
+		}
+		decl, ok := decls[ref]
+		if !ok {
+			return api.FECParamType{}, 0, fmt.Errorf("no %s %d", AttrFECDeclaration, ref)
+		}
+		p, err := decl.FECParam(exts[ref])
+		return p, ref, err
+	}
+
+	d.FEC = api.FECParamsType{{}}
+	source, sourced := 0, false // FEC-declaration of the source flows, -1 for none
+	repairs := map[int]int{}    // index in d.FEC of the FEC-declarations of repair flows
+	var sourceBandwidth []Bandwidth
+	for k, m := range s.Media {
+		j := 0
+		switch m.Proto {
+		case ProtoFLUTE, ProtoROUTE:
+			p, ref, err := param(m)
+			if err != nil {
+				return d, fmt.Errorf("media %d: %w", k, err)
+			}
+			if !sourced {
+				source, sourced, d.FEC[0] = ref, true, p
+				sourceBandwidth = m.Bandwidth
+			} else if ref != source {
+				return d, fmt.Errorf("media %d: source flows with FEC %d and %d", k, source, ref)
+			}
+		case ProtoRepair:
+			p, ref, err := param(m)
+			if err != nil {
+				return d, fmt.Errorf("media %d: %w", k, err)
+			}
+			var ok bool
+			if j, ok = repairs[ref]; !ok {
+				j = len(d.FEC)
+				repairs[ref] = j
+				d.FEC = append(d.FEC, p)
+			}
+		default:
+			continue
+		}
+		ep, ttl, err := s.endpoint(m)
+		if err != nil {
+			return d, fmt.Errorf("media %d: %w", k, err)
+		}
+		if j == 0 && d.TTL == 0 {
+			d.TTL = ttl
+		}
+		d.FEC[j].Endpoint = append(d.FEC[j].Endpoint, ep)
+	}
+
+	bw := kbps(s.Bandwidth)
+	if bw == 0 {
+		bw = kbps(sourceBandwidth)
+	}
+	d.BitrateKbps = api.BitRateType{Average: int(bw), Maximum: int(bw)}
+	if len(s.Timing) > 0 && s.Timing[0].Start > 0 && s.Timing[0].Stop > s.Timing[0].Start {
+		duration := api.Duration(time.Duration(s.Timing[0].Stop-s.Timing[0].Start) * time.Second)
+		d.Duration = &duration
+	}
+	return d, nil
+}
+
+// kbps returns the TIAS bandwidth of bws in kbit/s, rounded up, else the AS
+// one, 0 if none.
+func kbps(bws []Bandwidth) uint64 {
+	if bw, ok := bandwidth(bws, BandwidthTIAS); ok {
+		return (bw + 999) / 1000
+	}
+	bw, _ := bandwidth(bws, BandwidthAS)
+	return bw
+}
+
+// endpoint returns the endpoint of media description m and the TTL of its
+// connection. Its connection, TSI and source filter are those of the session
+// if it has none.
+func (s *Session) endpoint(m *Media) (api.MulticastEndpointAddressType, uint8, error) {
+	ep := api.MulticastEndpointAddressType{DestPort: uint16(m.Port)}
+	c := s.Connection
+	if len(m.Connections) > 0 {
+		c = &m.Connections[0]
+	}
+	if c == nil {
+		return ep, 0, fmt.Errorf("no connection")
+	}
+	var err error
+	if ep.Group, err = c.Addr(); err != nil {
+		return ep, 0, fmt.Errorf("invalid connection address: %w", err)
+	}
+
+	v, ok := m.Attributes.Value(AttrFLUTETSI)
+	if !ok {
+		v, ok = s.Attributes.Value(AttrFLUTETSI)
+	}
+	if ok {
+		tsi, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return ep, 0, fmt.Errorf("invalid %s %q", AttrFLUTETSI, v)
+		}
+		ep.TSI = &tsi
+	}
+
+	for _, attrs := range []Attributes{m.Attributes, s.Attributes} {
+		for _, v := range attrs.Values(AttrSourceFilter) {
+			f, err := ParseSourceFilter(v)
+			if err != nil {
+				return ep, 0, err
+			}
+			if f.Mode != "incl" || !f.Applies(c.Address) {
+				continue
+			}
+			if ep.Source, err = netip.ParseAddr(f.Sources[0]); err != nil {
+				return ep, 0, fmt.Errorf("invalid %s source: %w", AttrSourceFilter, err)
+			}
+			return ep, uint8(min(c.TTL, 255)), nil
+		}
+	}
+	return ep, uint8(min(c.TTL, 255)), nil
+}
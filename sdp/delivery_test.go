@@ -0,0 +1,149 @@
+package sdp_test
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/sdp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSession(t *testing.T) {
+	tsi, rprTSI := uint64(1), uint64(2)
+	duration := api.Duration(2 * time.Hour)
+	source := netip.MustParseAddr("10.0.0.1")
+	svc := api.Service{ServiceId: "tag:example.com,2024:svc1", TransportProtocol: api.FLUTE}
+	s := api.Session{
+		ID:            7,
+		Reoccurrences: api.RRuleSet{Dtstart: api.TimeZ(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		Delivery: []api.DeliveryMethod{{
+			TTL:         16,
+			StartOffset: api.Duration(time.Minute),
+			Duration:    &duration,
+			BitrateKbps: api.BitRateType{Average: 200, Maximum: 200},
+			FEC: api.FECParamsType{
+				{Encoding: api.RAPTORQ_FEC_ENC_ID, NumEsPerGroup: 1, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: source, Group: netip.MustParseAddr("232.1.1.1"), DestPort: 3000, TSI: &tsi},
+					{Source: source, Group: netip.MustParseAddr("232.1.1.2"), DestPort: 3000, TSI: &tsi},
+				}},
+				{Encoding: api.RS_GEN_FEC_ENC_ID, NumEsPerGroup: 2, Endpoint: api.MulticastEndpointAddressesType{
+					{Source: source, Group: netip.MustParseAddr("232.1.1.3"), DestPort: 3001, TSI: &rprTSI},
+				}},
+			},
+		}},
+	}
+	sd, err := sdp.NewSession(svc, s, 0)
+	require.NoError(t, err)
+	out, err := sd.MarshalText()
+	require.NoError(t, err)
+	start := sdp.NTP(time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC))
+	assert.Equal(t, strings.Join([]string{
+		"v=0",
+		"o=- 458752 1 IN IP4 10.0.0.1",
+		"s=tag:example.com,2024:svc1",
+		"b=AS:200",
+		"b=TIAS:200000",
+		"t=" + strconv.FormatUint(start, 10) + " " + strconv.FormatUint(start+7200, 10),
+		"a=FEC-declaration:0 encoding-id=6",
+		"a=FEC-declaration:1 encoding-id=2",
+		"a=FEC-OTI-extension:1 CAI=",
+		"a=flute-ch:2",
+		"a=flute-tsi:1",
+		"m=application 3000 FLUTE/UDP 0",
+		"c=IN IP4 232.1.1.1/16",
+		"a=source-filter: incl IN IP4 232.1.1.1 10.0.0.1",
+		"a=FEC:0",
+		"m=application 3000 FLUTE/UDP 0",
+		"c=IN IP4 232.1.1.2/16",
+		"a=source-filter: incl IN IP4 232.1.1.2 10.0.0.1",
+		"a=FEC:0",
+		"m=application 3001 UDP/MBMS-REPAIR *",
+		"c=IN IP4 232.1.1.3/16",
+		"a=source-filter: incl IN IP4 232.1.1.3 10.0.0.1",
+		"a=flute-tsi:2",
+		"a=FEC:1",
+		"a=mbms-flowid: 1=232.1.1.1/3000, 2=232.1.1.2/3000",
+		"",
+	}, "\r\n"), string(out))
+
+	parsed, err := sdp.Parse(out)
+	require.NoError(t, err)
+	protocol, err := parsed.TransportProtocol()
+	require.NoError(t, err)
+	assert.Equal(t, api.FLUTE, protocol)
+	d, err := parsed.Delivery()
+	require.NoError(t, err)
+	want := s.Delivery[0]
+	want.StartOffset = 0
+	assert.Equal(t, want, d)
+}
+
+func TestDeliveryFLUTE(t *testing.T) {
+	// the FLUTE session example of TS 26.346, session level TSI and source filter
+	const text = `v=0
+o=user123 2890844526 2890842807 IN IP6 2201:056D::112E:144A:1E24
+s=File delivery session example
+i=More information
+t=2873397496 2873404696
+a=mbms-mode:broadcast 1234 1
+a=FEC-declaration:0 encoding-id=1
+a=source-filter: incl IN IP6 * 2001:210:1:2:240:96FF:FE25:8EC9
+a=flute-tsi:3
+a=flute-ch:1
+m=application 12345 FLUTE/UDP 0
+c=IN IP6 FF1E:03AD::7F2E:172A:1E24/1
+b=64
+a=lang:EN
+a=FEC:0
+`
+	_, err := sdp.Parse([]byte(text))
+	assert.Error(t, err, "b=64 has no type")
+
+	s, err := sdp.Parse([]byte(strings.Replace(text, "b=64", "b=AS:64", 1)))
+	require.NoError(t, err)
+	d, err := s.Delivery()
+	require.NoError(t, err)
+	assert.Equal(t, api.BitRateType{Average: 64, Maximum: 64}, d.BitrateKbps, "media level bandwidth")
+	require.NotNil(t, d.Duration)
+	assert.Equal(t, api.Duration(7200*time.Second), *d.Duration)
+	require.Len(t, d.FEC, 1)
+	assert.Equal(t, api.FECEncoding(1), d.FEC[0].Encoding)
+	require.Len(t, d.FEC[0].Endpoint, 1)
+	ep := d.FEC[0].Endpoint[0]
+	assert.Equal(t, netip.MustParseAddr("2001:210:1:2:240:96FF:FE25:8EC9"), ep.Source)
+	assert.Equal(t, netip.MustParseAddr("FF1E:03AD::7F2E:172A:1E24"), ep.Group)
+	assert.Equal(t, uint16(12345), ep.DestPort)
+	require.NotNil(t, ep.TSI)
+	assert.Equal(t, uint64(3), *ep.TSI)
+}
+
+func TestDeliveryErrors(t *testing.T) {
+	svc := api.Service{ServiceId: "svc", TransportProtocol: api.FLUTE}
+	s := api.Session{Delivery: []api.DeliveryMethod{{FEC: api.FECParamsType{{Endpoint: api.MulticastEndpointAddressesType{
+		{Group: netip.MustParseAddr("239.1.1.1"), DestPort: 3000},
+	}}}}}}
+	_, err := sdp.NewSession(svc, s, 0)
+	require.NoError(t, err)
+	_, err = sdp.NewSession(svc, s, 1)
+	assert.Error(t, err, "no delivery method 1")
+	svc.TransportProtocol = "RTP"
+	_, err = sdp.NewSession(svc, s, 0)
+	assert.ErrorContains(t, err, "unsupported transport protocol")
+
+	for name, text := range map[string]string{
+		"no declaration": "v=0\no=- 1 1 IN IP4 10.0.0.1\ns=x\nm=application 3000 FLUTE/UDP 0\nc=IN IP4 232.1.1.1/1\na=FEC:0\n",
+		"no connection":  "v=0\no=- 1 1 IN IP4 10.0.0.1\ns=x\nm=application 3000 FLUTE/UDP 0\n",
+		"bad tsi":        "v=0\no=- 1 1 IN IP4 10.0.0.1\ns=x\nc=IN IP4 232.1.1.1/1\na=flute-tsi:x\nm=application 3000 FLUTE/UDP 0\n",
+		"mixed fec":      "v=0\no=- 1 1 IN IP4 10.0.0.1\ns=x\nc=IN IP4 232.1.1.1/1\na=FEC-declaration:0 encoding-id=6\nm=application 3000 FLUTE/UDP 0\na=FEC:0\nm=application 3001 FLUTE/UDP 0\n",
+	} {
+		s, err := sdp.Parse([]byte(text))
+		require.NoError(t, err, name)
+		_, err = s.Delivery()
+		assert.Error(t, err, name)
+	}
+}
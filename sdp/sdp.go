@@ -0,0 +1,497 @@
+// Package sdp parses and generates session descriptions, RFC 8866, with the
+// attributes of multicast delivery: source filters, RFC 4570, the bandwidth
+// modifier TIAS, RFC 3890, and the FLUTE, FEC and repair attributes of 3GPP
+// TS 26.346.
+package sdp
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session is a session description.
+type Session struct {
+	Version    int
+	Origin     Origin
+	Name       string   // s=, a single space if none
+	Info       string   // i=
+	URI        string   // u=
+	Emails     []string // e=
+	Phones     []string // p=
+	Connection *Connection
+	Bandwidth  []Bandwidth
+	Timing     []Timing
+	TimeZones  string // z=
+	Key        string // k=, obsolete
+	Attributes Attributes
+	Media      []*Media
+}
+
+// Origin is the o= field, the globally unique identifier of the session.
+type Origin struct {
+	Username       string // - if none
+	SessionID      uint64
+	SessionVersion uint64
+	NetType        string // IN
+	AddrType       string // IP4 or IP6
+	Address        string
+}
+
+// Connection is a c= field. TTL is only set for IPv4 multicast, Count for a
+// range of multicast addresses.
+type Connection struct {
+	NetType  string
+	AddrType string
+	Address  string
+	TTL      int
+	Count    int
+}
+
+// Addr returns the address of c, an error for a domain name.
+func (c Connection) Addr() (netip.Addr, error) {
+	return netip.ParseAddr(c.Address)
+}
+
+// Bandwidth is a b= field, in kbit/s for AS and in bit/s for TIAS.
+type Bandwidth struct {
+	Type  string
+	Value uint64
+}
+
+// Bandwidth types
+const (
+	BandwidthAS   = "AS"   // application specific maximum, kbit/s
+	BandwidthTIAS = "TIAS" // transport independent application specific maximum, bit/s, RFC 3890
+)
+
+// Timing is a t= field and its r= fields. Times are NTP seconds, 0 for an
+// unbounded or permanent session.
+type Timing struct {
+	Start  uint64
+	Stop   uint64
+	Repeat []string
+}
+
+// ntpEpoch is the offset of the Unix epoch from the NTP one, 1900-01-01.
+const ntpEpoch = 2208988800
+
+// NTP returns t in NTP seconds, 0 for the zero time.
+func NTP(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix() + ntpEpoch)
+}
+
+// NTPTime returns the time of NTP seconds s, the zero time for 0.
+func NTPTime(s uint64) time.Time {
+	if s == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(s)-ntpEpoch, 0).UTC()
+}
+
+// NewTiming returns the timing of a session from start to stop, either zero if
+// unbounded.
+func NewTiming(start, stop time.Time) Timing {
+	return Timing{Start: NTP(start), Stop: NTP(stop)}
+}
+
+// Media is a media description, an m= field and the fields that follow it.
+type Media struct {
+	Type        string // audio, video, application...
+	Port        int
+	NumPorts    int // of a port range, 0 if none
+	Proto       string
+	Formats     []string
+	Info        string // i=
+	Connections []Connection
+	Bandwidth   []Bandwidth
+	Key         string
+	Attributes  Attributes
+}
+
+// Attribute is an a= field, a property attribute if Value is empty.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attributes lists the attributes of a session or media description. Keys are
+// matched regardless of case.
+type Attributes []Attribute
+
+// Value returns the value of the first attribute key.
+func (a Attributes) Value(key string) (string, bool) {
+	for _, attr := range a {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Values returns the values of the attributes key.
+func (a Attributes) Values(key string) []string {
+	var values []string
+	for _, attr := range a {
+		if strings.EqualFold(attr.Key, key) {
+			values = append(values, attr.Value)
+		}
+	}
+	return values
+}
+
+// Add appends attribute key with value.
+func (a *Attributes) Add(key, value string) {
+	*a = append(*a, Attribute{Key: key, Value: value})
+}
+
+// spaced are the attributes whose value follows a space, RFC 4570 and TS 26.346.
+var spaced = map[string]bool{
+	AttrSourceFilter: true,
+	AttrMBMSRepair:   true,
+	AttrMBMSFlowID:   true,
+}
+
+func (attr Attribute) String() string {
+	switch {
+	case attr.Value == "":
+		return attr.Key
+	case spaced[attr.Key]:
+		return attr.Key + ": " + attr.Value
+	}
+	return attr.Key + ":" + attr.Value
+}
+
+func (o Origin) String() string {
+	user := o.Username
+	if user == "" {
+		user = "-"
+	}
+	return fmt.Sprintf("%s %d %d %s %s %s", user, o.SessionID, o.SessionVersion, o.NetType, o.AddrType, o.Address)
+}
+
+func (c Connection) String() string {
+	s := c.NetType + " " + c.AddrType + " " + c.Address
+	if c.TTL > 0 {
+		s += "/" + strconv.Itoa(c.TTL)
+	}
+	if c.Count > 1 {
+		s += "/" + strconv.Itoa(c.Count)
+	}
+	return s
+}
+
+func (b Bandwidth) String() string {
+	return b.Type + ":" + strconv.FormatUint(b.Value, 10)
+}
+
+func (m *Media) String() string {
+	port := strconv.Itoa(m.Port)
+	if m.NumPorts > 0 {
+		port += "/" + strconv.Itoa(m.NumPorts)
+	}
+	return strings.Join(append([]string{m.Type, port, m.Proto}, m.Formats...), " ")
+}
+
+// MarshalText returns the session description, CRLF terminated lines in the
+// order of RFC 8866 section 5. A session without timing is permanent, t=0 0.
+func (s *Session) MarshalText() ([]byte, error) {
+	if s.Origin.NetType == "" || s.Origin.AddrType == "" || s.Origin.Address == "" {
+		return nil, fmt.Errorf("incomplete origin %q", s.Origin)
+	}
+	var b strings.Builder
+	line := func(typ byte, v string) {
+		b.WriteByte(typ)
+		b.WriteByte('=')
+		b.WriteString(v)
+		b.WriteString("\r\n")
+	}
+	optional := func(typ byte, v string) {
+		if v != "" {
+			line(typ, v)
+		}
+	}
+	line('v', strconv.Itoa(s.Version))
+	line('o', s.Origin.String())
+	name := s.Name
+	if name == "" {
+		name = " "
+	}
+	line('s', name)
+	optional('i', s.Info)
+	optional('u', s.URI)
+	for _, e := range s.Emails {
+		line('e', e)
+	}
+	for _, p := range s.Phones {
+		line('p', p)
+	}
+	if s.Connection != nil {
+		line('c', s.Connection.String())
+	}
+	for _, bw := range s.Bandwidth {
+		line('b', bw.String())
+	}
+	timing := s.Timing
+	if len(timing) == 0 {
+		timing = []Timing{{}}
+	}
+	for _, t := range timing {
+		line('t', fmt.Sprintf("%d %d", t.Start, t.Stop))
+		for _, r := range t.Repeat {
+			line('r', r)
+		}
+	}
+	optional('z', s.TimeZones)
+	optional('k', s.Key)
+	for _, a := range s.Attributes {
+		line('a', a.String())
+	}
+	for _, m := range s.Media {
+		line('m', m.String())
+		optional('i', m.Info)
+		for _, c := range m.Connections {
+			line('c', c.String())
+		}
+		for _, bw := range m.Bandwidth {
+			line('b', bw.String())
+		}
+		optional('k', m.Key)
+		for _, a := range m.Attributes {
+			line('a', a.String())
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+func (s *Session) String() string {
+	b, err := s.MarshalText()
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// UnmarshalText parses the session description text, see Parse.
+func (s *Session) UnmarshalText(text []byte) error {
+	p, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*s = *p
+	return nil
+}
+
+// Parse parses a session description. Lines may end with LF or CRLF, and the
+// spaces around values are ignored. Fields are accepted in any order, except
+// that v= comes first and a media description holds the fields up to the next
+// m=.
+func Parse(data []byte) (*Session, error) {
+	s := &Session{}
+	var m *Media
+	seen := false
+	for n, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if len(l) < 2 || l[1] != '=' {
+			return nil, fmt.Errorf("sdp: line %d: invalid line %q", n+1, l)
+		}
+		typ, v := l[0], strings.TrimSpace(l[2:])
+		if !seen && typ != 'v' {
+			return nil, fmt.Errorf("sdp: line %d: %c= before v=", n+1, typ)
+		}
+		seen = true
+		if err := s.parseLine(&m, typ, v); err != nil {
+			return nil, fmt.Errorf("sdp: line %d: %c=%s: %w", n+1, typ, v, err)
+		}
+	}
+	if !seen {
+		return nil, fmt.Errorf("sdp: empty session description")
+	}
+	if s.Origin.Address == "" {
+		return nil, fmt.Errorf("sdp: no origin")
+	}
+	return s, nil
+}
+
+func (s *Session) parseLine(mp **Media, typ byte, v string) error {
+	m := *mp
+	var err error
+	switch typ {
+	case 'v':
+		if m != nil || s.Origin.Address != "" {
+			return fmt.Errorf("repeated version")
+		}
+		s.Version, err = strconv.Atoi(v)
+	case 'o':
+		s.Origin, err = parseOrigin(v)
+	case 's':
+		s.Name = v
+	case 'i':
+		if m != nil {
+			m.Info = v
+		} else {
+			s.Info = v
+		}
+	case 'u':
+		s.URI = v
+	case 'e':
+		s.Emails = append(s.Emails, v)
+	case 'p':
+		s.Phones = append(s.Phones, v)
+	case 'c':
+		var c Connection
+		if c, err = parseConnection(v); err != nil {
+			break
+		}
+		if m != nil {
+			m.Connections = append(m.Connections, c)
+		} else {
+			s.Connection = &c
+		}
+	case 'b':
+		var bw Bandwidth
+		if bw, err = parseBandwidth(v); err != nil {
+			break
+		}
+		if m != nil {
+			m.Bandwidth = append(m.Bandwidth, bw)
+		} else {
+			s.Bandwidth = append(s.Bandwidth, bw)
+		}
+	case 't':
+		var t Timing
+		f := strings.Fields(v)
+		if len(f) != 2 {
+			return fmt.Errorf("expected start and stop times")
+		}
+		if t.Start, err = strconv.ParseUint(f[0], 10, 64); err != nil {
+			break
+		}
+		if t.Stop, err = strconv.ParseUint(f[1], 10, 64); err != nil {
+			break
+		}
+		s.Timing = append(s.Timing, t)
+	case 'r':
+		if len(s.Timing) == 0 {
+			return fmt.Errorf("repeat time without t=")
+		}
+		t := &s.Timing[len(s.Timing)-1]
+		t.Repeat = append(t.Repeat, v)
+	case 'z':
+		s.TimeZones = v
+	case 'k':
+		if m != nil {
+			m.Key = v
+		} else {
+			s.Key = v
+		}
+	case 'a':
+		key, value, _ := strings.Cut(v, ":")
+		attr := Attribute{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)}
+		if m != nil {
+			m.Attributes = append(m.Attributes, attr)
+		} else {
+			s.Attributes = append(s.Attributes, attr)
+		}
+	case 'm':
+		if m, err = parseMedia(v); err != nil {
+			break
+		}
+		s.Media = append(s.Media, m)
+		*mp = m
+	default:
+		return fmt.Errorf("unknown type")
+	}
+	return err
+}
+
+func parseOrigin(v string) (Origin, error) {
+	f := strings.Fields(v)
+	if len(f) != 6 {
+		return Origin{}, fmt.Errorf("expected 6 fields")
+	}
+	o := Origin{Username: f[0], NetType: f[3], AddrType: f[4], Address: f[5]}
+	var err error
+	if o.SessionID, err = strconv.ParseUint(f[1], 10, 64); err != nil {
+		return o, err
+	}
+	o.SessionVersion, err = strconv.ParseUint(f[2], 10, 64)
+	return o, err
+}
+
+// parseConnection parses a connection field, whose IPv4 multicast address is
+// followed by its TTL and maybe a count, and IPv6 one by maybe a count.
+func parseConnection(v string) (Connection, error) {
+	f := strings.Fields(v)
+	if len(f) != 3 {
+		return Connection{}, fmt.Errorf("expected 3 fields")
+	}
+	c := Connection{NetType: f[0], AddrType: f[1]}
+	parts := strings.Split(f[2], "/")
+	c.Address = parts[0]
+	nums := make([]int, len(parts)-1)
+	for i, p := range parts[1:] {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid connection address %q", f[2])
+		}
+		nums[i] = n
+	}
+	switch {
+	case len(nums) > 2 || len(nums) == 2 && c.AddrType != "IP4":
+		return c, fmt.Errorf("invalid connection address %q", f[2])
+	case len(nums) == 2:
+		c.TTL, c.Count = nums[0], nums[1]
+	case len(nums) == 1 && c.AddrType == "IP4":
+		c.TTL = nums[0]
+	case len(nums) == 1:
+		c.Count = nums[0]
+	}
+	return c, nil
+}
+
+func parseBandwidth(v string) (Bandwidth, error) {
+	typ, value, ok := strings.Cut(v, ":")
+	if !ok {
+		return Bandwidth{}, fmt.Errorf("expected type:bandwidth")
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	return Bandwidth{Type: strings.TrimSpace(typ), Value: n}, err
+}
+
+func parseMedia(v string) (*Media, error) {
+	f := strings.Fields(v)
+	if len(f) < 4 {
+		return nil, fmt.Errorf("expected media, port, proto and formats")
+	}
+	m := &Media{Type: f[0], Proto: f[2], Formats: f[3:]}
+	port, num, ok := strings.Cut(f[1], "/")
+	var err error
+	if m.Port, err = strconv.Atoi(port); err != nil || m.Port < 0 || m.Port > 0xffff {
+		return nil, fmt.Errorf("invalid port %q", f[1])
+	}
+	if ok {
+		if m.NumPorts, err = strconv.Atoi(num); err != nil || m.NumPorts < 1 {
+			return nil, fmt.Errorf("invalid port %q", f[1])
+		}
+	}
+	return m, nil
+}
+
+// bandwidth returns the bandwidth of type typ in bws.
+func bandwidth(bws []Bandwidth, typ string) (uint64, bool) {
+	for _, bw := range bws {
+		if strings.EqualFold(bw.Type, typ) {
+			return bw.Value, true
+		}
+	}
+	return 0, false
+}
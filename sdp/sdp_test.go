@@ -0,0 +1,147 @@
+package sdp_test
+
+import (
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/sdp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreaming(t *testing.T) {
+	data, err := os.ReadFile("../3gpp/models/session.sdp")
+	require.NoError(t, err)
+	s, err := sdp.Parse(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, sdp.Origin{Username: "ghost", SessionID: 2890844526, SessionVersion: 2890842807, NetType: "IN", AddrType: "IP6", Address: "2001:210:1:2:240:96FF:FE25:8EC9"}, s.Origin)
+	assert.Equal(t, "3GPP MBMS Streaming SDP Example", s.Name)
+	assert.Equal(t, []string{"ghost@mailserver.example.com"}, s.Emails)
+	require.NotNil(t, s.Connection)
+	assert.Equal(t, "FF1E:03AD::7F2E:172A:1E24", s.Connection.Address)
+	assert.Equal(t, []sdp.Bandwidth{{Type: "AS", Value: 62}, {Type: "TIAS", Value: 60500}}, s.Bandwidth)
+	require.Len(t, s.Timing, 1)
+	assert.Equal(t, uint64(3034423619), s.Timing[0].Start)
+	assert.Equal(t, time.Date(1996, 2, 27, 15, 26, 59, 0, time.UTC), sdp.NTPTime(s.Timing[0].Start))
+
+	v, ok := s.Attributes.Value(sdp.AttrSourceFilter)
+	require.True(t, ok)
+	f, err := sdp.ParseSourceFilter(v)
+	require.NoError(t, err)
+	assert.Equal(t, sdp.SourceFilter{Mode: "incl", NetType: "IN", AddrType: "IP6", Dest: "*", Sources: []string{"2001:210:1:2:240:96FF:FE25:8EC9"}}, f)
+	assert.True(t, f.Applies("FF1E:03AD::7F2E:172A:1E24"))
+
+	require.Len(t, s.Media, 2)
+	m := s.Media[0]
+	assert.Equal(t, "video", m.Type)
+	assert.Equal(t, 4002, m.Port)
+	assert.Equal(t, "UDP/MBMS-FEC/RTP/AVP", m.Proto)
+	assert.Equal(t, []string{"96"}, m.Formats)
+	assert.Equal(t, []string{"96 H263-2000/90000"}, m.Attributes.Values("rtpmap"))
+	v, _ = m.Attributes.Value(sdp.AttrFECDeclaration)
+	d, err := sdp.ParseFECDeclaration(v)
+	require.NoError(t, err)
+	assert.Equal(t, sdp.FECDeclaration{Ref: 0, Encoding: 1}, d)
+
+	_, err = s.TransportProtocol()
+	assert.Error(t, err, "RTP streaming is no FLUTE or ROUTE session")
+}
+
+func TestParseRepair(t *testing.T) {
+	data, err := os.ReadFile("../3gpp/models/session-fec.sdp")
+	require.NoError(t, err)
+	s, err := sdp.Parse(data)
+	require.NoError(t, err)
+	require.Len(t, s.Media, 2)
+	m := s.Media[1]
+	assert.Equal(t, sdp.ProtoRepair, m.Proto)
+	assert.Equal(t, []string{"*"}, m.Formats)
+
+	v, _ := m.Attributes.Value(sdp.AttrFECOTIExtension)
+	ext, err := sdp.ParseFECOTIExtension(v)
+	require.NoError(t, err)
+	assert.Equal(t, sdp.FECOTIExtension{Ref: 1, Info: []byte{0, 0x20, 0x04, 0}}, ext)
+
+	v, _ = m.Attributes.Value(sdp.AttrMBMSRepair)
+	r, err := sdp.ParseMBMSRepair(v)
+	require.NoError(t, err)
+	assert.Equal(t, sdp.MBMSRepair{Ref: 1, MinBufferTime: 2600 * time.Millisecond}, r)
+	assert.Equal(t, "1 min-buffer-time=2600", r.String())
+
+	v, _ = m.Attributes.Value(sdp.AttrMBMSFlowID)
+	ids, err := sdp.ParseFlowIDs(v)
+	require.NoError(t, err)
+	group := netip.MustParseAddr("FF1E:03AD::7F2E:172A:1E24")
+	assert.Equal(t, []sdp.FlowID{{ID: 3, Addr: netip.AddrPortFrom(group, 4004)}, {ID: 4, Addr: netip.AddrPortFrom(group, 4005)}}, ids)
+	assert.Equal(t, "3=ff1e:3ad::7f2e:172a:1e24/4004, 4=ff1e:3ad::7f2e:172a:1e24/4005", sdp.FormatFlowIDs(ids))
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, name := range []string{"../3gpp/models/session.sdp", "../3gpp/models/session-fec.sdp"} {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		s, err := sdp.Parse(data)
+		require.NoError(t, err)
+		out, err := s.MarshalText()
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "\r\na=source-filter: incl IN IP6 * ")
+		var again sdp.Session
+		require.NoError(t, again.UnmarshalText(out))
+		assert.Equal(t, s, &again, name)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, text := range map[string]string{
+		"empty":       "",
+		"no version":  "o=- 1 1 IN IP4 10.0.0.1\r\ns=x\r\n",
+		"no origin":   "v=0\r\ns=x\r\n",
+		"bad line":    "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nfoo\r\n",
+		"bad type":    "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nx=1\r\n",
+		"bad port":    "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nm=application 70000 FLUTE/UDP 0\r\n",
+		"bad ttl":     "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nc=IN IP4 233.1.1.1/x\r\n",
+		"ipv6 ttl":    "v=0\r\no=- 1 1 IN IP6 ::1\r\nc=IN IP6 ff3e::1/127/2\r\n",
+		"orphan r":    "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nr=7d 1h 0 25h\r\n",
+		"bad timing":  "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\nt=1\r\n",
+		"bad version": "v=0\r\no=- x 1 IN IP4 10.0.0.1\r\n",
+	} {
+		_, err := sdp.Parse([]byte(text))
+		assert.Error(t, err, name)
+	}
+
+	_, err := sdp.ParseFECDeclaration("0 instance-id=1")
+	assert.ErrorContains(t, err, "no encoding-id")
+	_, err = sdp.ParseSourceFilter("include IN IP4 * 10.0.0.1")
+	assert.Error(t, err)
+	_, err = sdp.ParseFlowIDs("1=233.1.1.1")
+	assert.Error(t, err)
+}
+
+func TestConnection(t *testing.T) {
+	s, err := sdp.Parse([]byte("v=0\no=- 1 1 IN IP4 10.0.0.1\ns=x\nc=IN IP4 233.252.0.1/127/3\nt=0 0\nm=application 4000 FLUTE/UDP 0\nc=IN IP6 ff3e::1/2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &sdp.Connection{NetType: "IN", AddrType: "IP4", Address: "233.252.0.1", TTL: 127, Count: 3}, s.Connection)
+	assert.Equal(t, []sdp.Connection{{NetType: "IN", AddrType: "IP6", Address: "ff3e::1", Count: 2}}, s.Media[0].Connections)
+	assert.Equal(t, "IN IP4 233.252.0.1/127/3", s.Connection.String())
+}
+
+func TestFECDeclaration(t *testing.T) {
+	d, ext := sdp.NewFECDeclaration(2, api.FECParamType{Encoding: api.RS_GEN_FEC_ENC_ID, NumEsPerGroup: 4})
+	assert.Equal(t, "2 encoding-id=2", d.String())
+	require.NotNil(t, ext)
+	assert.Equal(t, []byte{8, 4}, ext.Info)
+	p, err := d.FECParam(ext)
+	require.NoError(t, err)
+	assert.Equal(t, api.FECParamType{Encoding: api.RS_GEN_FEC_ENC_ID, NumEsPerGroup: 4}, p)
+
+	d, ext = sdp.NewFECDeclaration(0, api.FECParamType{Encoding: api.SB_SYS_FEC_ENC_ID, Instance: 3})
+	assert.Nil(t, ext)
+	assert.Equal(t, "0 encoding-id=129; instance-id=3", d.String())
+	parsed, err := sdp.ParseFECDeclaration(d.String())
+	require.NoError(t, err)
+	assert.Equal(t, d, parsed)
+}
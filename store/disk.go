@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// NewDisk returns a store holding every object in a file of dir, named after
+// its escaped key.
+func NewDisk(dir string, maxFileSize uint64) (Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("disk store: %w", err)
+	}
+	return newStore(api.Disk, maxFileSize, func(key string, size int64) (backing, error) {
+		f, err := createFile(dir, key, size)
+		if err != nil {
+			return nil, err
+		}
+		return &file{f}, nil
+	}), nil
+}
+
+// createFile creates the file of object key in dir, of size octets if known.
+// Keys escaping to no name, "." or ".." would name dir or its parent.
+func createFile(dir, key string, size int64) (*os.File, error) {
+	name := url.PathEscape(key)
+	if name == "" || name == "." || name == ".." {
+		return nil, fmt.Errorf("create object %q: invalid key for a file name", key)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("create object %s: %w", key, err)
+	}
+	if size > 0 {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, fmt.Errorf("create object %s: %w", key, err)
+		}
+	}
+	return f, nil
+}
+
+type file struct {
+	*os.File
+}
+
+func (f *file) truncate(size int64) error {
+	return f.Truncate(size)
+}
+
+func (f *file) remove() error {
+	cerr := f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	return cerr
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"io"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// NewMemory returns a store holding its objects in memory.
+func NewMemory(maxFileSize uint64) Store {
+	return newStore(api.Memory, maxFileSize, func(key string, size int64) (backing, error) {
+		m := &memory{}
+		if size > 0 {
+			m.b = make([]byte, size)
+		}
+		return m, nil
+	})
+}
+
+// memory is a growable buffer, the Object serializing its accesses.
+type memory struct {
+	b []byte
+}
+
+func (m *memory) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(m.b)) {
+		if end <= int64(cap(m.b)) {
+			m.b = m.b[:end]
+		} else {
+			m.b = append(m.b[:cap(m.b)], make([]byte, end-int64(cap(m.b)))...)[:end]
+		}
+	}
+	return copy(m.b[off:], p), nil
+}
+
+func (m *memory) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memory) truncate(size int64) error {
+	if size <= int64(len(m.b)) {
+		m.b = m.b[:size]
+		return nil
+	}
+	_, err := m.WriteAt(make([]byte, size-int64(len(m.b))), int64(len(m.b)))
+	return err
+}
+
+func (m *memory) remove() error {
+	m.b = nil
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package store
+
+import "fmt"
+
+// NewMMap is not supported on this platform.
+func NewMMap(dir string, maxFileSize uint64) (Store, error) {
+	return nil, fmt.Errorf("%w: mmap on this platform", ErrUnsupported)
+}
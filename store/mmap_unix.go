@@ -0,0 +1,93 @@
+//go:build unix
+
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	api "github.com/Blockcast/multicast-api"
+)
+
+// NewMMap returns a store mapping the file of every object in dir to memory.
+// The mapping is sized once: objects of unknown size are mapped at maxFileSize,
+// so the store needs one to create them.
+func NewMMap(dir string, maxFileSize uint64) (Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mmap store: %w", err)
+	}
+	return newStore(api.MMap, maxFileSize, func(key string, size int64) (backing, error) {
+		length := size
+		if size < 0 {
+			if maxFileSize == 0 {
+				return nil, fmt.Errorf("%w: mmap object %s of unknown size without a maximum file size", ErrUnsupported, key)
+			}
+			length = int64(maxFileSize)
+		}
+		f, err := createFile(dir, key, length)
+		if err != nil {
+			return nil, err
+		}
+		m := &mmap{f: f, size: size}
+		if length > 0 {
+			if m.b, err = syscall.Mmap(int(f.Fd()), 0, int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED); err != nil {
+				f.Close()
+				os.Remove(f.Name())
+				return nil, fmt.Errorf("mmap object %s: %w", key, err)
+			}
+		}
+		return m, nil
+	}), nil
+}
+
+type mmap struct {
+	f    *os.File
+	b    []byte
+	size int64 // -1 until known, the mapping spanning the maximum file size
+}
+
+func (m *mmap) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m.b)) {
+		return 0, fmt.Errorf("write %d octets at %d beyond mapping of %d", len(p), off, len(m.b))
+	}
+	return copy(m.b[off:], p), nil
+}
+
+func (m *mmap) ReadAt(p []byte, off int64) (int, error) {
+	length := int64(len(m.b))
+	if m.size >= 0 {
+		length = m.size
+	}
+	if off >= length {
+		return 0, io.EOF
+	}
+	n := copy(p, m.b[off:length])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// truncate keeps the mapping and only shrinks the file, the octets beyond size
+// being unused.
+func (m *mmap) truncate(size int64) error {
+	m.size = size
+	return m.f.Truncate(size)
+}
+
+func (m *mmap) remove() error {
+	var err error
+	if m.b != nil {
+		err = syscall.Munmap(m.b)
+		m.b = nil
+	}
+	if cerr := m.f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(m.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
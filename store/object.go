@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Blockcast/multicast-api/fec"
+	sync "github.com/linkdata/deadlock"
+)
+
+// backing holds the octets of an object for a store type.
+type backing interface {
+	io.WriterAt
+	io.ReaderAt
+	// truncate sets the final size of the object.
+	truncate(size int64) error
+	// remove releases the storage of the object.
+	remove() error
+}
+
+// Object is an object of a store, written in any order. It tracks the ranges
+// present and its readers block until the ranges they read arrive.
+type Object struct {
+	key     string
+	max     int64
+	mux     sync.RWMutex
+	b       backing
+	size    int64 // -1 until known
+	present fec.RangeSet
+	changed chan struct{} // closed on every write
+	closed  bool
+}
+
+func newObject(key string, size, max int64, b backing) *Object {
+	return &Object{key: key, max: max, b: b, size: size, changed: make(chan struct{})}
+}
+
+func (o *Object) Key() string {
+	return o.key
+}
+
+// Size returns the size of the object, -1 if not known yet.
+func (o *Object) Size() int64 {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+	return o.size
+}
+
+// SetSize sets the final size of an object created with an unknown one, e.g.
+// once a chunked transfer ends.
+func (o *Object) SetSize(size int64) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	switch {
+	case o.closed:
+		return fmt.Errorf("%w: %s", ErrClosed, o.key)
+	case o.size == size:
+		return nil
+	case o.size >= 0:
+		return fmt.Errorf("object %s size is %d, cannot set %d", o.key, o.size, size)
+	case size < 0:
+		return fmt.Errorf("invalid size %d of object %s", size, o.key)
+	case o.max > 0 && size > o.max:
+		return fmt.Errorf("%w: %s is %d octets, max %d", ErrTooLarge, o.key, size, o.max)
+	}
+	if last, ok := o.present.Last(); ok && last.End >= size {
+		return fmt.Errorf("object %s has octets up to %d beyond size %d", o.key, last.End, size)
+	}
+	if err := o.b.truncate(size); err != nil {
+		return err
+	}
+	o.size = size
+	o.notify()
+	return nil
+}
+
+// notify wakes the readers up, with the lock held.
+func (o *Object) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// Present returns the ranges of the object written so far.
+func (o *Object) Present() fec.RangeList {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+	return o.present.Ranges()
+}
+
+// Complete reports whether the size of the object is known and all of it is present.
+func (o *Object) Complete() bool {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+	return o.size == 0 || o.size > 0 && o.present.Count() == o.size
+}
+
+// WriteAt writes p at offset off and records its range as present.
+func (o *Object) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	end := off + int64(len(p))
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	switch {
+	case o.closed:
+		return 0, fmt.Errorf("%w: %s", ErrClosed, o.key)
+	case o.size >= 0 && end > o.size:
+		return 0, fmt.Errorf("write %d-%d beyond the %d octets of object %s", off, end-1, o.size, o.key)
+	case o.max > 0 && end > o.max:
+		return 0, fmt.Errorf("%w: %s written up to %d, max %d", ErrTooLarge, o.key, end, o.max)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := o.b.WriteAt(p, off)
+	if n > 0 {
+		o.present.InplaceUnion(fec.RangeList{{Start: off, End: off + int64(n) - 1}})
+		o.notify()
+	}
+	return n, err
+}
+
+// ReadAt reads len(p) octets at offset off, blocking until they are present.
+// It returns io.EOF with the octets up to the end of the object, once its size
+// is known.
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	return o.ReadAtContext(context.Background(), p, off)
+}
+
+// ReadAtContext is ReadAt, giving up when ctx is done.
+func (o *Object) ReadAtContext(ctx context.Context, p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if werr := o.wait(ctx, func() bool {
+		end, eof := off+int64(len(p)), false
+		if o.size >= 0 && end >= o.size {
+			end, eof = max(o.size, off), true
+		}
+		if end > off && !o.present.Contains(fec.RangeList{{Start: off, End: end - 1}}) {
+			return false
+		}
+		n, err = o.b.ReadAt(p[:end-off], off)
+		if err == nil && eof {
+			err = io.EOF
+		}
+		return true
+	}); werr != nil {
+		return 0, werr
+	}
+	return n, err
+}
+
+// wait calls ready with the read lock held, on every write until it returns
+// true. It fails once the object is closed or ctx is done.
+func (o *Object) wait(ctx context.Context, ready func() bool) error {
+	for {
+		o.mux.RLock()
+		if o.closed {
+			o.mux.RUnlock()
+			return fmt.Errorf("%w: %s", ErrClosed, o.key)
+		}
+		if ready() {
+			o.mux.RUnlock()
+			return nil
+		}
+		changed := o.changed
+		o.mux.RUnlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// WaitRange blocks until the octets of r are present, r.End == -1 standing for
+// the end of the object.
+func (o *Object) WaitRange(ctx context.Context, r fec.Range) error {
+	return o.wait(ctx, func() bool {
+		want := r
+		if want.End < 0 {
+			if o.size < 0 {
+				return false
+			}
+			want.End = o.size - 1
+		}
+		return want.End < want.Start || o.present.Contains(fec.RangeList{want})
+	})
+}
+
+// NewReader returns a reader of the object from its start, blocking until the
+// octets it reads arrive.
+func (o *Object) NewReader(ctx context.Context) io.Reader {
+	return &reader{ctx: ctx, o: o}
+}
+
+type reader struct {
+	ctx context.Context
+	o   *Object
+	off int64
+}
+
+// Read returns the octets present from the offset of the reader on, up to
+// len(p), waiting for at least one.
+func (r *reader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	o := r.o
+	werr := o.wait(r.ctx, func() bool {
+		if o.size >= 0 && r.off >= o.size {
+			err = io.EOF
+			return true
+		}
+		for _, pr := range o.present.Intersection(fec.RangeList{{Start: r.off, End: r.off + int64(len(p)) - 1}}) {
+			if pr.Start == r.off {
+				n, err = o.b.ReadAt(p[:pr.Count()], r.off)
+				return true
+			}
+		}
+		return false
+	})
+	if werr != nil {
+		return 0, werr
+	}
+	r.off += int64(n)
+	return n, err
+}
+
+// release closes the object, failing its readers, and removes its storage.
+func (o *Object) release() error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	o.notify()
+	return o.b.remove()
+}
@@ -0,0 +1,133 @@
+// Package store holds the objects of a delivery while their ranges arrive, in
+// memory, in memory mapped files or on disk as selected by
+// DeliveryMethod.StoreType, so that every consumer stores objects the same way.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	api "github.com/Blockcast/multicast-api"
+	sync "github.com/linkdata/deadlock"
+)
+
+var (
+	ErrNotFound    = fmt.Errorf("object not found")
+	ErrExists      = fmt.Errorf("object already exists")
+	ErrTooLarge    = fmt.Errorf("object exceeds the maximum file size")
+	ErrClosed      = fmt.Errorf("object closed")
+	ErrUnsupported = fmt.Errorf("unsupported store type")
+)
+
+// Store holds objects by key, e.g. their Content-Location.
+type Store interface {
+	Type() api.StoreType
+	// Create adds the object key of size octets, -1 if not known yet.
+	Create(key string, size int64) (*Object, error)
+	Open(key string) (*Object, error)
+	// Remove closes the object key and releases its storage.
+	Remove(key string) error
+	Keys() []string
+	// Close removes every object.
+	Close() error
+}
+
+// New returns the store of type typ, dir holding the files of the mmap and disk
+// stores. maxFileSize limits the size of every object, 0 for no limit. Souin is
+// a Caddy cache module and has no backend in this module.
+func New(typ api.StoreType, dir string, maxFileSize uint64) (Store, error) {
+	switch typ {
+	case api.Memory:
+		return NewMemory(maxFileSize), nil
+	case api.MMap:
+		return NewMMap(dir, maxFileSize)
+	case api.Disk:
+		return NewDisk(dir, maxFileSize)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnsupported, typ)
+}
+
+// NewForDelivery returns the store of the delivery method d.
+func NewForDelivery(d *api.DeliveryMethod, dir string) (Store, error) {
+	return New(d.StoreType, dir, d.MaxFileSize)
+}
+
+// store indexes the objects of a backend.
+type store struct {
+	typ     api.StoreType
+	max     int64
+	open    func(key string, size int64) (backing, error)
+	mux     sync.RWMutex
+	objects map[string]*Object
+}
+
+func newStore(typ api.StoreType, maxFileSize uint64, open func(key string, size int64) (backing, error)) *store {
+	return &store{typ: typ, max: int64(maxFileSize), open: open, objects: make(map[string]*Object)}
+}
+
+func (s *store) Type() api.StoreType {
+	return s.typ
+}
+
+func (s *store) Create(key string, size int64) (*Object, error) {
+	if size < -1 {
+		return nil, fmt.Errorf("invalid size %d of object %s", size, key)
+	}
+	if s.max > 0 && size > s.max {
+		return nil, fmt.Errorf("%w: %s is %d octets, max %d", ErrTooLarge, key, size, s.max)
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.objects[key]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrExists, key)
+	}
+	b, err := s.open(key, size)
+	if err != nil {
+		return nil, err
+	}
+	o := newObject(key, size, s.max, b)
+	s.objects[key] = o
+	return o, nil
+}
+
+func (s *store) Open(key string) (*Object, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if o, ok := s.objects[key]; ok {
+		return o, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+func (s *store) Remove(key string) error {
+	s.mux.Lock()
+	o, ok := s.objects[key]
+	delete(s.objects, key)
+	s.mux.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return o.release()
+}
+
+func (s *store) Keys() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *store) Close() error {
+	var errs []error
+	for _, key := range s.Keys() {
+		if err := s.Remove(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
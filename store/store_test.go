@@ -0,0 +1,192 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/Blockcast/multicast-api/fec"
+	"github.com/Blockcast/multicast-api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stores(t *testing.T, maxFileSize uint64) map[api.StoreType]store.Store {
+	out := make(map[api.StoreType]store.Store)
+	for _, typ := range []api.StoreType{api.Memory, api.MMap, api.Disk} {
+		s, err := store.New(typ, t.TempDir(), maxFileSize)
+		require.NoError(t, err, typ)
+		t.Cleanup(func() { s.Close() })
+		out[typ] = s
+	}
+	return out
+}
+
+func TestStorePartialWrites(t *testing.T) {
+	for typ, s := range stores(t, 1<<20) {
+		t.Run(string(typ), func(t *testing.T) {
+			assert.Equal(t, typ, s.Type())
+			o, err := s.Create("http://example.com/a b", 10)
+			require.NoError(t, err)
+			_, err = s.Create("http://example.com/a b", 10)
+			assert.ErrorIs(t, err, store.ErrExists)
+
+			_, err = o.WriteAt([]byte("789"), 7)
+			require.NoError(t, err)
+			_, err = o.WriteAt([]byte("012"), 0)
+			require.NoError(t, err)
+			assert.Equal(t, fec.RangeList{{Start: 0, End: 2}, {Start: 7, End: 9}}, o.Present())
+			assert.False(t, o.Complete())
+			_, err = o.WriteAt([]byte("x"), 10)
+			assert.Error(t, err)
+
+			p := make([]byte, 3)
+			n, err := o.ReadAt(p, 7)
+			assert.Equal(t, 3, n)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, "789", string(p))
+
+			_, err = o.WriteAt([]byte("3456"), 3)
+			require.NoError(t, err)
+			assert.True(t, o.Complete())
+			b, err := io.ReadAll(o.NewReader(context.Background()))
+			require.NoError(t, err)
+			assert.Equal(t, "0123456789", string(b))
+
+			got, err := s.Open("http://example.com/a b")
+			require.NoError(t, err)
+			assert.Same(t, o, got)
+			assert.Equal(t, []string{"http://example.com/a b"}, s.Keys())
+			require.NoError(t, s.Remove("http://example.com/a b"))
+			_, err = s.Open("http://example.com/a b")
+			assert.ErrorIs(t, err, store.ErrNotFound)
+			assert.Empty(t, s.Keys())
+		})
+	}
+}
+
+func TestStoreBlockingReaders(t *testing.T) {
+	for typ, s := range stores(t, 1<<20) {
+		t.Run(string(typ), func(t *testing.T) {
+			o, err := s.Create("obj", -1)
+			require.NoError(t, err)
+
+			done := make(chan string)
+			go func() {
+				b, err := io.ReadAll(o.NewReader(context.Background()))
+				assert.NoError(t, err)
+				done <- string(b)
+			}()
+			read := make(chan error)
+			go func() {
+				p := make([]byte, 4)
+				_, err := o.ReadAt(p, 4)
+				read <- err
+			}()
+
+			_, err = o.WriteAt([]byte("4567"), 4)
+			require.NoError(t, err)
+			select {
+			case err := <-read:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("ReadAt not unblocked by the write")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			assert.ErrorIs(t, o.WaitRange(ctx, fec.Range{Start: 0, End: -1}), context.DeadlineExceeded)
+
+			_, err = o.WriteAt([]byte("0123"), 0)
+			require.NoError(t, err)
+			require.NoError(t, o.SetSize(8))
+			assert.Equal(t, int64(8), o.Size())
+			assert.Error(t, o.SetSize(9))
+			require.NoError(t, o.WaitRange(context.Background(), fec.Range{Start: 0, End: -1}))
+			select {
+			case b := <-done:
+				assert.Equal(t, "01234567", b)
+			case <-time.After(5 * time.Second):
+				t.Fatal("reader not unblocked by the end of the object")
+			}
+		})
+	}
+}
+
+func TestStoreRemoveWakesReaders(t *testing.T) {
+	for typ, s := range stores(t, 1<<20) {
+		t.Run(string(typ), func(t *testing.T) {
+			o, err := s.Create("obj", 16)
+			require.NoError(t, err)
+			read := make(chan error)
+			go func() {
+				_, err := o.ReadAt(make([]byte, 4), 0)
+				read <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			require.NoError(t, s.Remove("obj"))
+			select {
+			case err := <-read:
+				assert.ErrorIs(t, err, store.ErrClosed)
+			case <-time.After(5 * time.Second):
+				t.Fatal("ReadAt not unblocked by Remove")
+			}
+			_, err = o.WriteAt([]byte("x"), 0)
+			assert.ErrorIs(t, err, store.ErrClosed)
+		})
+	}
+}
+
+func TestStoreMaxFileSize(t *testing.T) {
+	for typ, s := range stores(t, 8) {
+		t.Run(string(typ), func(t *testing.T) {
+			_, err := s.Create("big", 9)
+			assert.ErrorIs(t, err, store.ErrTooLarge)
+			o, err := s.Create("chunked", -1)
+			require.NoError(t, err)
+			_, err = o.WriteAt([]byte("01234567"), 0)
+			require.NoError(t, err)
+			_, err = o.WriteAt([]byte("8"), 8)
+			assert.ErrorIs(t, err, store.ErrTooLarge)
+			assert.ErrorIs(t, o.SetSize(9), store.ErrTooLarge)
+		})
+	}
+}
+
+func TestStoreFileKeys(t *testing.T) {
+	dir := t.TempDir()
+	for _, typ := range []api.StoreType{api.MMap, api.Disk} {
+		s, err := store.New(typ, dir, 0)
+		require.NoError(t, err)
+		for _, key := range []string{"", ".", ".."} {
+			_, err = s.Create(key, 4)
+			assert.ErrorContains(t, err, "invalid key", "%s key %q", typ, key)
+		}
+		_, err = s.Create("../up", 4)
+		require.NoError(t, err, typ)
+		assert.NoError(t, s.Close())
+	}
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "nothing is created beside the store directory")
+}
+
+func TestStoreNew(t *testing.T) {
+	_, err := store.New(api.Souin, t.TempDir(), 0)
+	assert.True(t, errors.Is(err, store.ErrUnsupported))
+
+	s, err := store.NewForDelivery(&api.DeliveryMethod{StoreType: api.Memory, MaxFileSize: 4}, "")
+	require.NoError(t, err)
+	_, err = s.Create("obj", 5)
+	assert.ErrorIs(t, err, store.ErrTooLarge)
+
+	mm, err := store.NewMMap(t.TempDir(), 0)
+	require.NoError(t, err)
+	_, err = mm.Create("obj", -1)
+	assert.ErrorIs(t, err, store.ErrUnsupported)
+}
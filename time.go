@@ -56,7 +56,7 @@ func (t *TimeZ) UnmarshalJSON(b []byte) error {
 }
 
 func (t TimeZ) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
-	return xml.Attr{name, t.String()}, nil
+	return xml.Attr{Name: name, Value: t.String()}, nil
 }
 func (t *TimeZ) Scan(src interface{}) error {
 	var in []byte
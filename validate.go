@@ -0,0 +1,298 @@
+package api
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrRequired  = fmt.Errorf("required")
+	ErrMinimum   = fmt.Errorf("below minimum")
+	ErrMinItems  = fmt.Errorf("too few items")
+	ErrEnum      = fmt.Errorf("not an allowed value")
+	ErrInvalid   = fmt.Errorf("invalid")
+	ErrDuplicate = fmt.Errorf("duplicate")
+)
+
+// FieldError is a validation error of the field at Field, a path of JSON names,
+// e.g. "streams[0].fec[1].endpoint[0].destGroupAddr".
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists the field errors of a value: those of its tags, then
+// those of the semantic rules.
+type ValidationError []*FieldError
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (v ValidationError) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, e := range v {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Validate enforces the required, minimum and minItems tags of s and the enums
+// of its fields.
+func (s Service) Validate() error {
+	v := &validator{}
+	v.tags("", reflect.ValueOf(s))
+	return v.err()
+}
+
+// Validate enforces the tags of s and of its delivery methods, and the rules of
+// DeliveryMethod.Validate. A TSI is unique per group and port across the
+// delivery methods of the session.
+func (s Session) Validate() error {
+	v := &validator{}
+	v.tags("", reflect.ValueOf(s))
+	for i, d := range s.Delivery {
+		v.delivery(fmt.Sprintf("streams[%d]", i), d)
+	}
+	return v.err()
+}
+
+// Validate enforces the tags of d, that its endpoints are multicast groups, with
+// a source for source-specific multicast (232/8, FF3x::/32), a non-zero port
+// and a TSI unique per group and port, that a packet does not carry more
+// symbols than a source block and that the average bitrate does not exceed the
+// maximum.
+func (d DeliveryMethod) Validate() error {
+	v := &validator{}
+	v.tags("", reflect.ValueOf(d))
+	v.delivery("", d)
+	return v.err()
+}
+
+type validator struct {
+	errs ValidationError
+	tsi  map[string]string // path of the endpoint using a group, port and TSI
+}
+
+func (v *validator) add(field string, err error) {
+	v.errs = append(v.errs, &FieldError{Field: field, Err: err})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// fieldName returns the JSON name of f, its Go name if it has none.
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// apiPkg is the package whose struct types are walked into.
+var apiPkg = reflect.TypeOf(Service{}).PkgPath()
+
+// enumer is implemented by the string enums of this package.
+type enumer interface {
+	Enum() []interface{}
+}
+
+// tags checks the struct rv at path and the structs of this package it holds.
+func (v *validator) tags(path string, rv reflect.Value) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			v.tags(path, fv)
+			continue
+		}
+		name := fieldName(f)
+		if name == "-" {
+			continue
+		}
+		p := fieldPath(path, name)
+		if f.Tag.Get("required") == "true" && isEmpty(fv) {
+			v.add(p, ErrRequired)
+			continue
+		}
+		if s := f.Tag.Get("minItems"); s != "" && fv.Kind() == reflect.Slice {
+			if n, err := strconv.Atoi(s); err == nil && fv.Len() < n {
+				v.add(p, fmt.Errorf("%w: %d, min %d", ErrMinItems, fv.Len(), n))
+			}
+		}
+		if s := f.Tag.Get("minimum"); s != "" {
+			if min, err := strconv.ParseFloat(s, 64); err == nil {
+				if x, ok := number(fv); ok && x < min {
+					v.add(p, fmt.Errorf("%w: %v, min %s", ErrMinimum, x, s))
+				}
+			}
+		}
+		if e, ok := fv.Interface().(enumer); ok && !fv.IsZero() && !isEnum(e) {
+			v.add(p, fmt.Errorf("%w: %v, one of %v", ErrEnum, e, e.Enum()))
+		}
+		v.walk(p, fv)
+	}
+}
+
+// walk checks the structs of this package held by fv.
+func (v *validator) walk(path string, fv reflect.Value) {
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type().PkgPath() == apiPkg {
+			v.tags(path, fv)
+		}
+	case reflect.Pointer:
+		if !fv.IsNil() {
+			v.walk(path, fv.Elem())
+		}
+	case reflect.Slice:
+		if et := fv.Type().Elem(); et.Kind() == reflect.Struct && et.PkgPath() == apiPkg {
+			for j := 0; j < fv.Len(); j++ {
+				v.tags(fmt.Sprintf("%s[%d]", path, j), fv.Index(j))
+			}
+		}
+	}
+}
+
+// isEmpty reports whether the required field fv is missing: an empty slice or
+// map, or a zero value but for the numbers of this package, such as the FEC
+// encoding and code point, for which 0 is a value of its own.
+func isEmpty(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if fv.Type().PkgPath() == apiPkg {
+			return false
+		}
+	}
+	return fv.IsZero()
+}
+
+func isEnum(e enumer) bool {
+	for _, x := range e.Enum() {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+func number(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	}
+	return 0, false
+}
+
+// delivery checks the rules of DeliveryMethod.Validate.
+func (v *validator) delivery(path string, d DeliveryMethod) {
+	if d.BitrateKbps.Maximum > 0 && d.BitrateKbps.Average > d.BitrateKbps.Maximum {
+		v.add(fieldPath(path, "bitrate_kbps.avg"), fmt.Errorf("%w: average %d above maximum %d", ErrInvalid, d.BitrateKbps.Average, d.BitrateKbps.Maximum))
+	}
+	for i, f := range d.FEC {
+		p := fmt.Sprintf("%s[%d]", fieldPath(path, "fec"), i)
+		if f.MaxSrcBlockLen > 0 && f.NumEsPerGroup > f.MaxSrcBlockLen {
+			v.add(fieldPath(p, "numEsPerGroup"), fmt.Errorf("%w: %d symbols per packet above maxSbLen %d", ErrInvalid, f.NumEsPerGroup, f.MaxSrcBlockLen))
+		}
+		for j, ep := range f.Endpoint {
+			v.endpoint(fmt.Sprintf("%s[%d]", fieldPath(p, "endpoint"), j), ep)
+		}
+	}
+}
+
+var (
+	localControl = netip.MustParsePrefix("224.0.0.0/24") // RFC 5771, never forwarded
+	ssm4         = netip.MustParsePrefix("232.0.0.0/8")  // RFC 4607
+)
+
+// isSSM reports whether group is in a source-specific multicast range, 232/8
+// or FF3x::/32, RFC 4607.
+func isSSM(group netip.Addr) bool {
+	if group.Is4() {
+		return ssm4.Contains(group)
+	}
+	b := group.As16()
+	return b[0] == 0xff && b[1]&0xf0 == 0x30
+}
+
+func (v *validator) endpoint(path string, ep MulticastEndpointAddressType) {
+	group := ep.Group.Unmap()
+	switch {
+	case !group.IsValid():
+	case !group.IsMulticast():
+		v.add(fieldPath(path, "destGroupAddr"), fmt.Errorf("%w: %s is not a multicast group", ErrInvalid, ep.Group))
+	case group.Is4() && localControl.Contains(group):
+		v.add(fieldPath(path, "destGroupAddr"), fmt.Errorf("%w: %s is in the local network control block", ErrInvalid, ep.Group))
+	case group.Is6() && group.As16()[1]&0x0f <= 1:
+		v.add(fieldPath(path, "destGroupAddr"), fmt.Errorf("%w: %s has a reserved or interface-local scope", ErrInvalid, ep.Group))
+	}
+	source := ep.Source.Unmap()
+	switch {
+	case !source.IsValid() || source.IsUnspecified():
+		if group.IsValid() && isSSM(group) {
+			v.add(fieldPath(path, "sourceAddr"), fmt.Errorf("%w: source-specific multicast group %s", ErrRequired, ep.Group))
+		}
+	case source.IsMulticast():
+		v.add(fieldPath(path, "sourceAddr"), fmt.Errorf("%w: %s is not a unicast source", ErrInvalid, ep.Source))
+	case group.IsValid() && source.Is4() != group.Is4():
+		v.add(fieldPath(path, "sourceAddr"), fmt.Errorf("%w: %s and group %s differ in address family", ErrInvalid, ep.Source, ep.Group))
+	}
+	if ep.DestPort == 0 {
+		v.add(fieldPath(path, "destPort"), ErrRequired)
+	}
+	if !group.IsValid() || ep.DestPort == 0 {
+		return
+	}
+	var tsi uint64
+	if ep.TSI != nil {
+		tsi = *ep.TSI
+	}
+	key := fmt.Sprintf("%s/%d", netip.AddrPortFrom(group, ep.DestPort), tsi)
+	if prev, ok := v.tsi[key]; ok {
+		v.add(fieldPath(path, "sessionId"), fmt.Errorf("%w: tsi %d on %s already used by %s", ErrDuplicate, tsi, netip.AddrPortFrom(group, ep.DestPort), prev))
+		return
+	}
+	if v.tsi == nil {
+		v.tsi = make(map[string]string)
+	}
+	v.tsi[key] = path
+}
@@ -0,0 +1,113 @@
+package api_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	api "github.com/Blockcast/multicast-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func delivery(ep api.MulticastEndpointAddressType) api.DeliveryMethod {
+	return api.DeliveryMethod{
+		BitrateKbps: api.BitRateType{Average: 1000, Maximum: 2000},
+		StoreType:   api.Memory,
+		FEC: api.FECParamsType{{
+			CodePoint:      1,
+			Encoding:       api.RAPTORQ_FEC_ENC_ID,
+			SymbolLen:      1428,
+			MaxSrcBlockLen: 64,
+			NumEsPerGroup:  1,
+			Endpoint:       []api.MulticastEndpointAddressType{ep},
+		}},
+	}
+}
+
+// fieldErrors maps the field paths of err to their errors.
+func fieldErrors(t *testing.T, err error) map[string]error {
+	t.Helper()
+	var v api.ValidationError
+	require.True(t, errors.As(err, &v), "%v", err)
+	m := make(map[string]error, len(v))
+	for _, e := range v {
+		m[e.Field] = e
+	}
+	return m
+}
+
+func TestDeliveryMethodValidate(t *testing.T) {
+	group := netip.MustParseAddr("239.1.1.1")
+	ep := api.MulticastEndpointAddressType{Group: group, DestPort: 5000, TSI: ptr(uint64(1))}
+	require.NoError(t, delivery(ep).Validate())
+	noCode := delivery(ep)
+	noCode.FEC[0].Encoding, noCode.FEC[0].CodePoint = api.COM_NO_C_FEC_ENC_ID, 0
+	require.NoError(t, noCode.Validate(), "Compact No-Code and code point 0 are values")
+
+	ssm := ep
+	ssm.Group = netip.MustParseAddr("232.1.1.1")
+	ssm.Source = netip.MustParseAddr("10.0.0.1")
+	require.NoError(t, delivery(ssm).Validate())
+	ssm6 := ep
+	ssm6.Group = netip.MustParseAddr("ff3e::8000:1")
+	ssm6.Source = netip.MustParseAddr("2001:db8::1")
+	require.NoError(t, delivery(ssm6).Validate())
+
+	for _, tt := range []struct {
+		name  string
+		edit  func(d *api.DeliveryMethod)
+		field string
+		err   error
+	}{
+		{"missing bitrate", func(d *api.DeliveryMethod) { d.BitrateKbps.Maximum = 0 }, "bitrate_kbps.max", api.ErrRequired},
+		{"negative bitrate", func(d *api.DeliveryMethod) { d.BitrateKbps.Average = -1 }, "bitrate_kbps.avg", api.ErrMinimum},
+		{"missing store", func(d *api.DeliveryMethod) { d.StoreType = "" }, "StoreType", api.ErrRequired},
+		{"unknown store", func(d *api.DeliveryMethod) { d.StoreType = "tape" }, "StoreType", api.ErrEnum},
+		{"no fec", func(d *api.DeliveryMethod) { d.FEC = api.FECParamsType{} }, "fec", api.ErrRequired},
+		{"missing symbol length", func(d *api.DeliveryMethod) { d.FEC[0].SymbolLen = 0 }, "fec[0].symLength", api.ErrRequired},
+		{"negative redundancy", func(d *api.DeliveryMethod) { d.FEC[0].Redundancy = -0.5 }, "fec[0].redundancy", api.ErrMinimum},
+		{"no endpoint", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint = nil }, "fec[0].endpoint", api.ErrMinItems},
+		{"average above maximum", func(d *api.DeliveryMethod) { d.BitrateKbps.Average = 3000 }, "bitrate_kbps.avg", api.ErrInvalid},
+		{"packet above block", func(d *api.DeliveryMethod) { d.FEC[0].NumEsPerGroup = 65 }, "fec[0].numEsPerGroup", api.ErrInvalid},
+		{"unicast group", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Group = netip.MustParseAddr("10.0.0.1") }, "fec[0].endpoint[0].destGroupAddr", api.ErrInvalid},
+		{"local network control group", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Group = netip.MustParseAddr("224.0.0.5") }, "fec[0].endpoint[0].destGroupAddr", api.ErrInvalid},
+		{"interface-local group", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Group = netip.MustParseAddr("ff01::1") }, "fec[0].endpoint[0].destGroupAddr", api.ErrInvalid},
+		{"ssm without source", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Group = netip.MustParseAddr("232.1.1.1") }, "fec[0].endpoint[0].sourceAddr", api.ErrRequired},
+		{"ipv6 ssm without source", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Group = netip.MustParseAddr("ff3e::8000:1") }, "fec[0].endpoint[0].sourceAddr", api.ErrRequired},
+		{"multicast source", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Source = netip.MustParseAddr("239.2.2.2") }, "fec[0].endpoint[0].sourceAddr", api.ErrInvalid},
+		{"source family", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].Source = netip.MustParseAddr("2001:db8::1") }, "fec[0].endpoint[0].sourceAddr", api.ErrInvalid},
+		{"zero port", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint[0].DestPort = 0 }, "fec[0].endpoint[0].destPort", api.ErrRequired},
+		{"duplicate tsi", func(d *api.DeliveryMethod) { d.FEC[0].Endpoint = append(d.FEC[0].Endpoint, ep) }, "fec[0].endpoint[1].sessionId", api.ErrDuplicate},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := delivery(ep)
+			tt.edit(&d)
+			errs := fieldErrors(t, d.Validate())
+			require.Contains(t, errs, tt.field)
+			assert.ErrorIs(t, errs[tt.field], tt.err)
+			assert.Len(t, errs, 1, "%v", errs)
+		})
+	}
+}
+
+func TestSessionValidateTSI(t *testing.T) {
+	ep := api.MulticastEndpointAddressType{Group: netip.MustParseAddr("239.1.1.1"), DestPort: 5000, TSI: ptr(uint64(1))}
+	s := api.Session{Delivery: []api.DeliveryMethod{delivery(ep), delivery(ep)}}
+	errs := fieldErrors(t, s.Validate())
+	require.Contains(t, errs, "streams[1].fec[0].endpoint[0].sessionId")
+	assert.ErrorIs(t, errs["streams[1].fec[0].endpoint[0].sessionId"], api.ErrDuplicate)
+	assert.NotContains(t, errs, "streams[0].fec[0].endpoint[0].sessionId")
+
+	// another port, or a missing TSI (0) next to TSI 1, is another session
+	other := ep
+	other.DestPort = 5001
+	s.Delivery[1] = delivery(other)
+	assert.NotContains(t, fieldErrors(t, s.Validate()), "streams[1].fec[0].endpoint[0].sessionId")
+	other = ep
+	other.TSI = nil
+	s.Delivery[1] = delivery(other)
+	assert.NotContains(t, fieldErrors(t, s.Validate()), "streams[1].fec[0].endpoint[0].sessionId")
+}